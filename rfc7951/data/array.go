@@ -7,6 +7,8 @@ package data
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"reflect"
 	"sort"
 
@@ -66,8 +68,10 @@ func (arr *Array) with(elements ...interface{}) *Array {
 }
 
 // At returns the value at the index of the array, if the index is out
-// of bounds, nil is returned.
+// of bounds, nil is returned. A negative index counts back from the end
+// of the array, -1 is the last element.
 func (arr *Array) At(index int) *Value {
+	index = arr.resolveIndex(index)
 	if index >= arr.store.Length() || index < 0 {
 		return nil
 	}
@@ -75,28 +79,77 @@ func (arr *Array) At(index int) *Value {
 }
 
 // Contains returns whether the index is in the bounds of the array.
+// A negative index counts back from the end of the array.
 func (arr *Array) Contains(index int) bool {
+	index = arr.resolveIndex(index)
 	return index < arr.store.Length() && index >= 0
 }
 
 // Find returns the value at the index or nil if it doesn't exist and
-// whether the index was in the array.
+// whether the index was in the array. A negative index counts back
+// from the end of the array.
 func (arr *Array) Find(index int) (*Value, bool) {
-	v, ok := arr.store.Find(index)
+	v, ok := arr.store.Find(arr.resolveIndex(index))
 	if !ok {
 		return nil, ok
 	}
 	return v.(*Value), ok
 }
 
+// resolveIndex converts a negative index, counting back from the end
+// of the array, to the equivalent non-negative index. The result is
+// still negative if the index is before the start of the array.
+func (arr *Array) resolveIndex(index int) int {
+	if index < 0 {
+		return arr.store.Length() + index
+	}
+	return index
+}
+
+// mustResolveIndex is like resolveIndex but panics if the index is
+// before the start of the array.
+func (arr *Array) mustResolveIndex(index int) int {
+	resolved := arr.resolveIndex(index)
+	if resolved < 0 {
+		panic(fmt.Errorf("index %d out of bounds", index))
+	}
+	return resolved
+}
+
+// IndexOf returns the index of the first element of the array that is
+// Equal to value, or -1 if there is none. The value is converted with
+// ValueNew so native go values may be supplied.
+func (arr *Array) IndexOf(value interface{}) int {
+	val := arr.adaptValue(ValueNew(value))
+	out := -1
+	arr.Range(func(i int, v *Value) bool {
+		if equal(v, val) {
+			out = i
+			return false
+		}
+		return true
+	})
+	return out
+}
+
+// ContainsValue returns whether any element of the array is Equal to
+// value. Unlike Contains, which checks index bounds, this searches the
+// array's elements.
+func (arr *Array) ContainsValue(value interface{}) bool {
+	return arr.IndexOf(value) != -1
+}
+
 // Assoc associates the value with the index in the array. If the
-// index is out of bounds the array is padded to that index and the value
-// is associated.
+// index is out of bounds the array is padded to that index with null
+// values and the value is associated. A negative index counts back from
+// the end of the array, Assoc panics if it is before the start of the
+// array.
 func (arr *Array) Assoc(index int, value interface{}) *Array {
+	index = arr.mustResolveIndex(index)
 	newStore := arr.store
 	if arr.Length() <= index {
 		for i := arr.Length(); i < index+1; i++ {
-			newStore = newStore.Append(nil)
+			newStore = newStore.Append(ValueNew(nil))
 		}
 	}
 	newStore = newStore.Assoc(index, arr.adaptValue(ValueNew(value)))
@@ -120,15 +173,161 @@ func (arr *Array) Append(value interface{}) *Array {
 	}
 }
 
-// Delete removes an element at the supplied index from the array.
+// Concat returns a new array containing the elements of arr followed by
+// the elements of each of the others in turn. The appended elements are
+// adapted to arr's module, as they are by Append. Nil and empty arrays
+// are skipped, if nothing is appended arr is returned unchanged.
+func (arr *Array) Concat(others ...*Array) *Array {
+	var appended bool
+	out := arr.Transform(func(out *TArray) {
+		for _, other := range others {
+			if other == nil {
+				continue
+			}
+			other.Range(func(v *Value) {
+				out.Append(v)
+				appended = true
+			})
+		}
+	})
+	if !appended {
+		return arr
+	}
+	return out
+}
+
+// Delete removes an element at the supplied index from the array. A
+// negative index counts back from the end of the array.
 func (arr *Array) Delete(index int) *Array {
-	newStore := arr.store.Delete(index)
+	newStore := arr.store.Delete(arr.resolveIndex(index))
 	return &Array{
 		store:  newStore,
 		module: arr.module,
 	}
 }
 
+// RemoveAt returns a new array without the element at index, the
+// elements after it shift one position to the left. It is the inverse
+// of Insert, a negative index counts back from the end of the array as
+// it does for Insert. RemoveAt panics if index is out of bounds.
+func (arr *Array) RemoveAt(index int) *Array {
+	return arr.Delete(arr.mustResolveIndex(index))
+}
+
+// Without returns a new array with the elements at all of the supplied
+// indices removed. The indices refer to positions in the original array,
+// so the removal of one element does not change which element another
+// index refers to. Negative indices count back from the end of the array
+// as they do for At. Duplicate and out of range indices are ignored.
+func (arr *Array) Without(indices ...int) *Array {
+	remove := make(map[int]struct{}, len(indices))
+	for _, index := range indices {
+		index = arr.resolveIndex(index)
+		if index >= 0 && index < arr.Length() {
+			remove[index] = struct{}{}
+		}
+	}
+	if len(remove) == 0 {
+		return arr
+	}
+	out := arr.copy()
+	out.store = vector.Empty().Transform(
+		func(store *vector.TVector) *vector.TVector {
+			arr.Range(func(i int, elem *Value) {
+				if _, removed := remove[i]; !removed {
+					store = store.Append(elem)
+				}
+			})
+			return store
+		})
+	return out
+}
+
+// Insert returns a new array with value placed before the element at
+// index, shifting that element and those after it one position to the
+// right. Unlike Assoc no element is overwritten. If index is beyond the
+// end of the array the array is padded with nulls up to index. A
+// negative index counts back from the end of the array, as for At, so
+// -1 inserts before the last element. Insert panics if a negative
+// index is before the start of the array.
+func (arr *Array) Insert(index int, value interface{}) *Array {
+	index = arr.mustResolveIndex(index)
+	if index > arr.Length() {
+		return arr.Assoc(index, value)
+	}
+	return arr.insert(index, value)
+}
+
+// insert places value at index shifting the element at index, and
+// those after it, one position to the right. An index equal to the
+// length of the array appends the value.
+func (arr *Array) insert(index int, value interface{}) *Array {
+	return arr.Transform(func(out *TArray) {
+		out.Append(nil)
+		for i := arr.Length(); i > index; i-- {
+			out.Assoc(i, arr.At(i-1))
+		}
+		out.Assoc(index, value)
+	})
+}
+
+// everyElement reports whether fn returns true for every element of
+// the array, stopping at the first that it does not.
+func (arr *Array) everyElement(fn func(int, *Value) bool) bool {
+	out := true
+	arr.Range(func(i int, v *Value) bool {
+		out = fn(i, v)
+		return out
+	})
+	return out
+}
+
+// Slice returns a new array containing the elements from index start up
+// to but not including end. Negative indices are treated as 0 and
+// indices beyond the length of the array are clamped to its length. An
+// empty array is returned if start is not less than end. The elements
+// themselves are shared with the original array and slicing the whole
+// array returns the original.
+func (arr *Array) Slice(start, end int) *Array {
+	length := arr.Length()
+	start, end = clampIndex(start, length), clampIndex(end, length)
+	if start == 0 && end == length {
+		return arr
+	}
+	out := arr.copy()
+	out.store = vector.Empty()
+	if start >= end {
+		return out
+	}
+	out.store = out.store.Transform(
+		func(store *vector.TVector) *vector.TVector {
+			arr.store.Slice(start, end).Range(func(_ int, elem interface{}) {
+				store = store.Append(elem)
+			})
+			return store
+		})
+	return out
+}
+
+func clampIndex(index, length int) int {
+	switch {
+	case index < 0:
+		return 0
+	case index > length:
+		return length
+	default:
+		return index
+	}
+}
+
+// Compact returns a new array with all of the null elements removed.
+// The order of the remaining elements is preserved.
+func (arr *Array) Compact() *Array {
+	return arr.selectItems(func(elem *Value) bool {
+		return elem != nil && !elem.IsNull()
+	})
+}
+
 func (arr *Array) detect(fn func(*Value) bool) *Value {
 	// TODO: is there a better name for this?
 	return arr.detectAndIfNone(fn, func() *Value { return nil })
@@ -151,6 +350,41 @@ func (arr *Array) detectAndIfNone(fn func(*Value) bool, ifNone func() *Value) *V
 	return ifNone()
 }
 
+// SelectIndices returns the indices of every element of a list whose
+// keyLeaf member has the RFC7951 string representation value, strings
+// are compared without escaping. This is the array equivalent of the
+// instance-identifier predicate [keyLeaf='value']. Elements that are
+// not objects never match.
+func (arr *Array) SelectIndices(keyLeaf, value string) []int {
+	return arr.selectIndices(func(elem *Value) bool {
+		obj := elem.ToObject()
+		if obj == nil {
+			return false
+		}
+		key, found := obj.Find(keyLeaf)
+		return found && key != nil && predicateValue(key) == value
+	})
+}
+
+// SelectIndicesLeafList returns the indices of every element of a
+// leaf-list whose RFC7951 string representation is value. This is the
+// array equivalent of the instance-identifier predicate [.='value'].
+func (arr *Array) SelectIndicesLeafList(value string) []int {
+	return arr.selectIndices(func(elem *Value) bool {
+		return predicateValue(elem) == value
+	})
+}
+
+func (arr *Array) selectIndices(fn func(*Value) bool) []int {
+	out := []int{}
+	arr.Range(func(idx int, elem *Value) {
+		if fn(elem) {
+			out = append(out, idx)
+		}
+	})
+	return out
+}
+
 // Range iterates over the object's members. Range can take a set of functions
 // matched by type. If the function returns a bool this is treated as a
 // loop terminataion variable if false the loop will terminate.
@@ -190,6 +424,32 @@ func (arr *Array) Range(fn interface{}) *Array {
 	return arr
 }
 
+// Filter returns a new array containing only the elements for which
+// fn returns true.
+func (arr *Array) Filter(fn func(*Value) bool) *Array {
+	return arr.selectItems(fn)
+}
+
+// Map returns a new array containing the result of applying fn to each
+// element in index order. If fn returns nil the element is replaced with
+// a null value so the returned array is always the same length as the
+// original.
+func (arr *Array) Map(fn func(*Value) *Value) *Array {
+	out := arr.copy()
+	out.store = out.store.Transform(
+		func(store *vector.TVector) *vector.TVector {
+			arr.Range(func(idx int, elem *Value) {
+				new := fn(elem)
+				if new == nil {
+					new = ValueNew(nil)
+				}
+				store = store.Assoc(idx, out.adaptValue(new))
+			})
+			return store
+		})
+	return out
+}
+
 func (arr *Array) selectItems(fn func(*Value) bool) *Array {
 	out := ArrayNew()
 	out.module = arr.module
@@ -245,6 +505,49 @@ func (arr *Array) belongsTo(orig *Value, moduleName string) *Value {
 	return ValueNew(out)
 }
 
+func (arr *Array) renameModule(from, to string) *Value {
+	out := &Array{
+		module: renameModule(arr.module, from, to),
+		store: vector.Empty().Transform(
+			func(store *vector.TVector) *vector.TVector {
+				arr.Range(func(val *Value) {
+					store = store.Append(
+						val.renameModule(from, to))
+				})
+				return store
+			}),
+	}
+	return ValueNew(out)
+}
+
+func (arr *Array) detach() *Value {
+	out := &Array{
+		module: cloneString(arr.module),
+		store: vector.Empty().Transform(
+			func(store *vector.TVector) *vector.TVector {
+				arr.Range(func(val *Value) {
+					store = store.Append(val.Detach())
+				})
+				return store
+			}),
+	}
+	return ValueNew(out)
+}
+
+func (arr *Array) clone() *Value {
+	out := &Array{
+		module: arr.module,
+		store: vector.Empty().Transform(
+			func(store *vector.TVector) *vector.TVector {
+				arr.Range(func(val *Value) {
+					store = store.Append(val.Clone())
+				})
+				return store
+			}),
+	}
+	return ValueNew(out)
+}
+
 func (arr *Array) adaptValue(val *Value) *Value {
 	return val.belongsTo(val, arr.module)
 }
@@ -256,26 +559,25 @@ func (arr *Array) copy() *Array {
 	}
 }
 
-// merge merges one array with another. The returned array is the
-// old array with any existing indicies replaced with counterparts from the
-// new object and any new indicies added. Merge is accretive only and will
-// not remove non-existant indicies.
-func (arr *Array) merge(new *Value) *Value {
+// merge merges one array with another according to the supplied
+// policy. By default the returned array is the old array with any
+// existing indicies replaced with counterparts from the new object and
+// any new indicies added. Merge is accretive only and will not remove
+// non-existant indicies.
+func (arr *Array) merge(new *Value, policy ArrayMergePolicy) *Value {
 	return new.Perform(func(n *Array) *Value {
-		out := arr.Transform(func(out *TArray) {
-			arr.Range(func(i int, v *Value) {
-				if n.Contains(i) {
-					out = out.Assoc(i,
-						v.Merge(n.At(i)))
-				}
-			})
-			n.Range(func(i int, v *Value) {
-				if !arr.Contains(i) {
-					out = out.Append(v)
-				}
-			})
-		})
-		return ValueNew(out)
+		switch policy.kind {
+		case arrayMergeReplace:
+			return ValueNew(n)
+		case arrayMergeAppend:
+			return ValueNew(arr.mergeAppend(n))
+		case arrayMergeUnion:
+			return ValueNew(arr.mergeUnion(n))
+		case arrayMergeByKey:
+			return ValueNew(arr.mergeByKey(n, policy))
+		default:
+			return ValueNew(arr.mergeByIndex(n, policy))
+		}
 	}, func(_ interface{}) *Value {
 		// By default just return the original array; can't merge
 		// unlike types.
@@ -283,6 +585,112 @@ func (arr *Array) merge(new *Value) *Value {
 	}).(*Value)
 }
 
+func (arr *Array) mergeByIndex(n *Array, policy ArrayMergePolicy) *Array {
+	return arr.Transform(func(out *TArray) {
+		arr.Range(func(i int, v *Value) {
+			if n.Contains(i) {
+				out = out.Assoc(i,
+					v.MergeArrays(n.At(i), policy))
+			}
+		})
+		n.Range(func(i int, v *Value) {
+			if !arr.Contains(i) {
+				out = out.Append(v)
+			}
+		})
+	})
+}
+
+func (arr *Array) mergeAppend(n *Array) *Array {
+	return arr.Transform(func(out *TArray) {
+		n.Range(func(v *Value) {
+			out = out.Append(v)
+		})
+	})
+}
+
+func (arr *Array) mergeUnion(n *Array) *Array {
+	out := arr
+	n.Range(func(v *Value) {
+		if !out.ContainsValue(v) {
+			out = out.Append(v)
+		}
+	})
+	return out
+}
+
+func (arr *Array) mergeByKey(n *Array, policy ArrayMergePolicy) *Array {
+	return arr.Transform(func(out *TArray) {
+		matched := make(map[int]struct{})
+		arr.Range(func(i int, v *Value) {
+			key := policy.key(v)
+			if isNilKey(key) {
+				return
+			}
+			n.Range(func(j int, nv *Value) bool {
+				if _, seen := matched[j]; seen ||
+					!equal(key, policy.key(nv)) {
+					return true
+				}
+				matched[j] = struct{}{}
+				out = out.Assoc(i, v.MergeArrays(nv, policy))
+				return false
+			})
+		})
+		n.Range(func(j int, v *Value) {
+			if _, seen := matched[j]; !seen {
+				out = out.Append(v)
+			}
+		})
+	})
+}
+
+func isNilKey(key interface{}) bool {
+	v, isValue := key.(*Value)
+	return key == nil || (isValue && v == nil)
+}
+
+type arrayMergeKind int
+
+const (
+	arrayMergeByIndex arrayMergeKind = iota
+	arrayMergeReplace
+	arrayMergeAppend
+	arrayMergeByKey
+	arrayMergeUnion
+)
+
+// ArrayMergePolicy determines how two arrays are combined by
+// MergeArrays. The zero value is equivalent to ArrayByIndex.
+type ArrayMergePolicy struct {
+	kind arrayMergeKind
+	key  func(*Value) interface{}
+}
+
+var (
+	// ArrayByIndex merges arrays positionally, the elements at each
+	// index are merged and any additional elements are appended. This
+	// is the policy used by Merge.
+	ArrayByIndex = ArrayMergePolicy{kind: arrayMergeByIndex}
+	// ArrayReplace replaces the old array wholesale with the new one.
+	ArrayReplace = ArrayMergePolicy{kind: arrayMergeReplace}
+	// ArrayAppend appends the elements of the new array to the old one.
+	ArrayAppend = ArrayMergePolicy{kind: arrayMergeAppend}
+	// ArrayUnion treats arrays as sets, as is typical of YANG
+	// leaf-lists. Elements of the new array that are not Equal to an
+	// element already present are appended, the order of the existing
+	// elements is preserved.
+	ArrayUnion = ArrayMergePolicy{kind: arrayMergeUnion}
+)
+
+// ArrayByKey merges arrays by matching elements using the key
+// returned by keyFn. Elements whose keys are equal are merged, any
+// elements in the new array without a match are appended. Elements for
+// which keyFn returns nil never match.
+func ArrayByKey(keyFn func(*Value) interface{}) ArrayMergePolicy {
+	return ArrayMergePolicy{kind: arrayMergeByKey, key: keyFn}
+}
+
 // Equal implements equality for arrays. An array is equal to another
 // array if all their values at each index is equal. Equality checks are linear
 // with respect to the number of elements.
@@ -301,22 +709,48 @@ func (arr *Array) String() string {
 	return buf.String()
 }
 
-func (arr *Array) marshalRFC7951(buf *bytes.Buffer, module string) error {
-	buf.WriteByte('[')
-	arr.Range(func(i int, v *Value) {
-		v.marshalRFC7951(buf, module)
-		if i < arr.Length()-1 {
-			buf.WriteByte(',')
+// WriteRFC7951 writes the Array encoded as RFC7951 data to w. The
+// encoding is streamed to w as it is produced.
+func (arr *Array) WriteRFC7951(w io.Writer) error {
+	return writeRFC7951(w, arr)
+}
+
+// AppendRFC7951 appends the Array encoded as RFC7951 data to dst and
+// returns the extended slice, see Value.AppendRFC7951.
+func (arr *Array) AppendRFC7951(dst []byte) ([]byte, error) {
+	return appendRFC7951(dst, arr)
+}
+
+func (arr *Array) marshalRFC7951(w marshalWriter, module string) error {
+	if cw, caching := w.(cachingWriter); caching {
+		return cw.marshal(arr, module, arr.encodeRFC7951)
+	}
+	return arr.encodeRFC7951(w, module)
+}
+
+func (arr *Array) encodeRFC7951(w marshalWriter, module string) error {
+	err := w.WriteByte('[')
+	if err != nil {
+		return err
+	}
+	arr.Range(func(i int, v *Value) bool {
+		err = v.marshalRFC7951(w, module)
+		if err == nil && i < arr.Length()-1 {
+			err = w.WriteByte(',')
 		}
+		return err == nil
 	})
-	buf.WriteByte(']')
-	return nil
+	if err != nil {
+		return err
+	}
+	return w.WriteByte(']')
 }
 
 func (arr *Array) unmarshalRFC7951(
 	msg []byte, module string,
 	strs *stringInterner,
 	vals *valueInterner,
+	opts *decodeOpts,
 ) error {
 	var a []rfc7951.RawMessage
 	rfc7951.Unmarshal(msg, &a)
@@ -325,7 +759,7 @@ func (arr *Array) unmarshalRFC7951(
 		func(store *vector.TVector) *vector.TVector {
 			for _, v := range a {
 				val := valueNew(nil)
-				val.unmarshalRFC7951(v, arr.module, strs, vals)
+				val.unmarshalRFC7951(v, arr.module, strs, vals, opts)
 				val = arr.adaptValue(val)
 				val = vals.Intern(val)
 				store = store.Append(val)
@@ -335,33 +769,40 @@ func (arr *Array) unmarshalRFC7951(
 	return nil
 }
 
-func (arr *Array) diff(new *Value, path *InstanceID) []EditEntry {
+// diffMerge returns the edits needed to change the elements of arr into
+// those of new. Changed elements are edited in place, see mergePatch,
+// new elements are appended and removed elements are deleted from the
+// end of the array first so the indices of the remaining edits stay
+// valid.
+func (arr *Array) diffMerge(new *Array, path *InstanceID) []EditEntry {
 	out := []EditEntry{}
-	new.Perform(func(other *Array) {
-		arr.Range(func(i int, v *Value) {
-			if other.Contains(i) {
-				out = append(out,
-					v.diff(other.At(i),
-						path.addPosPredicate(i))...)
-			} else {
-				out = append(out,
-					EditEntry{
-						Action: EditDelete,
-						Path:   path.addPosPredicate(i),
-					})
-			}
-		})
-		other.Range(func(i int, v *Value) {
-			if arr.Contains(i) {
-				return
-			}
+	new.Range(func(i int, v *Value) {
+		old, found := arr.Find(i)
+		switch {
+		case !found:
+			out = append(out, EditEntry{
+				Action: EditAssoc,
+				Path:   path.addPosPredicate(i),
+				Value:  v,
+			})
+		case !equal(old, v):
 			out = append(out,
-				EditEntry{
-					Action: EditAssoc,
-					Path:   path.addPosPredicate(i),
-					Value:  v,
-				})
+				diffMergeValues(old, v, path.addPosPredicate(i))...)
+		}
+	})
+	for i := arr.Length() - 1; i >= new.Length(); i-- {
+		out = append(out, EditEntry{
+			Action: EditDelete,
+			Path:   path.addPosPredicate(i),
 		})
+	}
+	return out
+}
+
+func (arr *Array) diff(new *Value, path *InstanceID) []EditEntry {
+	out := []EditEntry{}
+	new.Perform(func(other *Array) {
+		out = arr.diffArray(other, path)
 	}, func(other interface{}) {
 		out = []EditEntry{
 			{Action: EditAssoc, Path: path, Value: ValueNew(new)},
@@ -370,24 +811,223 @@ func (arr *Array) diff(new *Value, path *InstanceID) []EditEntry {
 	return out
 }
 
+// diffArray returns the edits that transform arr into other. Elements
+// that are Equal in both arrays are matched, so an element that has
+// changed position is moved with an EditMove rather than each of the
+// positions it passed being reassigned. The remaining elements of arr
+// are paired in order with the remaining elements of other and diffed
+// in place, and any left over are deleted or inserted.
+//
+// The edits are ordered so that each applies to the result of the
+// previous ones: first deletions, from the highest index down, then
+// moves and insertions in ascending order of destination, and finally
+// the differences within the paired elements at their final positions.
+func (arr *Array) diffArray(other *Array, path *InstanceID) []EditEntry {
+	out := []EditEntry{}
+
+	// Match each element of other with an unused equal element of
+	// arr, oldFor holds the index in arr for each index in other.
+	oldFor := make([]int, other.Length())
+	used := make([]bool, arr.Length())
+	byHash := make(map[uint64][]int)
+	arr.Range(func(i int, v *Value) {
+		h := v.Hash()
+		byHash[h] = append(byHash[h], i)
+	})
+	other.Range(func(j int, v *Value) {
+		oldFor[j] = -1
+		h := v.Hash()
+		candidates := byHash[h]
+		for n, i := range candidates {
+			if equal(arr.At(i), v) {
+				oldFor[j], used[i] = i, true
+				byHash[h] = append(candidates[:n:n], candidates[n+1:]...)
+				return
+			}
+		}
+	})
+
+	// Pair up the unmatched elements in order, those of arr that
+	// remain are deleted.
+	modified := make([]bool, other.Length())
+	var unused []int
+	for i, isUsed := range used {
+		if !isUsed {
+			unused = append(unused, i)
+		}
+	}
+	for j := range oldFor {
+		if oldFor[j] == -1 && len(unused) > 0 {
+			oldFor[j], used[unused[0]] = unused[0], true
+			modified[j] = true
+			unused = unused[1:]
+		}
+	}
+	for n := len(unused) - 1; n >= 0; n-- {
+		out = append(out, EditEntry{
+			Action: EditDelete,
+			Path:   path.addPosPredicate(unused[n]),
+		})
+	}
+
+	// cur tracks the position in other that each element of the
+	// array being edited is destined for.
+	newFor := make(map[int]int, len(oldFor))
+	for j, i := range oldFor {
+		if i != -1 {
+			newFor[i] = j
+		}
+	}
+	cur := make([]int, 0, other.Length())
+	for i, isUsed := range used {
+		if isUsed {
+			cur = append(cur, newFor[i])
+		}
+	}
+
+	// Elements in the longest run that is already in order stay where
+	// they are, every other element is moved or inserted immediately
+	// after its predecessor in other.
+	stable := longestIncreasing(oldFor)
+	indexOf := func(j int) int {
+		for p, k := range cur {
+			if k == j {
+				return p
+			}
+		}
+		return -1
+	}
+	for j := range oldFor {
+		if stable[j] {
+			continue
+		}
+		from := -1
+		if oldFor[j] != -1 {
+			from = indexOf(j)
+			cur = append(cur[:from], cur[from+1:]...)
+		}
+		to := 0
+		if j > 0 {
+			to = indexOf(j-1) + 1
+		}
+		cur = append(cur, 0)
+		copy(cur[to+1:], cur[to:])
+		cur[to] = j
+		switch {
+		case from == to:
+		case from != -1:
+			out = append(out, EditEntry{
+				Action: EditMove,
+				Path:   path.addPosPredicate(to),
+				From:   path.addPosPredicate(from),
+			})
+		case to == len(cur)-1:
+			out = append(out, EditEntry{
+				Action: EditAssoc,
+				Path:   path.addPosPredicate(to),
+				Value:  other.At(j),
+			})
+		default:
+			out = append(out, EditEntry{
+				Action: EditInsert,
+				Path:   path.addPosPredicate(to),
+				Value:  other.At(j),
+			})
+		}
+	}
+
+	for j, isModified := range modified {
+		if isModified {
+			out = append(out, arr.At(oldFor[j]).diff(other.At(j),
+				path.addPosPredicate(j))...)
+		}
+	}
+	return out
+}
+
+// longestIncreasing reports which of the elements of seq form a longest
+// strictly increasing subsequence. Elements that are -1 are never part
+// of the subsequence.
+func longestIncreasing(seq []int) []bool {
+	// tails[n] is the index in seq of the smallest element that ends
+	// an increasing subsequence of length n+1.
+	var tails []int
+	prev := make([]int, len(seq))
+	for j, v := range seq {
+		prev[j] = -1
+		if v == -1 {
+			continue
+		}
+		n := sort.Search(len(tails), func(n int) bool {
+			return seq[tails[n]] >= v
+		})
+		if n > 0 {
+			prev[j] = tails[n-1]
+		}
+		if n == len(tails) {
+			tails = append(tails, j)
+		} else {
+			tails[n] = j
+		}
+	}
+	out := make([]bool, len(seq))
+	if len(tails) == 0 {
+		return out
+	}
+	for j := tails[len(tails)-1]; j != -1; j = prev[j] {
+		out[j] = true
+	}
+	return out
+}
+
 // Transform executes the provided function against a mutable
 // transient array to provide a faster, less memory intensive, array
 // editing mechanism.
 func (arr *Array) Transform(fn func(*TArray)) *Array {
-	tarr := &TArray{
+	tarr := arr.asTransient()
+	fn(tarr)
+	return tarr.asPersistent()
+}
+
+func (arr *Array) asTransient() *TArray {
+	return &TArray{
 		orig:  arr,
 		store: arr.store.AsTransient(),
 	}
-	fn(tarr)
-	out := arr.copy()
-	out.store = tarr.store.AsPersistent()
-	return out
 }
 
 // Sort sorts an array returning a new array that is sorted.
 // by default sort will use dyn.Compare as the comparison operator
 // this may be overridden using the Compare option.
 func (arr *Array) Sort(options ...SortOption) *Array {
+	out := arr.copy()
+	out.store = sortStore(out.store.AsTransient(), options).AsPersistent()
+	return out
+}
+
+// BinarySearch searches an array sorted by cmp for target and returns
+// the index of the first element that cmp considers equal to it and
+// true, or the index at which target would be inserted to keep the
+// array sorted and false. cmp follows the same contract as the function
+// given to the Compare sort option and a nil cmp uses the same default
+// ordering as Sort, so an array may be searched with the ordering it
+// was sorted by. The result is undefined if the array isn't sorted
+// according to cmp.
+func (arr *Array) BinarySearch(target *Value, cmp func(a, b *Value) int) (int, bool) {
+	if cmp == nil {
+		cmp = func(a, b *Value) int {
+			return a.Compare(b)
+		}
+	}
+	i := sort.Search(arr.Length(), func(i int) bool {
+		return cmp(arr.At(i), target) >= 0
+	})
+	return i, i < arr.Length() && cmp(arr.At(i), target) == 0
+}
+
+// sortStore sorts the elements of the transient vector in place
+// according to the options.
+func sortStore(store *vector.TVector, options []SortOption) *vector.TVector {
 	var opts sortOpts
 	opts.compare = func(v1, v2 *Value) int {
 		return v1.Compare(v2)
@@ -395,18 +1035,113 @@ func (arr *Array) Sort(options ...SortOption) *Array {
 	for _, opt := range options {
 		opt(&opts)
 	}
-	out := arr.copy()
 	sorter := arraySorter{
-		array: out.store.AsTransient(),
+		array: store,
 		opts:  &opts,
 	}
-	sort.Sort(&sorter)
-	out.store = sorter.array.AsPersistent()
+	if opts.key != nil {
+		sorter.keys = make([]*Value, store.Length())
+		for i := range sorter.keys {
+			sorter.keys[i] = opts.key(store.At(i).(*Value))
+		}
+	}
+	if opts.stable {
+		sort.Stable(&sorter)
+	} else {
+		sort.Sort(&sorter)
+	}
+	return sorter.array
+}
+
+// Reverse returns a new array with the elements in reverse order.
+// Arrays with fewer than two elements are returned unchanged.
+func (arr *Array) Reverse() *Array {
+	if arr.Length() < 2 {
+		return arr
+	}
+	return arr.Transform(func(tarr *TArray) {
+		tarr.Reverse()
+	})
+}
+
+// GroupBy buckets the elements of the array by the string keyFn
+// returns for each of them. The returned object maps each key to an
+// array of the elements with that key, in the order they appear in the
+// array; an empty key is a group like any other. The keys are used as
+// is, a key containing a ':' is not treated as being qualified by a
+// module, and each group belongs to the same module as the array.
+func (arr *Array) GroupBy(keyFn func(*Value) string) *Object {
+	groups := make(map[string]*TArray)
+	arr.Range(func(v *Value) {
+		key := keyFn(v)
+		group, ok := groups[key]
+		if !ok {
+			group = arr.Slice(0, 0).asTransient()
+			groups[key] = group
+		}
+		group.Append(v)
+	})
+	return ObjectNew().Transform(func(out *TObject) {
+		for key, group := range groups {
+			out.store = out.store.Assoc(key,
+				ValueNew(group.asPersistent()))
+		}
+	})
+}
+
+// Unique returns a new array with duplicate elements removed, keeping
+// the first occurrence of each. Elements are duplicates if they are
+// Equal, or if the keys extracted from them are Equal when UniqueByKey
+// is supplied. The order of the remaining elements is preserved.
+func (arr *Array) Unique(options ...UniqueOption) *Array {
+	var opts uniqueOpts
+	opts.key = func(v *Value) *Value { return v }
+	for _, opt := range options {
+		opt(&opts)
+	}
+	seen := make(map[uint64][]*Value)
+	isDuplicate := func(key *Value) bool {
+		if key == nil {
+			key = ValueNew(nil)
+		}
+		hash := key.Hash()
+		for _, other := range seen[hash] {
+			if key.Equal(other) {
+				return true
+			}
+		}
+		seen[hash] = append(seen[hash], key)
+		return false
+	}
+	out := arr.selectItems(func(v *Value) bool {
+		return !isDuplicate(opts.key(v))
+	})
+	if out.Length() == arr.Length() {
+		return arr
+	}
 	return out
 }
 
+// UniqueOption is an option to the Array.Unique function.
+type UniqueOption func(*uniqueOpts)
+
+type uniqueOpts struct {
+	key func(*Value) *Value
+}
+
+// UniqueByKey returns a unique option that identifies duplicates by the
+// key extracted from each element by keyFn rather than by the elements
+// themselves. A nil key is treated as null. keyFn is called exactly
+// once per element.
+func UniqueByKey(keyFn func(*Value) *Value) UniqueOption {
+	return func(opts *uniqueOpts) {
+		opts.key = keyFn
+	}
+}
+
 type arraySorter struct {
 	array *vector.TVector
+	keys  []*Value
 	opts  *sortOpts
 }
 
@@ -415,6 +1150,9 @@ func (s *arraySorter) Len() int {
 }
 
 func (s *arraySorter) Less(i, j int) bool {
+	if s.keys != nil {
+		return s.opts.compare(s.keys[i], s.keys[j]) < 0
+	}
 	return s.opts.compare(s.array.At(i).(*Value),
 		s.array.At(j).(*Value)) < 0
 }
@@ -423,10 +1161,15 @@ func (s *arraySorter) Swap(i, j int) {
 	a, b := s.array.At(i), s.array.At(j)
 	s.array.Assoc(i, b)
 	s.array.Assoc(j, a)
+	if s.keys != nil {
+		s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	}
 }
 
 type sortOpts struct {
 	compare func(v1, v2 *Value) int
+	key     func(*Value) *Value
+	stable  bool
 }
 
 // SortOption is an option to the Array.Sort function
@@ -443,6 +1186,40 @@ func Compare(fn func(a, b *Value) int) SortOption {
 	}
 }
 
+// StableSort returns a sort option that makes the sort stable, elements
+// that compare equal retain their original order.
+func StableSort() SortOption {
+	return func(opts *sortOpts) {
+		opts.stable = true
+	}
+}
+
+// SortByKey returns a sort option that orders elements by the key
+// extracted from each of them by keyFn rather than by the elements
+// themselves. Keys are compared with the comparison function, see
+// Compare. keyFn is called exactly once per element.
+func SortByKey(keyFn func(*Value) *Value) SortOption {
+	return func(opts *sortOpts) {
+		opts.key = keyFn
+	}
+}
+
+// CompareChain takes a list of comparison functions and returns a sort
+// option that applies them in order. The next comparison function is
+// only consulted when the previous ones consider the values equal. This
+// allows sorting by multiple keys without writing a single monolithic
+// comparison function.
+func CompareChain(cmps ...func(a, b *Value) int) SortOption {
+	return Compare(func(a, b *Value) int {
+		for _, cmp := range cmps {
+			if c := cmp(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
 // TArray is a transient array that may be used to perform
 // transformations on an array in a fast mutable fashion. This can
 // only be accessed via the (*Array).Transform method. Care should be
@@ -452,10 +1229,21 @@ type TArray struct {
 	store *vector.TVector
 }
 
+func (arr *TArray) asPersistent() *Array {
+	out := arr.orig.copy()
+	out.store = arr.store.AsPersistent()
+	return out
+}
+
 // Assoc associates the value with the index in the array. If the
 // index is out of bounds the array is padded to that index and the
-// value is associated.
+// value is associated. A negative index counts back from the end of
+// the array, Assoc panics if it is before the start of the array.
 func (arr *TArray) Assoc(i int, v interface{}) *TArray {
+	i = arr.mustResolveIndex(i)
+	for arr.store.Length() <= i {
+		arr.store = arr.store.Append(ValueNew(nil))
+	}
 	arr.store = arr.store.Assoc(i, arr.orig.adaptValue(ValueNew(v)))
 	return arr
 }
@@ -480,9 +1268,47 @@ func (arr *TArray) Contains(index int) bool {
 	return index < arr.store.Length() && index >= 0
 }
 
-// Delete removes an element at the supplied index from the array.
+// Delete removes an element at the supplied index from the array. A
+// negative index counts back from the end of the array.
 func (arr *TArray) Delete(index int) *TArray {
-	arr.store = arr.store.Delete(index)
+	arr.store = arr.store.Delete(arr.resolveIndex(index))
+	return arr
+}
+
+// resolveIndex converts a negative index, counting back from the end
+// of the array, to the equivalent non-negative index, see
+// (*Array).resolveIndex.
+func (arr *TArray) resolveIndex(index int) int {
+	if index < 0 {
+		return arr.store.Length() + index
+	}
+	return index
+}
+
+// mustResolveIndex is like resolveIndex but panics if the index is
+// before the start of the array.
+func (arr *TArray) mustResolveIndex(index int) int {
+	resolved := arr.resolveIndex(index)
+	if resolved < 0 {
+		panic(fmt.Errorf("index %d out of bounds", index))
+	}
+	return resolved
+}
+
+// Filter removes the elements for which fn returns false from the
+// array.
+func (arr *TArray) Filter(fn func(*Value) bool) *TArray {
+	keep := make([]*Value, 0, arr.Length())
+	arr.Range(func(elem *Value) {
+		if fn(elem) {
+			keep = append(keep, elem)
+		}
+	})
+	store := vector.Empty().AsTransient()
+	for _, elem := range keep {
+		store = store.Append(elem)
+	}
+	arr.store = store
 	return arr
 }
 
@@ -545,19 +1371,17 @@ func (arr *TArray) Range(fn interface{}) {
 // by default sort will use dyn.Compare as the comparison operator
 // this may be overridden using the Compare option.
 func (arr *TArray) Sort(options ...SortOption) *TArray {
-	var opts sortOpts
-	opts.compare = func(v1, v2 *Value) int {
-		return v1.Compare(v2)
-	}
-	for _, opt := range options {
-		opt(&opts)
-	}
-	sorter := arraySorter{
-		array: arr.store,
-		opts:  &opts,
+	arr.store = sortStore(arr.store, options)
+	return arr
+}
+
+// Reverse reverses the order of the array's elements in place.
+func (arr *TArray) Reverse() *TArray {
+	for i, j := 0, arr.store.Length()-1; i < j; i, j = i+1, j-1 {
+		a, b := arr.store.At(i), arr.store.At(j)
+		arr.store = arr.store.Assoc(i, b)
+		arr.store = arr.store.Assoc(j, a)
 	}
-	sort.Sort(&sorter)
-	arr.store = sorter.array
 	return arr
 }
 
@@ -568,14 +1392,20 @@ func (arr *TArray) String() string {
 	return buf.String()
 }
 
-func (arr *TArray) marshalRFC7951(buf *bytes.Buffer, module string) error {
-	buf.WriteByte('[')
-	arr.Range(func(i int, v *Value) {
-		v.marshalRFC7951(buf, module)
-		if i < arr.Length()-1 {
-			buf.WriteByte(',')
+func (arr *TArray) marshalRFC7951(w marshalWriter, module string) error {
+	err := w.WriteByte('[')
+	if err != nil {
+		return err
+	}
+	arr.Range(func(i int, v *Value) bool {
+		err = v.marshalRFC7951(w, module)
+		if err == nil && i < arr.Length()-1 {
+			err = w.WriteByte(',')
 		}
+		return err == nil
 	})
-	buf.WriteByte(']')
-	return nil
+	if err != nil {
+		return err
+	}
+	return w.WriteByte(']')
 }
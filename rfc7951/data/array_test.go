@@ -6,11 +6,14 @@
 package data
 
 import (
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"unicode"
 
 	"jsouthworth.net/go/dyn"
+	"jsouthworth.net/go/try"
 )
 
 func testCollectionArray(cons func(sz int) *Array, t *testing.T) {
@@ -204,13 +207,312 @@ func TestArrayFind(t *testing.T) {
 		}
 	})
 	t.Run("out of bounds", func(t *testing.T) {
+		v, ok := arr.Find(6)
+		if ok || v != nil {
+			t.Fatal("found an out of bounds value")
+		}
+	})
+}
+
+func TestArrayFindNegative(t *testing.T) {
+	// A negative index counts back from the end of the array, so -1
+	// is the last element rather than out of bounds.
+	arr := ArrayWith(1, 2, 3, 4, 5, 6)
+	t.Run("last", func(t *testing.T) {
 		v, ok := arr.Find(-1)
+		if !ok || v.AsInt64() != 6 {
+			t.Fatal("didn't find the last value")
+		}
+	})
+	t.Run("before start", func(t *testing.T) {
+		v, ok := arr.Find(-7)
 		if ok || v != nil {
 			t.Fatal("found an out of bounds value")
 		}
 	})
 }
 
+func TestArrayNegativeIndex(t *testing.T) {
+	arr := ArrayWith(0, 1, 2, 3, 4, 5)
+	cases := []struct {
+		name     string
+		index    int
+		expected *Value
+	}{
+		{"last", -1, ValueNew(5)},
+		{"first", -6, ValueNew(0)},
+		{"middle", -3, ValueNew(3)},
+		{"before-start", -7, nil},
+		{"beyond-end", 6, nil},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := arr.At(test.index)
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+			if arr.Contains(test.index) != (test.expected != nil) {
+				t.Fatalf("Contains disagrees with At for %d",
+					test.index)
+			}
+		})
+	}
+	if ArrayNew().Contains(-1) {
+		t.Fatal("empty array should not contain any index")
+	}
+	t.Run("Delete", func(t *testing.T) {
+		var got *Array
+		if arr.Contains(-1) {
+			got = arr.Delete(-1)
+		}
+		expected := ArrayWith(0, 1, 2, 3, 4)
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		got = arr.Transform(func(a *TArray) {
+			a.Delete(-6)
+		})
+		expected = ArrayWith(1, 2, 3, 4, 5)
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("Assoc", func(t *testing.T) {
+		got := arr.Assoc(-2, "x")
+		expected := ArrayWith(0, 1, 2, 3, "x", 5)
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		got = arr.Transform(func(a *TArray) {
+			a.Assoc(-1, "x")
+		})
+		expected = ArrayWith(0, 1, 2, 3, 4, "x")
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		_, err := try.Apply(arr.Assoc, -7, "x")
+		if err == nil {
+			t.Fatal("expected a panic for an index before the start")
+		}
+	})
+}
+
+func TestArrayIndexOf(t *testing.T) {
+	arr := ArrayWith("foo", 2, "bar", 2, ObjectWith(PairNew("m:a", "b")))
+	cases := []struct {
+		name     string
+		value    interface{}
+		expected int
+	}{
+		{"string", "bar", 2},
+		{"first-match", 2, 1},
+		{"value", ValueNew("foo"), 0},
+		{"object", ObjectWith(PairNew("m:a", "b")), 4},
+		{"missing", "baz", -1},
+		{"different-type", "2", -1},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := arr.IndexOf(test.value)
+			if got != test.expected {
+				t.Fatalf("expected: %d\ngot: %d\n", test.expected, got)
+			}
+			if arr.ContainsValue(test.value) != (test.expected != -1) {
+				t.Fatalf("ContainsValue disagrees with IndexOf for %v",
+					test.value)
+			}
+		})
+	}
+	if ArrayNew().ContainsValue(nil) {
+		t.Fatal("empty array should not contain any value")
+	}
+}
+
+func TestArraySlice(t *testing.T) {
+	arr := ArrayWith(0, 1, 2, 3, 4, 5)
+	cases := []struct {
+		name       string
+		start, end int
+		expected   *Array
+	}{
+		{"middle", 1, 4, ArrayWith(1, 2, 3)},
+		{"prefix", 0, 2, ArrayWith(0, 1)},
+		{"suffix", 4, 6, ArrayWith(4, 5)},
+		{"negative-start", -3, 2, ArrayWith(0, 1)},
+		{"end-beyond-length", 4, 100, ArrayWith(4, 5)},
+		{"start-beyond-length", 10, 20, ArrayNew()},
+		{"start-equals-end", 3, 3, ArrayNew()},
+		{"start-after-end", 4, 2, ArrayNew()},
+		{"all", 0, 6, arr},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := arr.Slice(test.start, test.end)
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+		})
+	}
+	t.Run("preserves-module", func(t *testing.T) {
+		list := TESTOBJ.At("module-v1:list").AsArray()
+		got := list.Slice(1, 3)
+		if got.module != list.module {
+			t.Fatalf("expected module %q, got %q", list.module, got.module)
+		}
+		if got.At(0) != list.At(1) || got.At(1) != list.At(2) {
+			t.Fatal("slice should share elements with the original")
+		}
+		if empty := list.Slice(3, 1); empty.module != list.module {
+			t.Fatalf("expected module %q, got %q",
+				list.module, empty.module)
+		}
+	})
+}
+
+func TestArrayReverse(t *testing.T) {
+	cases := []struct {
+		name     string
+		arr      *Array
+		expected *Array
+	}{
+		{"empty", ArrayNew(), ArrayNew()},
+		{"single", ArrayWith(1), ArrayWith(1)},
+		{"even", ArrayWith(1, 2, 3, 4), ArrayWith(4, 3, 2, 1)},
+		{"odd", ArrayWith(1, 2, 3), ArrayWith(3, 2, 1)},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.arr.Reverse()
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+			got = test.arr.Transform(func(tarr *TArray) {
+				tarr.Reverse()
+			})
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+		})
+	}
+	t.Run("preserves-module", func(t *testing.T) {
+		list := TESTOBJ.At("module-v1:list").AsArray()
+		got := list.Reverse()
+		if got.module != list.module {
+			t.Fatalf("expected module %q, got %q", list.module, got.module)
+		}
+		if got.At(0) != list.At(list.Length()-1) {
+			t.Fatal("reverse should share elements with the original")
+		}
+		if !equal(got.Reverse(), list) {
+			t.Fatalf("expected: %s\ngot: %s\n", list, got.Reverse())
+		}
+	})
+}
+
+func TestArrayFilter(t *testing.T) {
+	even := func(v *Value) bool { return v.AsInt32()%2 == 0 }
+	t.Run("Array", func(t *testing.T) {
+		orig := ArrayWith(1, 2, 3, 4, 5, 6)
+		got := orig.Filter(even)
+		expected := ArrayWith(2, 4, 6)
+		if !dyn.Equal(expected, got) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		if orig.Length() != 6 {
+			t.Fatal("Filter modified the original array")
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		got := ArrayNew().Filter(even)
+		if got == nil || got.Length() != 0 {
+			t.Fatalf("expected an empty array, got %v", got)
+		}
+	})
+	t.Run("module", func(t *testing.T) {
+		list := ValueNew(ObjectWith(PairNew("module-v1:list",
+			ArrayWith(1, 2)))).
+			AsObject().At("module-v1:list").AsArray()
+		if got := list.Filter(even); got.module != "module-v1" {
+			t.Fatalf("expected module-v1, got %q", got.module)
+		}
+	})
+	t.Run("TArray", func(t *testing.T) {
+		got := ArrayWith(1, 2, 3, 4, 5, 6).Transform(func(a *TArray) {
+			a.Filter(even).Append(8)
+		})
+		expected := ArrayWith(2, 4, 6, 8)
+		if !dyn.Equal(expected, got) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+}
+
+func TestArrayMap(t *testing.T) {
+	t.Run("transform", func(t *testing.T) {
+		orig := ArrayWith(1, 2, 3)
+		got := orig.Map(func(v *Value) *Value {
+			return ValueNew(v.AsInt32() + 1)
+		})
+		expected := ArrayWith(2, 3, 4)
+		if !dyn.Equal(expected, got) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		if !dyn.Equal(ArrayWith(1, 2, 3), orig) {
+			t.Fatal("Map modified the original array")
+		}
+	})
+	t.Run("nil", func(t *testing.T) {
+		got := ArrayWith(1, 2, 3).Map(func(v *Value) *Value {
+			if v.AsInt32() == 2 {
+				return nil
+			}
+			return v
+		})
+		if got.Length() != 3 || !got.At(1).IsNull() {
+			t.Fatalf("expected null in place of nil, got %s", got)
+		}
+	})
+	t.Run("module", func(t *testing.T) {
+		list := ValueNew(ObjectWith(PairNew("module-v1:list",
+			ArrayWith(1)))).
+			AsObject().At("module-v1:list").AsArray()
+		got := list.Map(func(v *Value) *Value {
+			return ValueNew(ObjectWith(PairNew("leaf", v)))
+		})
+		obj := got.At(0).AsObject()
+		if got.module != "module-v1" || obj.module != "module-v1" {
+			t.Fatalf("expected module-v1, got %q and %q",
+				got.module, obj.module)
+		}
+	})
+}
+
+func TestArrayCompact(t *testing.T) {
+	t.Run("sparse", func(t *testing.T) {
+		sparse := ArrayNew().Assoc(5, "foo")
+		if sparse.Length() != 6 || !sparse.At(0).IsNull() {
+			t.Fatalf("expected null padded array, got %s", sparse)
+		}
+		got := sparse.Compact()
+		expected := ArrayWith("foo")
+		if !dyn.Equal(expected, got) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("preserves order and module", func(t *testing.T) {
+		list := ValueNew(ObjectWith(PairNew("module-v1:list",
+			ArrayWith(1, nil, 2, nil, 3)))).
+			AsObject().At("module-v1:list").AsArray()
+		got := list.Compact()
+		if got.module != "module-v1" {
+			t.Fatalf("expected module-v1, got %q", got.module)
+		}
+		if got.String() != "[1,2,3]" {
+			t.Fatalf("expected: [1,2,3]\ngot: %s\n", got)
+		}
+	})
+}
+
 func TestArraySort(t *testing.T) {
 	expected := ArrayWith(1, 2, 3, 4, 5, 6, 7, 8)
 	got := ArrayWith(8, 7, 6, 5, 4, 3, 2, 1).Sort()
@@ -281,6 +583,163 @@ func TestArraySortCompare(t *testing.T) {
 	}
 }
 
+func TestArrayBinarySearch(t *testing.T) {
+	sorted := ArrayWith(5, 1, 9, 3, 7, 3).Sort()
+	cases := []struct {
+		name   string
+		target *Value
+		index  int
+		found  bool
+	}{
+		{"first", ValueNew(1), 0, true},
+		{"duplicate", ValueNew(3), 1, true},
+		{"last", ValueNew(9), 5, true},
+		{"before", ValueNew(0), 0, false},
+		{"between", ValueNew(4), 3, false},
+		{"after", ValueNew(10), 6, false},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			index, found := sorted.BinarySearch(test.target, nil)
+			if index != test.index || found != test.found {
+				t.Fatalf("expected: %d, %v\ngot: %d, %v\n",
+					test.index, test.found, index, found)
+			}
+		})
+	}
+	t.Run("compare", func(t *testing.T) {
+		byName := func(a, b *Value) int {
+			return strings.Compare(
+				a.AsObject().At("name").AsString(),
+				b.AsObject().At("name").AsString())
+		}
+		entry := func(name string) *Object {
+			return ObjectWith(PairNew("name", name))
+		}
+		arr := ArrayWith(entry("c"), entry("a"), entry("b")).
+			Sort(Compare(byName))
+		index, found := arr.BinarySearch(ValueNew(entry("b")), byName)
+		if index != 1 || !found {
+			t.Fatalf("expected: 1, true\ngot: %d, %v\n", index, found)
+		}
+		index, found = arr.BinarySearch(ValueNew(entry("bb")), byName)
+		if index != 2 || found {
+			t.Fatalf("expected: 2, false\ngot: %d, %v\n", index, found)
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		index, found := ArrayNew().BinarySearch(ValueNew(1), nil)
+		if index != 0 || found {
+			t.Fatalf("expected: 0, false\ngot: %d, %v\n", index, found)
+		}
+	})
+}
+
+func TestArraySortStableByKey(t *testing.T) {
+	entry := func(name string, prio int) *Object {
+		return ObjectWith(PairNew("name", name), PairNew("prio", prio))
+	}
+	orig := ArrayWith(
+		entry("a", 2), entry("b", 1), entry("c", 2),
+		entry("d", 1), entry("e", 2), entry("f", 0),
+		entry("g", 1), entry("h", 2), entry("i", 0))
+	expected := ArrayWith(
+		entry("f", 0), entry("i", 0),
+		entry("b", 1), entry("d", 1), entry("g", 1),
+		entry("a", 2), entry("c", 2), entry("e", 2), entry("h", 2))
+	var calls int
+	byPrio := SortByKey(func(v *Value) *Value {
+		calls++
+		return v.AsObject().At("prio")
+	})
+	got := orig.Sort(byPrio, StableSort())
+	if !dyn.Equal(expected, got) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	if calls != orig.Length() {
+		t.Fatalf("expected %d key extractions, got %d",
+			orig.Length(), calls)
+	}
+	got = orig.Transform(func(arr *TArray) {
+		arr.Sort(StableSort(), byPrio)
+	})
+	if !dyn.Equal(expected, got) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	t.Run("key with compare", func(t *testing.T) {
+		expected := ArrayWith(
+			entry("a", 2), entry("c", 2), entry("e", 2), entry("h", 2),
+			entry("b", 1), entry("d", 1), entry("g", 1),
+			entry("f", 0), entry("i", 0))
+		got := orig.Sort(byPrio, StableSort(),
+			Compare(func(a, b *Value) int {
+				return b.Compare(a)
+			}))
+		if !dyn.Equal(expected, got) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+}
+
+func TestArraySortCompareChain(t *testing.T) {
+	entry := func(typ, name string) map[string]interface{} {
+		return map[string]interface{}{"type": typ, "name": name}
+	}
+	byKey := func(key string) func(a, b *Value) int {
+		return func(a, b *Value) int {
+			return a.AsObject().At(key).Compare(b.AsObject().At(key))
+		}
+	}
+	expected := ArrayWith(
+		entry("dataplane", "dp0s1"),
+		entry("dataplane", "dp0s2"),
+		entry("loopback", "lo"),
+		entry("loopback", "lo1"),
+	)
+	got := ArrayWith(
+		entry("loopback", "lo1"),
+		entry("dataplane", "dp0s2"),
+		entry("loopback", "lo"),
+		entry("dataplane", "dp0s1"),
+	).Sort(CompareChain(byKey("type"), byKey("name")))
+	if !dyn.Equal(expected, got) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+}
+
+func TestArraySelectIndices(t *testing.T) {
+	list := ArrayFrom([]interface{}{
+		map[string]interface{}{"name": "foo", "value": 1},
+		map[string]interface{}{"name": "bar", "value": 2},
+		map[string]interface{}{"name": "foo", "value": 3},
+		"not-an-object",
+	})
+	t.Run("list", func(t *testing.T) {
+		got := list.SelectIndices("name", "foo")
+		if !reflect.DeepEqual(got, []int{0, 2}) {
+			t.Fatalf("expected: %v\ngot: %v\n", []int{0, 2}, got)
+		}
+	})
+	t.Run("list non-string key", func(t *testing.T) {
+		got := list.SelectIndices("value", "2")
+		if !reflect.DeepEqual(got, []int{1}) {
+			t.Fatalf("expected: %v\ngot: %v\n", []int{1}, got)
+		}
+	})
+	t.Run("list no match", func(t *testing.T) {
+		got := list.SelectIndices("name", "baz")
+		if len(got) != 0 {
+			t.Fatalf("expected no matches, got: %v\n", got)
+		}
+	})
+	t.Run("leaf-list", func(t *testing.T) {
+		got := ArrayWith(1, 2, 1, 3).SelectIndicesLeafList("1")
+		if !reflect.DeepEqual(got, []int{0, 2}) {
+			t.Fatalf("expected: %v\ngot: %v\n", []int{0, 2}, got)
+		}
+	})
+}
+
 func TestTArray(t *testing.T) {
 	list := TESTOBJ.At("module-v1:leaf-list").AsArray()
 	t.Run("Append", func(t *testing.T) {
@@ -364,3 +823,373 @@ func TestTArray(t *testing.T) {
 		})
 	})
 }
+
+func TestArrayMergePolicies(t *testing.T) {
+	build := func(leafList, list []interface{}) *Value {
+		return ValueNew(ObjectFrom(map[string]interface{}{
+			"module-v1:container": map[string]interface{}{
+				"leaf-list": leafList,
+			},
+			"module-v1:list": list,
+		}))
+	}
+	orig := build(
+		[]interface{}{1, 2, 3},
+		[]interface{}{
+			map[string]interface{}{"name": "foo", "a": 1},
+			map[string]interface{}{"name": "bar", "a": 2},
+		})
+	new := build(
+		[]interface{}{4, 5},
+		[]interface{}{
+			map[string]interface{}{"name": "bar", "b": 3},
+			map[string]interface{}{"name": "baz", "b": 4},
+		})
+	byName := ArrayByKey(func(v *Value) interface{} {
+		return v.ToObject(ObjectNew()).At("name")
+	})
+	cases := []struct {
+		name     string
+		policy   ArrayMergePolicy
+		expected *Value
+	}{
+		{
+			name:   "by-index",
+			policy: ArrayByIndex,
+			expected: build(
+				[]interface{}{4, 5, 3},
+				[]interface{}{
+					map[string]interface{}{"name": "bar", "a": 1, "b": 3},
+					map[string]interface{}{"name": "baz", "a": 2, "b": 4},
+				}),
+		},
+		{
+			name:     "replace",
+			policy:   ArrayReplace,
+			expected: new,
+		},
+		{
+			name:   "append",
+			policy: ArrayAppend,
+			expected: build(
+				[]interface{}{1, 2, 3, 4, 5},
+				[]interface{}{
+					map[string]interface{}{"name": "foo", "a": 1},
+					map[string]interface{}{"name": "bar", "a": 2},
+					map[string]interface{}{"name": "bar", "b": 3},
+					map[string]interface{}{"name": "baz", "b": 4},
+				}),
+		},
+		{
+			name:   "union",
+			policy: ArrayUnion,
+			expected: build(
+				[]interface{}{1, 2, 3, 4, 5},
+				[]interface{}{
+					map[string]interface{}{"name": "foo", "a": 1},
+					map[string]interface{}{"name": "bar", "a": 2},
+					map[string]interface{}{"name": "bar", "b": 3},
+					map[string]interface{}{"name": "baz", "b": 4},
+				}),
+		},
+		{
+			name:   "by-key",
+			policy: byName,
+			expected: build(
+				[]interface{}{1, 2, 3, 4, 5},
+				[]interface{}{
+					map[string]interface{}{"name": "foo", "a": 1},
+					map[string]interface{}{"name": "bar", "a": 2, "b": 3},
+					map[string]interface{}{"name": "baz", "b": 4},
+				}),
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := orig.MergeArrays(new, test.policy)
+			if !dyn.Equal(test.expected, got) {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, got)
+			}
+		})
+	}
+}
+
+func TestArrayMergeUnion(t *testing.T) {
+	orig := TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith(3, 1, 2))
+	new := TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith(2, 4, 3, 4, 5))
+	expected := TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith(3, 1, 2, 4, 5))
+	got := orig.MergeArrays(new, ArrayUnion)
+	if !equal(expected, got) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	got = orig.MergeArrays(orig, ArrayUnion)
+	if !equal(orig, got) {
+		t.Fatalf("expected: %s\ngot: %s\n", orig, got)
+	}
+	concat := orig.Merge(TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith(3, 1, 2, 7)))
+	expected = TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith(3, 1, 2, 7))
+	if !equal(expected, concat) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, concat)
+	}
+}
+
+func TestValueMergeUsesArrayByIndex(t *testing.T) {
+	orig := ValueNew(ArrayWith(1, 2, 3))
+	new := ValueNew(ArrayWith(4))
+	if !dyn.Equal(orig.Merge(new), orig.MergeArrays(new, ArrayByIndex)) {
+		t.Fatal("Merge and MergeArrays(ArrayByIndex) differ")
+	}
+}
+
+func TestArrayInsert(t *testing.T) {
+	arr := ArrayWith("a", "b", "c")
+	cases := []struct {
+		name     string
+		index    int
+		expected *Array
+	}{
+		{"start", 0, ArrayWith("x", "a", "b", "c")},
+		{"middle", 1, ArrayWith("a", "x", "b", "c")},
+		{"end", 3, ArrayWith("a", "b", "c", "x")},
+		{"beyond-end", 5, ArrayWith("a", "b", "c", nil, nil, "x")},
+		{"negative", -1, ArrayWith("a", "b", "x", "c")},
+		{"negative-start", -3, ArrayWith("x", "a", "b", "c")},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := arr.Insert(test.index, "x")
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+			if !equal(arr, ArrayWith("a", "b", "c")) {
+				t.Fatal("original array was modified")
+			}
+		})
+	}
+	t.Run("negative-before-start", func(t *testing.T) {
+		_, err := try.Apply(arr.Insert, -4, "x")
+		if err == nil {
+			t.Fatal("expected a panic for an index before the start")
+		}
+	})
+	t.Run("module", func(t *testing.T) {
+		list := TreeNew().
+			Assoc("/module-v1:list", ArrayWith(
+				ObjectWith(PairNew("key", "a")))).
+			At("/module-v1:list").AsArray()
+		got := list.Insert(0, ObjectWith(PairNew("key", "b")))
+		if got.At(0).AsObject().At("module-v1:key").ToString() != "b" {
+			t.Fatalf("inserted value not adapted to module: %s", got)
+		}
+	})
+}
+
+func TestArrayRemoveAt(t *testing.T) {
+	arr := ArrayWith("a", "b", "c")
+	got := arr.RemoveAt(1)
+	if !equal(got, ArrayWith("a", "c")) {
+		t.Fatalf("expected: %s\ngot: %s\n", ArrayWith("a", "c"), got)
+	}
+	if !equal(got.Insert(1, "b"), arr) {
+		t.Fatal("Insert didn't restore the removed element")
+	}
+	t.Run("negative", func(t *testing.T) {
+		got := arr.RemoveAt(-1)
+		if !equal(got, ArrayWith("a", "b")) {
+			t.Fatalf("expected: %s\ngot: %s\n", ArrayWith("a", "b"), got)
+		}
+		if !equal(arr.RemoveAt(-2).Insert(-1, "b"), arr) {
+			t.Fatal("Insert didn't restore the removed element")
+		}
+		for _, index := range []int{-4, 3} {
+			_, err := try.Apply(arr.RemoveAt, index)
+			if err == nil {
+				t.Fatalf("expected a panic for index %d", index)
+			}
+		}
+	})
+}
+
+func TestArrayConcat(t *testing.T) {
+	arr := ArrayWith("a", "b")
+	t.Run("arrays", func(t *testing.T) {
+		got := arr.Concat(ArrayWith("c"), ArrayNew(), nil, ArrayWith("d", "e"))
+		expected := ArrayWith("a", "b", "c", "d", "e")
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		if !equal(arr, ArrayWith("a", "b")) {
+			t.Fatalf("receiver was modified: %s", arr)
+		}
+	})
+	t.Run("nothing", func(t *testing.T) {
+		if got := arr.Concat(); got != arr {
+			t.Fatalf("expected receiver, got: %s", got)
+		}
+		if got := arr.Concat(nil, ArrayNew()); got != arr {
+			t.Fatalf("expected receiver, got: %s", got)
+		}
+	})
+	t.Run("modules", func(t *testing.T) {
+		tree := TreeNew().
+			Assoc("/module-v1:list", ArrayWith(
+				ObjectWith(PairNew("key", "a")))).
+			Assoc("/module-v2:list", ArrayWith(
+				ObjectWith(PairNew("key", "b"))))
+		v1 := tree.At("/module-v1:list").AsArray()
+		v2 := tree.At("/module-v2:list").AsArray()
+		got := v1.Concat(v2)
+		if got.Length() != 2 {
+			t.Fatalf("expected 2 elements, got: %s", got)
+		}
+		entry := got.At(1).AsObject()
+		if !entry.Contains("module-v2:key") || entry.Contains("module-v1:key") {
+			t.Fatalf("element not adapted to receiver's module: %s", got)
+		}
+		expected := TreeNew().
+			Assoc("/module-v1:list", ArrayWith(
+				ObjectWith(PairNew("key", "a")),
+				ObjectWith(PairNew("module-v2:key", "b"))))
+		merged := TreeNew().Assoc("/module-v1:list", got)
+		if !merged.Equal(expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, merged)
+		}
+	})
+}
+
+func TestArrayWithout(t *testing.T) {
+	arr := ArrayWith("a", "b", "c", "d", "e")
+	tests := []struct {
+		name     string
+		indices  []int
+		expected *Array
+	}{
+		{"none", nil, arr},
+		{"single", []int{1}, ArrayWith("a", "c", "d", "e")},
+		{"original indices", []int{1, 2, 4}, ArrayWith("a", "d")},
+		{"unordered", []int{4, 0, 2}, ArrayWith("b", "d")},
+		{"duplicates", []int{3, 3, 3}, ArrayWith("a", "b", "c", "e")},
+		{"negative", []int{-1, 0}, ArrayWith("b", "c", "d")},
+		{"out of range", []int{5, -6, 1}, ArrayWith("a", "c", "d", "e")},
+		{"all", []int{0, 1, 2, 3, 4}, ArrayNew()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := arr.Without(test.indices...)
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+		})
+	}
+	t.Run("module", func(t *testing.T) {
+		list := TreeNew().
+			Assoc("/module-v1:leaf-list", ArrayWith("a", "b")).
+			At("/module-v1:leaf-list").AsArray()
+		got := list.Without(0)
+		if got.module != list.module {
+			t.Fatalf("expected module %q, got %q", list.module, got.module)
+		}
+	})
+}
+
+func TestArrayUnique(t *testing.T) {
+	t.Run("values", func(t *testing.T) {
+		arr := ArrayWith("b", "a", "b", 1, "c", "a", 1, "1",
+			ObjectWith(PairNew("k", 1)), ObjectWith(PairNew("k", 1)))
+		got := arr.Unique()
+		expected := ArrayWith("b", "a", 1, "c", "1",
+			ObjectWith(PairNew("k", 1)))
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		if arr.Length() != 10 {
+			t.Fatalf("receiver was modified: %s", arr)
+		}
+	})
+	t.Run("no duplicates", func(t *testing.T) {
+		arr := ArrayWith(1, 2, 3)
+		if got := arr.Unique(); got != arr {
+			t.Fatalf("expected receiver, got: %s", got)
+		}
+	})
+	t.Run("by key", func(t *testing.T) {
+		list := TreeNew().
+			Assoc("/module-v1:list", ArrayWith(
+				ObjectWith(PairNew("name", "a"), PairNew("val", 1)),
+				ObjectWith(PairNew("name", "b"), PairNew("val", 2)),
+				ObjectWith(PairNew("name", "a"), PairNew("val", 3)),
+				ObjectWith(PairNew("val", 4)),
+				ObjectWith(PairNew("val", 5)))).
+			At("/module-v1:list").AsArray()
+		got := list.Unique(UniqueByKey(func(v *Value) *Value {
+			return v.AsObject().At("name")
+		}))
+		var vals []uint32
+		got.Range(func(v *Value) {
+			vals = append(vals, v.AsObject().At("val").ToUint32())
+		})
+		expected := []uint32{1, 2, 4}
+		if !reflect.DeepEqual(vals, expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, vals)
+		}
+		if got.module != list.module {
+			t.Fatalf("expected module %q, got %q", list.module, got.module)
+		}
+	})
+}
+
+func TestArrayGroupBy(t *testing.T) {
+	list := TreeNew().
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("name", "a"), PairNew("type", "x")),
+			ObjectWith(PairNew("name", "b"), PairNew("type", "y")),
+			ObjectWith(PairNew("name", "c"), PairNew("type", "x")),
+			ObjectWith(PairNew("name", "d")),
+			ObjectWith(PairNew("name", "e"), PairNew("type", "m:z")))).
+		At("/module-v1:list").AsArray()
+	groups := list.GroupBy(func(v *Value) string {
+		typ, found := v.AsObject().Find("type")
+		if !found {
+			return ""
+		}
+		return typ.ToString()
+	})
+	expected := map[string][]string{
+		"x":   {"a", "c"},
+		"y":   {"b"},
+		"":    {"d"},
+		"m:z": {"e"},
+	}
+	if groups.Length() != len(expected) {
+		t.Fatalf("expected %d groups, got %d: %s",
+			len(expected), groups.Length(), groups)
+	}
+	for key, names := range expected {
+		group := groups.At(key)
+		if group == nil {
+			t.Fatalf("missing group %q", key)
+		}
+		arr := group.AsArray()
+		if arr.module != list.module {
+			t.Fatalf("expected module %q, got %q",
+				list.module, arr.module)
+		}
+		var got []string
+		arr.Range(func(v *Value) {
+			got = append(got, v.AsObject().At("name").ToString())
+		})
+		if !reflect.DeepEqual(got, names) {
+			t.Fatalf("group %q expected: %v\ngot: %v\n",
+				key, names, got)
+		}
+	}
+	if ArrayNew().GroupBy(func(*Value) string { return "" }).Length() != 0 {
+		t.Fatal("expected no groups for an empty array")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// BigInt is an arbitrary precision integer. It allows integers that do
+// not fit in 64 bits to be stored in a Value. Like int64 and uint64
+// values, BigInts are encoded as quoted strings in RFC7951. The zero
+// BigInt is 0.
+type BigInt struct {
+	i *big.Int
+}
+
+// BigIntNew creates a BigInt holding a copy of the supplied integer.
+func BigIntNew(i *big.Int) BigInt {
+	return BigInt{i: new(big.Int).Set(i)}
+}
+
+func (b BigInt) int() *big.Int {
+	if b.i == nil {
+		return new(big.Int)
+	}
+	return b.i
+}
+
+// Int returns a copy of the integer held by the BigInt.
+func (b BigInt) Int() *big.Int {
+	return new(big.Int).Set(b.int())
+}
+
+// RFC7951String returns the integer in base 10.
+func (b BigInt) RFC7951String() string {
+	return b.int().String()
+}
+
+// String returns the integer in base 10.
+func (b BigInt) String() string {
+	return b.int().String()
+}
+
+// MarshalRFC7951 returns the integer encoded as a quoted string.
+func (b BigInt) MarshalRFC7951() ([]byte, error) {
+	return []byte("\"" + b.int().String() + "\""), nil
+}
+
+// Equal implements equality for BigInts.
+func (b BigInt) Equal(other interface{}) bool {
+	ob, isBigInt := other.(BigInt)
+	return isBigInt && b.int().Cmp(ob.int()) == 0
+}
+
+// Compare implements comparison for BigInts. A BigInt may also be
+// compared with any of the other numeric types by value.
+func (b BigInt) Compare(other interface{}) int {
+	if ob, isBigInt := other.(BigInt); isBigInt {
+		return b.int().Cmp(ob.int())
+	}
+	n, isNumeric := numericValue(other)
+	if !isNumeric {
+		panic(fmt.Errorf("cannot compare big integer with %T", other))
+	}
+	return new(big.Float).SetInt(b.int()).Cmp(n)
+}
+
+var (
+	minInt64  = big.NewInt(-1 << 63)
+	maxUint64 = new(big.Int).SetUint64(1<<64 - 1)
+)
+
+// bigIntData returns the representation used to store i in a
+// Value. Integers that fit in 64 bits are stored in the same way as
+// any other integer so that equality is consistent regardless of how
+// the value was created.
+func bigIntData(i *big.Int) interface{} {
+	switch {
+	case i.Sign() >= 0 && i.Cmp(maxUint64) <= 0:
+		return i.Uint64()
+	case i.Sign() < 0 && i.Cmp(minInt64) >= 0:
+		return inferInt64Type(i.Int64())
+	default:
+		return BigIntNew(i)
+	}
+}
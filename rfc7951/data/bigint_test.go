@@ -0,0 +1,164 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const hugeInt = "123456789012345678901234567890"
+
+func mustBigInt(t *testing.T, s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		t.Fatalf("invalid big.Int %s", s)
+	}
+	return i
+}
+
+func TestBigIntValueNew(t *testing.T) {
+	t.Run("huge", func(t *testing.T) {
+		v := ValueNew(mustBigInt(t, hugeInt))
+		if !v.IsBigInt() {
+			t.Fatalf("expected BigInt, got %T", v.data)
+		}
+		if v.RFC7951String() != hugeInt {
+			t.Fatalf("expected %s, got %s", hugeInt, v.RFC7951String())
+		}
+	})
+	t.Run("fits in 64 bits", func(t *testing.T) {
+		if !equal(ValueNew(big.NewInt(-5)), ValueNew(int64(-5))) {
+			t.Fatal("small negative big.Int should be an int64")
+		}
+		if !equal(ValueNew(big.NewInt(5)), ValueNew(uint64(5))) {
+			t.Fatal("small positive big.Int should be a uint64")
+		}
+	})
+	t.Run("copies", func(t *testing.T) {
+		i := mustBigInt(t, hugeInt)
+		v := ValueNew(BigIntNew(i))
+		i.SetInt64(0)
+		if v.RFC7951String() != hugeInt {
+			t.Fatal("BigInt shares storage with its source")
+		}
+	})
+	t.Run("zero", func(t *testing.T) {
+		if !equal(ValueNew(BigInt{}), ValueNew(uint64(0))) {
+			t.Fatal("the zero BigInt should be 0")
+		}
+		var b BigInt
+		if b.String() != "0" || !b.Equal(BigIntNew(new(big.Int))) {
+			t.Fatalf("expected: 0\ngot: %s\n", b)
+		}
+	})
+	t.Run("compare", func(t *testing.T) {
+		huge := BigIntNew(mustBigInt(t, hugeInt))
+		for _, other := range []interface{}{
+			uint64(1) << 63, int64(-1) << 62, uint32(1), int32(-1), 1.5,
+		} {
+			if huge.Compare(other) <= 0 {
+				t.Fatalf("expected %s to be greater than %v", huge, other)
+			}
+		}
+		neg := BigIntNew(mustBigInt(t, "-"+hugeInt))
+		if neg.Compare(int64(-1)<<62) >= 0 || neg.Compare(huge) >= 0 {
+			t.Fatalf("expected %s to be less", neg)
+		}
+		if ValueNew(huge).Compare(ValueNew(uint64(1))) <= 0 {
+			t.Fatal("expected a huge Value to be greater than 1")
+		}
+	})
+	t.Run("equal", func(t *testing.T) {
+		if !equal(ValueNew(mustBigInt(t, hugeInt)),
+			ValueNew(mustBigInt(t, hugeInt))) {
+			t.Fatal("equal BigInts should be Equal")
+		}
+		if !ValueNew(mustBigInt(t, hugeInt)).EqualNumeric(
+			ValueNew(mustBigInt(t, hugeInt))) {
+			t.Fatal("equal BigInts should be EqualNumeric")
+		}
+	})
+}
+
+func TestBigIntMarshal(t *testing.T) {
+	tree := TreeNew().Assoc("/module-v1:counter", mustBigInt(t, hugeInt))
+	got, err := tree.MarshalRFC7951()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"module-v1:counter":"` + hugeInt + `"}`
+	if string(got) != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+}
+
+func TestValueAsBigInt(t *testing.T) {
+	cases := []struct {
+		name     string
+		val      *Value
+		expected string
+		err      bool
+	}{
+		{"big", ValueNew(mustBigInt(t, hugeInt)), hugeInt, false},
+		{"string", ValueNew(hugeInt), hugeInt, false},
+		{"negative string", ValueNew("-" + hugeInt), "-" + hugeInt, false},
+		{"uint32", ValueNew(10), "10", false},
+		{"int64", ValueNew(int64(-10)), "-10", false},
+		{"non-numeric string", ValueNew("foo"), "", true},
+		{"float", ValueNew(1.5), "", true},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.val.AsBigInt()
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestDecoderParseBigInts(t *testing.T) {
+	in := `{"module-v1:big":"` + hugeInt +
+		`","module-v1:neg":"-` + hugeInt +
+		`","module-v1:small":"10"}`
+	t.Run("default", func(t *testing.T) {
+		var tree Tree
+		err := NewDecoder(strings.NewReader(in)).Decode(&tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tree.At("/module-v1:big").IsString() {
+			t.Fatal("expected huge integer to remain a string")
+		}
+	})
+	t.Run("ParseBigInts", func(t *testing.T) {
+		var tree Tree
+		err := NewDecoder(strings.NewReader(in), ParseBigInts()).
+			Decode(&tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range []string{"/module-v1:big", "/module-v1:neg"} {
+			if !tree.At(path).IsBigInt() {
+				t.Fatalf("expected %s to be a BigInt", path)
+			}
+		}
+		if tree.At("/module-v1:small").AsUint64() != 10 {
+			t.Fatal("small integers should be unaffected")
+		}
+	})
+}
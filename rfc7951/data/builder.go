@@ -0,0 +1,221 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"fmt"
+
+	"jsouthworth.net/go/immutable/hashmap"
+	"jsouthworth.net/go/immutable/vector"
+)
+
+// TreeBuilder incrementally constructs a Tree from instance-identifier
+// and value pairs. The builder keeps the containers along the most
+// recently used path open, so consecutive paths that share a prefix only
+// rebuild the part of the path that changed. Feeding paths in sorted
+// order yields the best performance, but any order produces the same
+// Tree as the equivalent sequence of Tree.Assoc calls.
+//
+// A TreeBuilder is mutable and must not be shared among goroutines.
+type TreeBuilder struct {
+	stack []treeBuilderFrame
+	path  []*nodeID
+}
+
+type treeBuilderFrame struct {
+	key   string
+	obj   *TObject
+	preds *predicates
+	list  *Array
+	index int
+}
+
+// TreeBuilderNew creates a new TreeBuilder that builds an empty tree.
+func TreeBuilderNew() *TreeBuilder {
+	return TreeBuilderFrom(TreeNew())
+}
+
+// TreeBuilderFrom creates a new TreeBuilder that builds on top of
+// the supplied tree.
+func TreeBuilderFrom(t *Tree) *TreeBuilder {
+	return &TreeBuilder{
+		stack: []treeBuilderFrame{
+			{obj: t.Root().AsObject().asTransient()},
+		},
+	}
+}
+
+// Assoc associates the value provided at the location pointed to by
+// the instance-identifier.
+func (b *TreeBuilder) Assoc(instanceID string, value interface{}) *TreeBuilder {
+	return b.assoc(InstanceIDNew(instanceID), ValueNew(value))
+}
+
+func (b *TreeBuilder) assoc(id *InstanceID, v *Value) *TreeBuilder {
+	parents, leaf := id.ids[:len(id.ids)-1], id.ids[len(id.ids)-1]
+	var common int
+	for common < len(parents) && common < len(b.path) &&
+		b.path[common].equal(parents[common]) {
+		common++
+	}
+	for len(b.path) > common {
+		b.pop()
+	}
+	for _, nid := range parents[common:] {
+		b.push(nid)
+	}
+	b.assocLeaf(leaf, v)
+	return b
+}
+
+// Finish returns the Tree built so far. The builder may continue to
+// be used after Finish, subsequent calls build on the returned Tree.
+func (b *TreeBuilder) Finish() *Tree {
+	for len(b.path) > 0 {
+		b.pop()
+	}
+	root := b.stack[0].obj.asPersistent()
+	b.stack[0].obj = root.asTransient()
+	return TreeFromObject(root)
+}
+
+func (b *TreeBuilder) top() *treeBuilderFrame {
+	return &b.stack[len(b.stack)-1]
+}
+
+func (b *TreeBuilder) push(nid *nodeID) {
+	key := nid.prefix + ":" + nid.identifier
+	existing := b.top().obj.At(key)
+	frame := treeBuilderFrame{key: key}
+	if nid.predicates == nil {
+		frame.obj = builderObject(existing, nid.prefix).asTransient()
+	} else {
+		frame.preds = nid.predicates
+		frame.list = builderArray(existing, nid.prefix)
+		frame.index = nid.predicates.
+			computeIdentifierDefault(ValueNew(frame.list)).(int)
+		entry := ValueNew(builderObject(frame.list.At(frame.index),
+			nid.prefix))
+		frame.obj = nid.predicates.modifyMatchCriteria(entry).
+			AsObject().asTransient()
+	}
+	b.stack = append(b.stack, frame)
+	b.path = append(b.path, nid)
+}
+
+func (b *TreeBuilder) pop() {
+	frame := b.top()
+	v := ValueNew(frame.obj.asPersistent())
+	if frame.preds != nil {
+		v = ValueNew(frame.list.Assoc(frame.index, v))
+	}
+	b.stack = b.stack[:len(b.stack)-1]
+	b.path = b.path[:len(b.path)-1]
+	b.top().obj.Assoc(frame.key, v)
+}
+
+func (b *TreeBuilder) assocLeaf(nid *nodeID, v *Value) {
+	key := nid.prefix + ":" + nid.identifier
+	top := b.top()
+	if nid.predicates == nil {
+		top.obj.Assoc(key, v)
+		return
+	}
+	arr := builderArray(top.obj.At(key), nid.prefix)
+	v = nid.predicates.modifyMatchCriteria(v)
+	idx := nid.predicates.computeIdentifierDefault(ValueNew(arr)).(int)
+	top.obj.Assoc(key, arr.Assoc(idx, v))
+}
+
+func builderObject(existing *Value, module string) *Object {
+	if existing != nil {
+		if obj := existing.ToObject(); obj != nil {
+			return obj
+		}
+	}
+	return &Object{store: hashmap.Empty(), module: module}
+}
+
+func builderArray(existing *Value, module string) *Array {
+	if existing != nil {
+		if arr := existing.ToArray(); arr != nil {
+			return arr
+		}
+	}
+	return &Array{store: vector.Empty(), module: module}
+}
+
+// ListBuilder constructs the entries of a list, ensuring that every
+// entry contains the list's key leaves so that it can be found by an
+// instance-identifier's key predicates. Only the presence of the keys
+// is checked, the builder has no further knowledge of the schema. Keys
+// must be named as they are in the entries, usually without a module
+// since entries are adapted to the list's module when they are
+// associated into a tree.
+//
+// A ListBuilder is mutable and must not be shared among goroutines.
+type ListBuilder struct {
+	keys    []string
+	entries *TArray
+}
+
+// ListBuilderNew creates a new ListBuilder for a list with the
+// supplied key leaves.
+func ListBuilderNew(keys ...string) *ListBuilder {
+	return &ListBuilder{
+		keys:    keys,
+		entries: ArrayNew().asTransient(),
+	}
+}
+
+// Entry appends an entry with the supplied members to the list, as
+// ObjectWith would construct it. Entry panics if the entry is missing
+// a key leaf, see Add.
+func (b *ListBuilder) Entry(pairs ...Pair) *ListBuilder {
+	err := b.Add(ObjectWith(pairs...))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Add appends the entry to the list. An error is returned, and the
+// entry isn't added, if it is missing any of the list's key leaves or
+// a key isn't a leaf.
+func (b *ListBuilder) Add(entry *Object) error {
+	for _, key := range b.keys {
+		v, found := entry.Find(key)
+		switch {
+		case !found:
+			return fmt.Errorf("list entry %s is missing key %q",
+				entryString(entry), key)
+		case v.IsContainer():
+			return fmt.Errorf("list entry %s key %q is not a leaf",
+				entryString(entry), key)
+		}
+	}
+	b.entries.Append(entry)
+	return nil
+}
+
+// entryString returns the entry encoded with its members in a stable
+// order so that errors describing it are reproducible.
+func entryString(entry *Object) string {
+	out, err := entry.MarshalCanonical()
+	if err != nil {
+		return entry.String()
+	}
+	return string(out)
+}
+
+// Finish returns the list built so far as an Array suitable for
+// associating into a tree. The builder may continue to be used after
+// Finish, subsequent entries are added to a copy of the returned Array.
+func (b *ListBuilder) Finish() *Array {
+	out := b.entries.asPersistent()
+	b.entries = out.asTransient()
+	return out
+}
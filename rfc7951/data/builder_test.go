@@ -0,0 +1,199 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTreeBuilder(t *testing.T) {
+	type pair struct {
+		path  string
+		value interface{}
+	}
+	cases := []struct {
+		name  string
+		pairs []pair
+	}{
+		{
+			name: "containers",
+			pairs: []pair{
+				{"/module-v1:foo/bar/baz", "a"},
+				{"/module-v1:foo/bar/quux", "b"},
+				{"/module-v1:foo/quuz", "c"},
+				{"/module-v2:foo/bar", 1},
+			},
+		},
+		{
+			name: "lists",
+			pairs: []pair{
+				{"/module-v1:list[name='a']/name", "a"},
+				{"/module-v1:list[name='a']/value", 1},
+				{"/module-v1:list[name='b']/name", "b"},
+				{"/module-v1:list[name='b']/value", 2},
+				{"/module-v1:list[name='b']/inner/leaf", 3},
+				{"/module-v1:other", "foo"},
+			},
+		},
+		{
+			name: "leaf-lists",
+			pairs: []pair{
+				{"/module-v1:foo/leaf-list[.='a']", "a"},
+				{"/module-v1:foo/leaf-list[.='b']", "b"},
+				{"/module-v1:foo/positional[0]", 1},
+				{"/module-v1:foo/positional[1]", 2},
+			},
+		},
+		{
+			name: "unsorted",
+			pairs: []pair{
+				{"/module-v1:foo/bar", "a"},
+				{"/module-v1:list[name='a']/value", 1},
+				{"/module-v1:baz/quux", "b"},
+				{"/module-v1:foo/baz", "c"},
+				{"/module-v1:list[name='b']/value", 2},
+				{"/module-v1:list[name='a']/other", 3},
+			},
+		},
+		{
+			name: "replace container",
+			pairs: []pair{
+				{"/module-v1:foo/bar/baz", "a"},
+				{"/module-v1:foo/bar", "b"},
+			},
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			expected := TreeNew()
+			builder := TreeBuilderNew()
+			for _, p := range test.pairs {
+				expected = expected.Assoc(p.path, p.value)
+				builder = builder.Assoc(p.path, p.value)
+			}
+			got := builder.Finish()
+			if !equal(got, expected) {
+				t.Fatalf("got:\n\t%s\nexpected:\n\t%s\ndifferences:\n\t%s\n",
+					got, expected, expected.Diff(got))
+			}
+		})
+	}
+}
+
+func TestTreeBuilderFinishContinue(t *testing.T) {
+	builder := TreeBuilderFrom(TreeNew().Assoc("/module-v1:foo", "a"))
+	first := builder.Assoc("/module-v1:bar/baz", "b").Finish()
+	second := builder.Assoc("/module-v1:bar/quux", "c").Finish()
+	expected := TreeNew().
+		Assoc("/module-v1:foo", "a").
+		Assoc("/module-v1:bar/baz", "b")
+	if !equal(first, expected) {
+		t.Fatalf("got:\n\t%s\nexpected:\n\t%s\n", first, expected)
+	}
+	expected = expected.Assoc("/module-v1:bar/quux", "c")
+	if !equal(second, expected) {
+		t.Fatalf("got:\n\t%s\nexpected:\n\t%s\n", second, expected)
+	}
+}
+
+func TestListBuilder(t *testing.T) {
+	list := ListBuilderNew("name", "type").
+		Entry(PairNew("name", "eth0"), PairNew("type", "ethernet"),
+			PairNew("mtu", 1500)).
+		Entry(PairNew("name", "lo"), PairNew("type", "loopback")).
+		Finish()
+	tree := TreeNew().Assoc("/module-v1:interfaces/interface", list)
+	got := tree.At(
+		"/module-v1:interfaces/interface[name='eth0'][type='ethernet']/mtu")
+	if !equal(got, ValueNew(1500)) {
+		t.Fatalf("expected: %d\ngot: %s\n", 1500, got)
+	}
+	if !tree.Contains("/module-v1:interfaces/interface[name='lo']") {
+		t.Fatal("expected the lo entry to be found by its key")
+	}
+
+	failures := []struct {
+		name     string
+		entry    *Object
+		expected string
+	}{
+		{"missing", ObjectWith(PairNew("name", "eth1"),
+			PairNew("typo", "ethernet")),
+			`list entry {"name":"eth1","typo":"ethernet"} is missing key "type"`},
+		{"container", ObjectWith(PairNew("name", "eth1"),
+			PairNew("type", ObjectNew())),
+			`list entry {"name":"eth1","type":{}} key "type" is not a leaf`},
+	}
+	for _, test := range failures {
+		t.Run(test.name, func(t *testing.T) {
+			b := ListBuilderNew("name", "type")
+			err := b.Add(test.entry)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.Error() != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, err)
+			}
+			if b.Finish().Length() != 0 {
+				t.Fatal("invalid entry was added")
+			}
+		})
+	}
+	t.Run("entry panics", func(t *testing.T) {
+		defer func() {
+			if _, isError := recover().(error); !isError {
+				t.Fatal("expected Entry to panic with an error")
+			}
+		}()
+		ListBuilderNew("name").Entry(PairNew("nmae", "eth0"))
+	})
+	t.Run("finish continue", func(t *testing.T) {
+		b := ListBuilderNew("name").Entry(PairNew("name", "a"))
+		first := b.Finish()
+		second := b.Entry(PairNew("name", "b")).Finish()
+		if first.Length() != 1 || second.Length() != 2 {
+			t.Fatalf("unexpected lengths %d and %d",
+				first.Length(), second.Length())
+		}
+	})
+}
+
+func genSortedPaths(n int) []string {
+	out := make([]string, 0, n*3)
+	for i := 0; i < n; i++ {
+		entry := fmt.Sprintf("/module-v1:state/interfaces/interface[name='dp0s%05d']", i)
+		out = append(out,
+			entry+"/counters/in-octets",
+			entry+"/counters/out-octets",
+			entry+"/oper-status")
+	}
+	return out
+}
+
+func BenchmarkTreeBuilderSorted(b *testing.B) {
+	paths := genSortedPaths(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		builder := TreeBuilderNew()
+		for _, path := range paths {
+			builder.Assoc(path, 1)
+		}
+		builder.Finish()
+	}
+}
+
+func BenchmarkTreeAssocSorted(b *testing.B) {
+	paths := genSortedPaths(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := TreeNew()
+		for _, path := range paths {
+			tree = tree.Assoc(path, 1)
+		}
+	}
+}
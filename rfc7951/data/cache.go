@@ -0,0 +1,129 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"bytes"
+	"sync"
+)
+
+// CachingEncoder marshals RFC7951 data remembering the encoding of
+// every object and array it marshals. Since these are immutable their
+// encoding never changes, so marshalling a tree that shares unchanged
+// subtrees with one marshalled previously reuses their encodings
+// instead of walking them again.
+//
+// Encodings are cached by the identity of the node and the module it
+// was encoded relative to, since that determines which of its keys are
+// qualified. A CachingEncoder is safe for concurrent use. Every cached
+// node is retained, along with its encoding, until Reset is called. The
+// encodings of a node and its descendants share storage, so the cache
+// holds little more than the output of each call to Marshal.
+type CachingEncoder struct {
+	mu    sync.RWMutex
+	cache map[cacheKey][]byte
+}
+
+type cacheKey struct {
+	node   interface{}
+	module string
+}
+
+// NewCachingEncoder returns a CachingEncoder with an empty cache.
+func NewCachingEncoder() *CachingEncoder {
+	return &CachingEncoder{
+		cache: make(map[cacheKey][]byte),
+	}
+}
+
+// Marshal returns the value encoded in an RFC7951 compatible way, as
+// Value.MarshalRFC7951 does.
+func (enc *CachingEncoder) Marshal(v *Value) ([]byte, error) {
+	m := &cacheMarshal{enc: enc}
+	err := v.marshalRFC7951(cachingWriter{&m.buf, m}, "")
+	m.commit()
+	// The cached encodings refer to buf, the caller must be free to
+	// modify the result.
+	return append([]byte(nil), m.buf.Bytes()...), err
+}
+
+// MarshalTree returns the tree encoded as RFC7951 data, as
+// Tree.MarshalRFC7951 does.
+func (enc *CachingEncoder) MarshalTree(t *Tree) ([]byte, error) {
+	return enc.Marshal(t.Root())
+}
+
+// Reset empties the cache.
+func (enc *CachingEncoder) Reset() {
+	enc.mu.Lock()
+	enc.cache = make(map[cacheKey][]byte)
+	enc.mu.Unlock()
+}
+
+func (enc *CachingEncoder) lookup(key cacheKey) ([]byte, bool) {
+	enc.mu.RLock()
+	out, ok := enc.cache[key]
+	enc.mu.RUnlock()
+	return out, ok
+}
+
+// cacheMarshal is the state of a single Marshal. Every node is encoded
+// once, directly to buf, and the offsets of the encodings of those not
+// already cached are recorded. Once the marshal is complete, and buf
+// will no longer move, they are cached as slices of buf.
+type cacheMarshal struct {
+	enc     *CachingEncoder
+	buf     bytes.Buffer
+	pending []pendingEncoding
+}
+
+type pendingEncoding struct {
+	key        cacheKey
+	start, end int
+}
+
+// commit adds the encodings produced by the marshal to the cache.
+func (m *cacheMarshal) commit() {
+	out := m.buf.Bytes()
+	m.enc.mu.Lock()
+	for _, p := range m.pending {
+		m.enc.cache[p.key] = out[p.start:p.end]
+	}
+	m.enc.mu.Unlock()
+	m.pending = nil
+}
+
+// cachingWriter wraps the buffer of a cacheMarshal to request that the
+// encodings of objects and arrays be taken from, or added to, the
+// encoder's cache.
+type cachingWriter struct {
+	marshalWriter
+	m *cacheMarshal
+}
+
+// marshal writes the encoding of node relative to module, using
+// encode to produce it if it isn't already cached. The members of node
+// are encoded through the cache too so they may be reused separately.
+func (w cachingWriter) marshal(
+	node interface{}, module string,
+	encode func(marshalWriter, string) error,
+) error {
+	key := cacheKey{node: node, module: module}
+	if encoding, ok := w.m.enc.lookup(key); ok {
+		_, err := w.Write(encoding)
+		return err
+	}
+	start := w.m.buf.Len()
+	if err := encode(w, module); err != nil {
+		return err
+	}
+	w.m.pending = append(w.m.pending, pendingEncoding{
+		key:   key,
+		start: start,
+		end:   w.m.buf.Len(),
+	})
+	return nil
+}
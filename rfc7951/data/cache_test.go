@@ -0,0 +1,128 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCachingEncoder(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:container/leaf", "foo").
+		Assoc("/module-v1:container/module-v2:aug/leaf", "bar").
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("key", "a")),
+			ObjectWith(PairNew("key", "b")))).
+		Assoc("/module-v1:big", uint64(1)<<40)
+	decode := func(t *testing.T, msg []byte) interface{} {
+		var out interface{}
+		if err := json.Unmarshal(msg, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	check := func(t *testing.T, enc *CachingEncoder, tree *Tree) {
+		got, err := enc.MarshalTree(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := tree.MarshalRFC7951()
+		if !reflect.DeepEqual(decode(t, got), decode(t, expected)) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	}
+
+	enc := NewCachingEncoder()
+	check(t, enc, tree)
+	cached := len(enc.cache)
+	t.Run("repeated", func(t *testing.T) {
+		check(t, enc, tree)
+		if len(enc.cache) != cached {
+			t.Fatalf("expected %d cached nodes, got %d",
+				cached, len(enc.cache))
+		}
+	})
+	t.Run("changed", func(t *testing.T) {
+		// Only the nodes on the path to the change are new.
+		check(t, enc, tree.Assoc("/module-v1:container/leaf", "baz"))
+		if len(enc.cache) != cached+2 {
+			t.Fatalf("expected %d cached nodes, got %d",
+				cached+2, len(enc.cache))
+		}
+	})
+	t.Run("module", func(t *testing.T) {
+		obj := ObjectWith(PairNew("module-v1:leaf", "foo"))
+		enc := NewCachingEncoder()
+		marshal := func(module string) string {
+			m := &cacheMarshal{enc: enc}
+			err := obj.marshalRFC7951(cachingWriter{&m.buf, m}, module)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.commit()
+			return m.buf.String()
+		}
+		outer, inner := marshal(""), marshal("module-v1")
+		if outer != `{"module-v1:leaf":"foo"}` ||
+			inner != `{"leaf":"foo"}` {
+			t.Fatalf("unexpected encodings %s and %s", outer, inner)
+		}
+		if len(enc.cache) != 2 || marshal("") != outer {
+			t.Fatalf("expected both encodings to be cached, got %d",
+				len(enc.cache))
+		}
+	})
+	t.Run("shared", func(t *testing.T) {
+		// Descendants are cached as slices of their ancestor's
+		// encoding rather than as copies.
+		enc := NewCachingEncoder()
+		check(t, enc, tree)
+		root := enc.cache[cacheKey{node: tree.Root().AsObject()}]
+		child := enc.cache[cacheKey{
+			node:   tree.At("/module-v1:container").AsObject(),
+			module: "module-v1",
+		}]
+		start := reflect.ValueOf(root).Pointer()
+		ptr := reflect.ValueOf(child).Pointer()
+		if len(child) == 0 || ptr < start ||
+			ptr+uintptr(len(child)) > start+uintptr(len(root)) {
+			t.Fatalf("%s is not a slice of %s", child, root)
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		enc.Reset()
+		if len(enc.cache) != 0 {
+			t.Fatal("expected an empty cache")
+		}
+		check(t, enc, tree)
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		enc := NewCachingEncoder()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				tree := tree.Assoc("/module-v1:container/leaf",
+					strconv.Itoa(i%2))
+				got, err := enc.MarshalTree(tree)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				expected, _ := tree.MarshalRFC7951()
+				if len(got) != len(expected) {
+					t.Errorf("expected: %s\ngot: %s\n", expected, got)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
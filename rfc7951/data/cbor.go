@@ -0,0 +1,405 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"jsouthworth.net/go/immutable/hashmap"
+	"jsouthworth.net/go/immutable/vector"
+)
+
+// CBOR major types, see RFC 7049 section 2.1.
+const (
+	cborUint byte = iota << 5
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const (
+	cborFalse   = cborSimple | 20
+	cborTrue    = cborSimple | 21
+	cborNull    = cborSimple | 22
+	cborFloat32 = cborSimple | 26
+	cborFloat64 = cborSimple | 27
+
+	// cborArg64 is the additional information signalling an eight
+	// byte argument follows the initial byte.
+	cborArg64 = 27
+)
+
+// MarshalCBOR returns the value encoded as CBOR (RFC 7049). The
+// encoding follows the same model as RFC7951, objects are encoded as
+// maps whose keys are module qualified when the module differs from
+// that of the parent and the empty value is encoded as [null].
+//
+// Numbers stored as 32 bit integers are encoded in the shortest form
+// while 64 bit integers always use an eight byte argument, this allows
+// the width to be recovered on decode in the same way RFC7951
+// distinguishes unquoted and quoted numbers.
+func (val *Value) MarshalCBOR() ([]byte, error) {
+	var buf bytes.Buffer
+	err := val.marshalCBOR(&buf, "")
+	return buf.Bytes(), err
+}
+
+func (val *Value) marshalCBOR(buf *bytes.Buffer, module string) error {
+	switch v := val.data.(type) {
+	case *Object:
+		return v.marshalCBOR(buf, module)
+	case *Array:
+		return v.marshalCBOR(buf, module)
+	case empty:
+		writeCBORHead(buf, cborArray, 1)
+		buf.WriteByte(cborNull)
+	case nil:
+		buf.WriteByte(cborNull)
+	case bool:
+		if v {
+			buf.WriteByte(cborTrue)
+		} else {
+			buf.WriteByte(cborFalse)
+		}
+	case uint32:
+		writeCBORHead(buf, cborUint, uint64(v))
+	case int32:
+		writeCBORHead(buf, cborNegInt, uint64(-1-int64(v)))
+	case uint64:
+		writeCBORHead64(buf, cborUint, v)
+	case int64:
+		if v < 0 {
+			writeCBORHead64(buf, cborNegInt, uint64(-1-v))
+		} else {
+			writeCBORHead64(buf, cborUint, uint64(v))
+		}
+	case float64:
+		buf.WriteByte(cborFloat64)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+		buf.Write(b[:])
+	case string:
+		writeCBORText(buf, v)
+	case interface {
+		RFC7951String() string
+	}:
+		writeCBORText(buf, v.RFC7951String())
+	default:
+		return fmt.Errorf("cannot marshal value of type %T", v)
+	}
+	return nil
+}
+
+func (obj *Object) marshalCBOR(buf *bytes.Buffer, module string) error {
+	writeCBORHead(buf, cborMap, uint64(obj.Length()))
+	var err error
+	obj.Range(func(k string, v *Value) bool {
+		mod, key := obj.parseKey(k)
+		if mod == module {
+			k = key
+		}
+		writeCBORText(buf, k)
+		err = v.marshalCBOR(buf, mod)
+		return err == nil
+	})
+	return err
+}
+
+func (arr *Array) marshalCBOR(buf *bytes.Buffer, module string) error {
+	writeCBORHead(buf, cborArray, uint64(arr.Length()))
+	var err error
+	arr.Range(func(_ int, v *Value) bool {
+		err = v.marshalCBOR(buf, module)
+		return err == nil
+	})
+	return err
+}
+
+// writeCBORHead writes the initial byte of a data item and its
+// argument in the shortest form possible.
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	var b [8]byte
+	switch {
+	case arg < 24:
+		buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.BigEndian.PutUint16(b[:], uint16(arg))
+		buf.Write(b[:2])
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.BigEndian.PutUint32(b[:], uint32(arg))
+		buf.Write(b[:4])
+	default:
+		writeCBORHead64(buf, major, arg)
+	}
+}
+
+// writeCBORHead64 writes the initial byte of a data item followed by
+// its argument as eight bytes regardless of its magnitude.
+func writeCBORHead64(buf *bytes.Buffer, major byte, arg uint64) {
+	var b [8]byte
+	buf.WriteByte(major | cborArg64)
+	binary.BigEndian.PutUint64(b[:], arg)
+	buf.Write(b[:])
+}
+
+func writeCBORText(buf *bytes.Buffer, s string) {
+	writeCBORHead(buf, cborText, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// UnmarshalCBOR extracts a value from CBOR encoded data produced by
+// MarshalCBOR. Integers encoded with an eight byte argument are decoded
+// as 64 bit integers and all others as 32 bit integers. Text strings are
+// subject to the same type inference as quoted RFC7951 values so the
+// As* assertions behave as they do for RFC7951 encoded data. Byte
+// strings are decoded as base64 encoded strings.
+func (val *Value) UnmarshalCBOR(msg []byte) error {
+	return val.unmarshalCBORWithOpts(msg, &decodeOpts{})
+}
+
+func (val *Value) unmarshalCBORWithOpts(msg []byte, opts *decodeOpts) error {
+	strs, vals, release := opts.interners()
+	defer release()
+	dec := &cborDecoder{
+		msg:  msg,
+		strs: strs,
+		vals: vals,
+		opts: opts,
+	}
+	data, err := dec.decode("")
+	if err != nil {
+		return err
+	}
+	if dec.pos != len(msg) {
+		return errors.New("cbor: unexpected data after top-level value")
+	}
+	val.data = data
+	return nil
+}
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+type cborDecoder struct {
+	msg  []byte
+	pos  int
+	strs *stringInterner
+	vals *valueInterner
+	opts *decodeOpts
+}
+
+// readHead reads the initial byte of a data item and its argument.
+func (d *cborDecoder) readHead() (major, info byte, arg uint64, err error) {
+	if d.pos >= len(d.msg) {
+		return 0, 0, 0, errCBORTruncated
+	}
+	initial := d.msg[d.pos]
+	d.pos++
+	major, info = initial&0xe0, initial&0x1f
+	var n int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	default:
+		return 0, 0, 0, fmt.Errorf(
+			"cbor: unsupported additional information %d", info)
+	}
+	if d.pos+n > len(d.msg) {
+		return 0, 0, 0, errCBORTruncated
+	}
+	for _, b := range d.msg[d.pos : d.pos+n] {
+		arg = arg<<8 | uint64(b)
+	}
+	d.pos += n
+	return major, info, arg, nil
+}
+
+func (d *cborDecoder) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.msg)-d.pos) {
+		return nil, errCBORTruncated
+	}
+	out := d.msg[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return out, nil
+}
+
+func (d *cborDecoder) decode(module string) (interface{}, error) {
+	major, info, arg, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		if info == cborArg64 {
+			return arg, nil
+		}
+		return uint32(arg), nil
+	case cborNegInt:
+		if info == cborArg64 {
+			if arg > math.MaxInt64 {
+				return nil, errors.New("cbor: integer out of range")
+			}
+			return -1 - int64(arg), nil
+		}
+		if arg > math.MaxInt32 {
+			return nil, errors.New("cbor: integer out of range")
+		}
+		return int32(-1 - int64(arg)), nil
+	case cborBytes:
+		b, err := d.readBytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return d.strs.Intern(base64.StdEncoding.EncodeToString(b)), nil
+	case cborText:
+		b, err := d.readBytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return inferStringData(d.strs.Intern(string(b)), d.opts), nil
+	case cborArray:
+		return d.decodeArray(module, arg)
+	case cborMap:
+		return d.decodeObject(module, arg)
+	case cborSimple:
+		return d.decodeSimple(info, arg)
+	default:
+		return nil, errors.New("cbor: tags are not supported")
+	}
+}
+
+func (d *cborDecoder) decodeSimple(info byte, arg uint64) (interface{}, error) {
+	switch info {
+	case cborFalse & 0x1f:
+		return false, nil
+	case cborTrue & 0x1f:
+		return true, nil
+	case cborNull & 0x1f:
+		return nil, nil
+	case cborFloat32 & 0x1f:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case cborFloat64 & 0x1f:
+		return math.Float64frombits(arg), nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+	}
+}
+
+func (d *cborDecoder) decodeArray(module string, length uint64) (interface{}, error) {
+	arr := arrayNew()
+	arr.module = module
+	var err error
+	arr.store = arr.store.Transform(
+		func(store *vector.TVector) *vector.TVector {
+			for i := uint64(0); i < length; i++ {
+				var data interface{}
+				data, err = d.decode(arr.module)
+				if err != nil {
+					return store
+				}
+				val := arr.adaptValue(&Value{data: data})
+				val = d.vals.Intern(val)
+				store = store.Append(val)
+			}
+			return store
+		})
+	if err != nil {
+		return nil, err
+	}
+	if arr.Length() == 1 && equal(arr.At(0), ValueNew(nil)) {
+		return _empty.data, nil
+	}
+	return arr, nil
+}
+
+func (d *cborDecoder) decodeObject(module string, length uint64) (interface{}, error) {
+	obj := objectNew()
+	obj.module = module
+	var err error
+	obj.store = obj.store.Transform(
+		func(store *hashmap.TMap) *hashmap.TMap {
+			for i := uint64(0); i < length; i++ {
+				var k string
+				k, err = d.decodeKey()
+				if err != nil {
+					return store
+				}
+				module, _ := obj.parseKey(k)
+				module = d.strs.Intern(module)
+				var data interface{}
+				data, err = d.decode(module)
+				if err != nil {
+					return store
+				}
+				k, v := obj.adaptValue(k, &Value{data: data})
+				k = d.strs.Intern(k)
+				v = d.vals.Intern(v)
+				store = store.Assoc(k, v)
+			}
+			return store
+		})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (d *cborDecoder) decodeKey() (string, error) {
+	major, _, arg, err := d.readHead()
+	if err != nil {
+		return "", err
+	}
+	if major != cborText {
+		return "", errors.New("cbor: object keys must be text strings")
+	}
+	b, err := d.readBytes(arg)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// MarshalCBOR returns the Tree encoded as CBOR, see
+// (*Value).MarshalCBOR for details of the encoding.
+func (t *Tree) MarshalCBOR() ([]byte, error) {
+	return t.Root().MarshalCBOR()
+}
+
+// UnmarshalCBOR replaces the contents of the Tree with the CBOR encoded
+// message, which must contain an object.
+func (t *Tree) UnmarshalCBOR(msg []byte) error {
+	var val Value
+	err := val.UnmarshalCBOR(msg)
+	if err != nil {
+		return err
+	}
+	if !val.IsObject() {
+		return errors.New("cbor: tree must be an object")
+	}
+	t.root = &val
+	return nil
+}
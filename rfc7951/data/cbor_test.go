@@ -0,0 +1,144 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/danos/encoding/rfc7951"
+)
+
+func TestCBOREncoding(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    *Value
+		expected []byte
+	}{
+		{"uint32", ValueNew(10), []byte{0x0a}},
+		{"uint32/two-bytes", ValueNew(500), []byte{0x19, 0x01, 0xf4}},
+		{"int32", ValueNew(-10), []byte{0x29}},
+		{"uint64", ValueNew(uint64(1)),
+			[]byte{0x1b, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{"int64", ValueNew(int64(-1)),
+			[]byte{0x3b, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{"float", ValueNew(1.5),
+			[]byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"string", ValueNew("foo"), []byte{0x63, 'f', 'o', 'o'}},
+		{"bool", ValueNew(true), []byte{0xf5}},
+		{"null", ValueNew(nil), []byte{0xf6}},
+		{"empty", Empty(), []byte{0x81, 0xf6}},
+		{"array", ValueNew(ArrayWith(1, false)), []byte{0x82, 0x01, 0xf4}},
+		{"object", ValueNew(ObjectWith(PairNew("m:a", 1))),
+			[]byte{0xa1, 0x63, 'm', ':', 'a', 0x01}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.value.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, test.expected) {
+				t.Fatalf("expected: %x\ngot: %x\n", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	tree := TreeFromObject(TESTOBJ).
+		Assoc("/module-v1:types/negative", -5).
+		Assoc("/module-v1:types/uint64", uint64(1<<40)).
+		Assoc("/module-v1:types/int64", int64(-1<<40)).
+		Assoc("/module-v1:types/float", 2.5).
+		Assoc("/module-v1:types/bool", true).
+		Assoc("/module-v1:types/null", nil).
+		Assoc("/module-v1:types/empty", Empty()).
+		Assoc("/module-v1:types/numeric-string", "123").
+		Assoc("/module-v1:types/module-v2:other", "foo").
+		Assoc("/module-v1:types/iid",
+			InstanceIDNew("/module-v1:types/bool"))
+	data, err := tree.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := TreeNew()
+	err = got.UnmarshalCBOR(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The CBOR encoding must decode to the same values as the
+	// RFC7951 encoding so that the As* assertions behave the same.
+	text, err := rfc7951.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TreeNew()
+	err = rfc7951.Unmarshal(text, expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unlike a quoted fractional RFC7951 value, which is kept as a
+	// string, a CBOR float is known to be a float.
+	expected = expected.Assoc("/module-v1:types/float", 2.5)
+	if !got.Equal(expected) {
+		t.Fatalf("expected: %s\ngot: %s\ndifferences: %s\n",
+			expected, got, expected.Diff(got))
+	}
+	if got.At("/module-v1:types/negative").AsInt32() != -5 {
+		t.Fatal("negative integer did not round trip")
+	}
+	if got.At("/module-v1:types/uint64").AsUint64() != 1<<40 {
+		t.Fatal("uint64 did not round trip")
+	}
+	if !got.At("/module-v1:types/empty").IsEmpty() {
+		t.Fatal("empty did not round trip")
+	}
+}
+
+func TestCBORDecodeErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		msg      []byte
+		expected string
+	}{
+		{"truncated", []byte{0x63, 'f'}, "cbor: unexpected end of data"},
+		{"trailing", []byte{0x01, 0x02},
+			"cbor: unexpected data after top-level value"},
+		{"non-text-key", []byte{0xa1, 0x01, 0x01},
+			"cbor: object keys must be text strings"},
+		{"tag", []byte{0xc1, 0x01}, "cbor: tags are not supported"},
+		{"indefinite", []byte{0x9f, 0xff},
+			"cbor: unsupported additional information 31"},
+		{"int32-range", []byte{0x3a, 0x80, 0, 0, 0},
+			"cbor: integer out of range"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			var val Value
+			err := val.UnmarshalCBOR(test.msg)
+			if err == nil || err.Error() != test.expected {
+				t.Fatalf("expected error %q, got %v", test.expected, err)
+			}
+		})
+	}
+	t.Run("tree-not-object", func(t *testing.T) {
+		err := TreeNew().UnmarshalCBOR([]byte{0x01})
+		if err == nil {
+			t.Fatal("expected error decoding non-object tree")
+		}
+	})
+	t.Run("bytes", func(t *testing.T) {
+		var val Value
+		err := val.UnmarshalCBOR([]byte{0x43, 'f', 'o', 'o'})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := val.AsBytes(); string(b) != "foo" {
+			t.Fatalf("expected: foo\ngot: %s\n", b)
+		}
+	})
+}
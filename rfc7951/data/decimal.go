@@ -0,0 +1,130 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Decimal is an exact decimal number such as a YANG decimal64 value.
+// Every digit of the number is kept, unlike a float64 which is rounded
+// to 53 bits. A Decimal remembers the form it was created from and is
+// marshalled in that form, so "3.140" is encoded with its trailing zero.
+// Like floats, Decimals are encoded as quoted strings in RFC7951.
+// Decimals are compared by value, "1.5" is Equal to "1.50". The zero
+// Decimal is 0.
+type Decimal struct {
+	s string
+	r *big.Rat
+}
+
+// DecimalNew parses a decimal number in the lexical form of the YANG
+// decimal64 type: an optional sign followed by one or more digits,
+// optionally followed by a period and one or more further digits. An
+// error is returned if s isn't in that form.
+func DecimalNew(s string) (Decimal, error) {
+	if !isDecimal(s) {
+		return Decimal{}, errors.New("invalid decimal " + strconv.Quote(s))
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, errors.New("invalid decimal " + strconv.Quote(s))
+	}
+	return Decimal{s: s, r: r}, nil
+}
+
+// isDecimal returns whether s is in the lexical form of a decimal64.
+func isDecimal(s string) bool {
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	var digits, fraction int
+	var period bool
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '.' && !period:
+			period = true
+		case c < '0' || c > '9':
+			return false
+		case period:
+			fraction++
+		default:
+			digits++
+		}
+	}
+	return digits > 0 && (!period || fraction > 0)
+}
+
+func (d Decimal) rat() *big.Rat {
+	if d.r == nil {
+		return new(big.Rat)
+	}
+	return d.r
+}
+
+// Rat returns a copy of the number held by the Decimal.
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).Set(d.rat())
+}
+
+// Float64 returns the nearest float64 to the Decimal.
+func (d Decimal) Float64() float64 {
+	f, _ := d.rat().Float64()
+	return f
+}
+
+// RFC7951String returns the decimal in the form it was created from.
+func (d Decimal) RFC7951String() string {
+	if d.s == "" {
+		return "0"
+	}
+	return d.s
+}
+
+// String returns the decimal in the form it was created from.
+func (d Decimal) String() string {
+	return d.RFC7951String()
+}
+
+// MarshalRFC7951 returns the decimal encoded as a quoted string.
+func (d Decimal) MarshalRFC7951() ([]byte, error) {
+	return []byte("\"" + d.RFC7951String() + "\""), nil
+}
+
+// Equal implements equality for Decimals.
+func (d Decimal) Equal(other interface{}) bool {
+	od, isDecimal := other.(Decimal)
+	return isDecimal && d.rat().Cmp(od.rat()) == 0
+}
+
+// Compare implements comparison for Decimals. A Decimal may also be
+// compared with any of the other numeric types by value.
+func (d Decimal) Compare(other interface{}) int {
+	if od, isDecimal := other.(Decimal); isDecimal {
+		return d.rat().Cmp(od.rat())
+	}
+	n, isNumeric := numericValue(other)
+	if !isNumeric {
+		panic(fmt.Errorf("cannot compare decimal with %T", other))
+	}
+	return new(big.Float).SetRat(d.rat()).Cmp(n)
+}
+
+// parseDecimal returns item as a Decimal if decimal parsing was
+// requested and it is in the form of a decimal64, otherwise ok is false.
+func parseDecimal(item string, opts *decodeOpts) (interface{}, bool) {
+	if !opts.decimals {
+		return nil, false
+	}
+	d, err := DecimalNew(item)
+	if err != nil {
+		return nil, false
+	}
+	return d, true
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"jsouthworth.net/go/try"
+)
+
+const preciseDecimal = "3.14159265358979323846"
+
+func mustDecimal(t *testing.T, s string) Decimal {
+	d, err := DecimalNew(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDecimalNew(t *testing.T) {
+	for _, s := range []string{"0", "-1", "+1.5", "3.140", preciseDecimal} {
+		t.Run(s, func(t *testing.T) {
+			if got := mustDecimal(t, s).String(); got != s {
+				t.Fatalf("expected: %s\ngot: %s\n", s, got)
+			}
+		})
+	}
+	for _, s := range []string{"", "-", "1.", ".5", "1.2.3", "1e5", "foo"} {
+		t.Run("invalid "+s, func(t *testing.T) {
+			d, err := DecimalNew(s)
+			if err == nil {
+				t.Fatalf("expected error, got %s", d)
+			}
+		})
+	}
+	t.Run("zero", func(t *testing.T) {
+		var d Decimal
+		if d.String() != "0" || !d.Equal(mustDecimal(t, "0.0")) {
+			t.Fatalf("expected: 0\ngot: %s\n", d)
+		}
+	})
+}
+
+func TestDecimalValue(t *testing.T) {
+	v := ValueNew(mustDecimal(t, preciseDecimal))
+	if !v.IsDecimal() || v.Type() != KindDecimal {
+		t.Fatalf("expected Decimal, got %T", v.data)
+	}
+	t.Run("equal", func(t *testing.T) {
+		if !equal(ValueNew(mustDecimal(t, "1.5")),
+			ValueNew(mustDecimal(t, "1.50"))) {
+			t.Fatal("equal Decimals should be Equal")
+		}
+		if ValueNew(mustDecimal(t, "1.5")).Hash() !=
+			ValueNew(mustDecimal(t, "1.50")).Hash() {
+			t.Fatal("equal Decimals should have the same hash")
+		}
+		if equal(v, ValueNew(mustDecimal(t, "3.14159265358979"))) {
+			t.Fatal("Decimals differing in the last digits should differ")
+		}
+		if !ValueNew(mustDecimal(t, "0.5")).EqualNumeric(ValueNew(0.5)) {
+			t.Fatal("Decimal should be EqualNumeric to a float")
+		}
+		if ValueNew(mustDecimal(t, "1.5")).Compare(
+			ValueNew(mustDecimal(t, "1.25"))) <= 0 {
+			t.Fatal("expected 1.5 to be greater than 1.25")
+		}
+		for _, other := range []*Value{
+			ValueNew(1), ValueNew(int64(-1) << 40), ValueNew(1.25),
+		} {
+			if ValueNew(mustDecimal(t, "1.5")).Compare(other) <= 0 {
+				t.Fatalf("expected 1.5 to be greater than %s", other)
+			}
+		}
+		if ValueNew(mustDecimal(t, "2.0")).Compare(ValueNew(2)) != 0 {
+			t.Fatal("expected 2.0 to equal 2")
+		}
+	})
+	t.Run("marshal", func(t *testing.T) {
+		tree := TreeNew().
+			Assoc("/module-v1:pi", mustDecimal(t, preciseDecimal)).
+			Assoc("/module-v1:price", mustDecimal(t, "10.50"))
+		got, err := tree.MarshalCanonical()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"module-v1:pi":"` + preciseDecimal + `",` +
+			`"module-v1:price":"10.50"}`
+		if string(got) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("coerce", func(t *testing.T) {
+		tree := TreeNew().
+			Assoc("/module-v1:str", preciseDecimal).
+			Assoc("/module-v1:dec", mustDecimal(t, "2.50")).
+			Coerce(map[string]ValueKind{
+				"/module-v1:str": KindDecimal,
+				"/module-v1:dec": KindString,
+			})
+		if got := tree.At("/module-v1:str"); !got.IsDecimal() ||
+			got.RFC7951String() != preciseDecimal {
+			t.Fatalf("expected: %s\ngot: %s\n", preciseDecimal, got)
+		}
+		if got := tree.At("/module-v1:dec"); !got.IsString() ||
+			got.AsString() != "2.50" {
+			t.Fatalf("expected: 2.50\ngot: %s\n", got)
+		}
+	})
+}
+
+func TestValueAsDecimal(t *testing.T) {
+	cases := []struct {
+		name     string
+		val      *Value
+		expected string
+		err      bool
+	}{
+		{"decimal", ValueNew(mustDecimal(t, "1.50")), "1.50", false},
+		{"string", ValueNew(preciseDecimal), preciseDecimal, false},
+		{"float", ValueNew(0.1), "0.1", false},
+		{"uint32", ValueNew(10), "10", false},
+		{"int64", ValueNew(int64(-10)), "-10", false},
+		{"non-numeric string", ValueNew("foo"), "", true},
+		{"bool", ValueNew(true), "", true},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.val.AsDecimalE()
+			if test.err {
+				if _, isKindError := err.(*KindError); !isKindError {
+					t.Fatalf("expected a *KindError, got %v", err)
+				}
+				if _, err := try.Apply(test.val.AsDecimal); err == nil {
+					t.Fatal("expected AsDecimal to panic")
+				}
+				if def := test.val.ToDecimal(
+					mustDecimal(t, "1.5")); def.String() != "1.5" {
+					t.Fatalf("expected: 1.5\ngot: %s\n", def)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, got)
+			}
+			if test.val.AsDecimal().String() != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected,
+					test.val.AsDecimal())
+			}
+			if test.val.ToDecimal().String() != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected,
+					test.val.ToDecimal())
+			}
+		})
+	}
+}
+
+func TestDecoderParseDecimals(t *testing.T) {
+	in := `{"module-v1:pi":"` + preciseDecimal + `",` +
+		`"module-v1:pos":"+1.50","module-v1:neg":"-0.25",` +
+		`"module-v1:int":"10","module-v1:str":"1.2.3"}`
+	t.Run("default", func(t *testing.T) {
+		var tree Tree
+		err := NewDecoder(strings.NewReader(in)).Decode(&tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.At("/module-v1:pi").ToString() != preciseDecimal {
+			t.Fatal("expected decimal to be a string")
+		}
+		if !tree.At("/module-v1:pos").Equal(ValueNew("+1.50")) {
+			t.Fatal("expected decimal to be kept as written")
+		}
+	})
+	t.Run("ParseDecimals", func(t *testing.T) {
+		var tree Tree
+		err := NewDecoder(strings.NewReader(in), ParseDecimals()).
+			Decode(&tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range []string{
+			"/module-v1:pi", "/module-v1:pos", "/module-v1:neg",
+		} {
+			if !tree.At(path).IsDecimal() {
+				t.Fatalf("expected %s to be a Decimal", path)
+			}
+		}
+		if tree.At("/module-v1:int").AsUint64() != 10 {
+			t.Fatal("integers should be unaffected")
+		}
+		if !tree.At("/module-v1:str").IsString() {
+			t.Fatal("non-numeric strings should be unaffected")
+		}
+		got, err := tree.MarshalCanonical()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"module-v1:int":"10","module-v1:neg":"-0.25",` +
+			`"module-v1:pi":"` + preciseDecimal + `",` +
+			`"module-v1:pos":"+1.50","module-v1:str":"1.2.3"}`
+		if string(got) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+}
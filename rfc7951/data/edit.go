@@ -18,6 +18,19 @@ const (
 	EditDelete EditAction = "delete"
 	// EditMerge is the edit action association with the Merge operation.
 	EditMerge EditAction = "merge"
+	// EditInsert is the edit action association with inserting a value
+	// into an array at a position without replacing the existing element.
+	EditInsert EditAction = "insert"
+	// EditTest is the edit action that asserts the value at a path
+	// equals the entry's value, or that the path doesn't exist if the
+	// entry has no value. If the assertion fails the edit fails, see
+	// Tree.EditChecked.
+	EditTest EditAction = "test"
+	// EditMove is the edit action that moves an element of an array
+	// from the position given by the entry's From to the position given
+	// by its Path. The element is removed before the destination is
+	// located, so Path is a position in the array without the element.
+	EditMove EditAction = "move"
 )
 
 // EditAction is an action that can be performed by the edit engine.
@@ -37,6 +50,12 @@ func (e *EditAction) UnmarshalRFC7951(msg []byte) error {
 		*e = EditDelete
 	case "merge":
 		*e = EditMerge
+	case "insert":
+		*e = EditInsert
+	case "test":
+		*e = EditTest
+	case "move":
+		*e = EditMove
 	default:
 		return errors.New("unknown edit-action" + string(msg))
 	}
@@ -46,7 +65,7 @@ func (e *EditAction) UnmarshalRFC7951(msg []byte) error {
 // MarshalRFC7951 returns the EditAction as RFC7951 encoded data.
 func (e EditAction) MarshalRFC7951() ([]byte, error) {
 	switch e {
-	case EditAssoc, EditDelete, EditMerge:
+	case EditAssoc, EditDelete, EditMerge, EditInsert, EditTest, EditMove:
 		s := e.String()
 		return []byte("\"" + s + "\""), nil
 	default:
@@ -61,9 +80,11 @@ func (e EditAction) String() string {
 
 // EditEntry contains the actions to perform as well as the
 // instance-id to perform it at and the value if any to be used.
+// From is only used by EditMove.
 type EditEntry struct {
 	Action EditAction  `rfc7951:"action"`
 	Path   *InstanceID `rfc7951:"path"`
+	From   *InstanceID `rfc7951:"from,omitempty"`
 	Value  *Value      `rfc7951:"value,omitempty"`
 }
 
@@ -87,6 +108,94 @@ func (e *EditEntry) evalMerge() func(*Tree) *Tree {
 		return t.assoc(path, val)
 	}
 }
+func (e *EditEntry) evalInsert() func(*Tree) *Tree {
+	path, value := e.Path, e.Value
+	return func(t *Tree) *Tree {
+		pos, isPos := path.position()
+		if !isPos {
+			panic(fmt.Errorf("insert requires a positional predicate: %v",
+				path))
+		}
+		parent := path.path()
+		arr := t.at(parent)
+		if arr == nil {
+			arr = ValueNew(ArrayNew())
+		}
+		if !arr.IsArray() {
+			panic(fmt.Errorf("insert target %v is not an array", parent))
+		}
+		if pos < 0 || pos > arr.AsArray().Length() {
+			panic(fmt.Errorf("insert position %d out of range for %v",
+				pos, parent))
+		}
+		return t.assoc(parent, ValueNew(arr.AsArray().insert(pos, value)))
+	}
+}
+func (e *EditEntry) evalTest() func(*Tree) *Tree {
+	path, value := e.Path, e.Value
+	if value != nil && len(path.ids) != 0 {
+		// Compare against the value as it would be stored at the
+		// path, so objects compare with their keys qualified.
+		value = value.belongsTo(value, path.ids[len(path.ids)-1].prefix)
+	}
+	return func(t *Tree) *Tree {
+		got := t.at(path)
+		matched := got == value ||
+			(got != nil && value != nil && equal(got, value))
+		if !matched {
+			panic(fmt.Errorf("test failed at %v: expected %s, got %s",
+				path, editTestString(value), editTestString(got)))
+		}
+		return t
+	}
+}
+
+func (e *EditEntry) evalMove() func(*Tree) *Tree {
+	from, to := e.From, e.Path
+	if from == nil {
+		panic(fmt.Errorf("move to %v requires a from path", to))
+	}
+	src, isSrcPos := from.position()
+	dst, isDstPos := to.position()
+	if !isSrcPos || !isDstPos {
+		panic(fmt.Errorf("move requires positional predicates: %v to %v",
+			from, to))
+	}
+	parent := to.path()
+	if !equal(from.path(), parent) {
+		panic(fmt.Errorf("move from %v to %v must be within one array",
+			from, to))
+	}
+	return func(t *Tree) *Tree {
+		arr := t.at(parent)
+		if arr == nil || !arr.IsArray() {
+			panic(fmt.Errorf("move target %v is not an array", parent))
+		}
+		a := arr.AsArray()
+		if src < 0 || src >= a.Length() {
+			panic(fmt.Errorf("move position %d out of range for %v",
+				src, parent))
+		}
+		v := a.At(src)
+		a = a.Delete(src)
+		if dst < 0 || dst > a.Length() {
+			panic(fmt.Errorf("move position %d out of range for %v",
+				dst, parent))
+		}
+		return t.assoc(parent, ValueNew(a.insert(dst, v)))
+	}
+}
+
+func editTestString(v *Value) string {
+	if v == nil {
+		return "nothing"
+	}
+	if v.IsContainer() {
+		return v.String()
+	}
+	return v.RFC7951String()
+}
+
 func (e *EditEntry) eval() func(*Tree) *Tree {
 	switch e.Action {
 	case EditAssoc:
@@ -95,6 +204,12 @@ func (e *EditEntry) eval() func(*Tree) *Tree {
 		return e.evalDelete()
 	case EditMerge:
 		return e.evalMerge()
+	case EditInsert:
+		return e.evalInsert()
+	case EditTest:
+		return e.evalTest()
+	case EditMove:
+		return e.evalMove()
 	default:
 		panic(fmt.Errorf("unknown edit-action %v", e.Action))
 	}
@@ -136,6 +251,7 @@ func EditOperationNew(entries ...EditEntry) *EditOperation {
 
 type editEntryOptions struct {
 	value *Value
+	from  *InstanceID
 }
 
 // EditEntryOption is a constructor for the optional parts of an EditEntry.
@@ -149,6 +265,14 @@ func EditEntryValue(val interface{}) EditEntryOption {
 	}
 }
 
+// EditEntryFrom produces an EditEntryOption that populates the from
+// field of an EditEntry, the position an EditMove moves an element from.
+func EditEntryFrom(path string) EditEntryOption {
+	return func(o *editEntryOptions) {
+		o.from = InstanceIDNew(path)
+	}
+}
+
 // EditEntryNew constructs a new EditEntry from the provided parameters.
 // The last option in wins if they write the same option.
 func EditEntryNew(action EditAction, path string, options ...EditEntryOption) EditEntry {
@@ -159,6 +283,7 @@ func EditEntryNew(action EditAction, path string, options ...EditEntryOption) Ed
 	return EditEntry{
 		Action: action,
 		Path:   InstanceIDNew(path),
+		From:   opts.from,
 		Value:  opts.value,
 	}
 }
@@ -151,3 +151,261 @@ func TestEditOperationUnmarshal(t *testing.T) {
 		}
 	})
 }
+
+func TestEditInsert(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith("a", "b", "c")).
+		Assoc("/module-v1:leaf", "foo")
+	t.Run("marshal", func(t *testing.T) {
+		edit := EditOperationNew(
+			EditEntryNew(EditInsert, "/module-v1:leaf-list[1]",
+				EditEntryValue("x")))
+		data, err := rfc7951.Marshal(edit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got EditOperation
+		err = rfc7951.Unmarshal(data, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Actions[0].Action != EditInsert {
+			t.Fatalf("expected: %s\ngot: %s\n",
+				EditInsert, got.Actions[0].Action)
+		}
+	})
+	cases := []struct {
+		name     string
+		path     string
+		expected *Array
+	}{
+		{"start", "/module-v1:leaf-list[0]", ArrayWith("x", "a", "b", "c")},
+		{"middle", "/module-v1:leaf-list[1]", ArrayWith("a", "x", "b", "c")},
+		{"end", "/module-v1:leaf-list[3]", ArrayWith("a", "b", "c", "x")},
+		{"new-list", "/module-v1:other[0]", ArrayWith("x")},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			new := tree.Edit(EditOperationNew(
+				EditEntryNew(EditInsert, test.path,
+					EditEntryValue("x"))))
+			parent := InstanceIDNew(test.path).path().String()
+			got := new.At(parent)
+			expected := TreeNew().Assoc(parent, test.expected).At(parent)
+			if !equal(got, expected) {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, got)
+			}
+			if !equal(tree.At("/module-v1:leaf-list"), TreeNew().
+				Assoc("/module-v1:leaf-list", ArrayWith("a", "b", "c")).
+				At("/module-v1:leaf-list")) {
+				t.Fatal("original tree was modified")
+			}
+		})
+	}
+	failures := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"not-an-array", "/module-v1:leaf[0]",
+			"insert target /module-v1:leaf is not an array"},
+		{"no-position", "/module-v1:leaf-list",
+			"insert requires a positional predicate: /module-v1:leaf-list"},
+		{"out-of-range", "/module-v1:leaf-list[5]",
+			"insert position 5 out of range for /module-v1:leaf-list"},
+	}
+	for _, test := range failures {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				err, ok := r.(error)
+				if !ok || err.Error() != test.expected {
+					t.Fatalf("expected: %s\ngot: %v\n",
+						test.expected, r)
+				}
+			}()
+			tree.Edit(EditOperationNew(
+				EditEntryNew(EditInsert, test.path,
+					EditEntryValue("x"))))
+		})
+	}
+}
+
+func TestEditMove(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith("a", "b", "c", "d")).
+		Assoc("/module-v1:other", ArrayWith("x")).
+		Assoc("/module-v1:leaf", "foo")
+	t.Run("marshal", func(t *testing.T) {
+		edit := EditOperationNew(
+			EditEntryNew(EditMove, "/module-v1:leaf-list[0]",
+				EditEntryFrom("/module-v1:leaf-list[2]")))
+		data, err := rfc7951.Marshal(edit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got EditOperation
+		err = rfc7951.Unmarshal(data, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Actions[0].Action != EditMove {
+			t.Fatalf("expected: %s\ngot: %s\n",
+				EditMove, got.Actions[0].Action)
+		}
+		if !equal(got.Actions[0].From, edit.Actions[0].From) {
+			t.Fatalf("expected: %s\ngot: %s\n",
+				edit.Actions[0].From, got.Actions[0].From)
+		}
+	})
+	cases := []struct {
+		name     string
+		from, to string
+		expected *Array
+	}{
+		{"forward", "/module-v1:leaf-list[0]", "/module-v1:leaf-list[2]",
+			ArrayWith("b", "c", "a", "d")},
+		{"backward", "/module-v1:leaf-list[3]", "/module-v1:leaf-list[0]",
+			ArrayWith("d", "a", "b", "c")},
+		{"end", "/module-v1:leaf-list[1]", "/module-v1:leaf-list[3]",
+			ArrayWith("a", "c", "d", "b")},
+		{"same", "/module-v1:leaf-list[1]", "/module-v1:leaf-list[1]",
+			ArrayWith("a", "b", "c", "d")},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			new := tree.Edit(EditOperationNew(
+				EditEntryNew(EditMove, test.to,
+					EditEntryFrom(test.from))))
+			got := new.At("/module-v1:leaf-list")
+			expected := TreeNew().
+				Assoc("/module-v1:leaf-list", test.expected).
+				At("/module-v1:leaf-list")
+			if !equal(got, expected) {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, got)
+			}
+		})
+	}
+	failures := []struct {
+		name     string
+		entry    EditEntry
+		expected string
+	}{
+		{"no-from", EditEntryNew(EditMove, "/module-v1:leaf-list[0]"),
+			"move to /module-v1:leaf-list[0] requires a from path"},
+		{"no-position", EditEntryNew(EditMove, "/module-v1:leaf-list[0]",
+			EditEntryFrom("/module-v1:leaf-list")),
+			"move requires positional predicates: " +
+				"/module-v1:leaf-list to /module-v1:leaf-list[0]"},
+		{"different-arrays", EditEntryNew(EditMove, "/module-v1:other[0]",
+			EditEntryFrom("/module-v1:leaf-list[0]")),
+			"move from /module-v1:leaf-list[0] to /module-v1:other[0] " +
+				"must be within one array"},
+		{"not-an-array", EditEntryNew(EditMove, "/module-v1:leaf[0]",
+			EditEntryFrom("/module-v1:leaf[1]")),
+			"move target /module-v1:leaf is not an array"},
+		{"from-out-of-range", EditEntryNew(EditMove, "/module-v1:leaf-list[0]",
+			EditEntryFrom("/module-v1:leaf-list[4]")),
+			"move position 4 out of range for /module-v1:leaf-list"},
+		{"to-out-of-range", EditEntryNew(EditMove, "/module-v1:leaf-list[4]",
+			EditEntryFrom("/module-v1:leaf-list[0]")),
+			"move position 4 out of range for /module-v1:leaf-list"},
+	}
+	for _, test := range failures {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := tree.EditChecked(EditOperationNew(test.entry))
+			if err == nil || err.Error() != test.expected {
+				t.Fatalf("expected: %s\ngot: %v\n",
+					test.expected, err)
+			}
+		})
+	}
+}
+
+func TestEditTest(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:container/inner", "bar")
+	t.Run("marshal", func(t *testing.T) {
+		edit := EditOperationNew(
+			EditEntryNew(EditTest, "/module-v1:leaf",
+				EditEntryValue("foo")))
+		data, err := rfc7951.Marshal(edit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got EditOperation
+		err = rfc7951.Unmarshal(data, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Actions[0].Action != EditTest {
+			t.Fatalf("expected: %s\ngot: %s\n",
+				EditTest, got.Actions[0].Action)
+		}
+	})
+	cases := []struct {
+		name     string
+		test     EditEntry
+		expected string
+	}{
+		{
+			name: "leaf matches",
+			test: EditEntryNew(EditTest, "/module-v1:leaf",
+				EditEntryValue("foo")),
+		},
+		{
+			name: "container matches",
+			test: EditEntryNew(EditTest, "/module-v1:container",
+				EditEntryValue(ObjectWith(PairNew("inner", "bar")))),
+		},
+		{
+			name: "absent",
+			test: EditEntryNew(EditTest, "/module-v1:missing"),
+		},
+		{
+			name: "leaf differs",
+			test: EditEntryNew(EditTest, "/module-v1:leaf",
+				EditEntryValue("bar")),
+			expected: "test failed at /module-v1:leaf: expected bar, got foo",
+		},
+		{
+			name:     "present",
+			test:     EditEntryNew(EditTest, "/module-v1:leaf"),
+			expected: "test failed at /module-v1:leaf: expected nothing, got foo",
+		},
+		{
+			name: "missing",
+			test: EditEntryNew(EditTest, "/module-v1:missing",
+				EditEntryValue("foo")),
+			expected: "test failed at /module-v1:missing: expected foo, got nothing",
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := tree.EditChecked(EditOperationNew(
+				test.test,
+				EditEntryNew(EditAssoc, "/module-v1:leaf",
+					EditEntryValue("baz"))))
+			if test.expected != "" {
+				if err == nil || err.Error() != test.expected {
+					t.Fatalf("expected: %s\ngot: %v\n",
+						test.expected, err)
+				}
+				if got != nil {
+					t.Fatal("expected no tree on failure")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.At("/module-v1:leaf").ToString() != "baz" {
+				t.Fatalf("expected: baz\ngot: %s\n",
+					got.At("/module-v1:leaf"))
+			}
+		})
+	}
+}
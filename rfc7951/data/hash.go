@@ -0,0 +1,143 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"reflect"
+)
+
+// Hash returns a hash of the value's content suitable for indexing
+// values in a map or deduplicating them. Hash is consistent with Equal:
+// values that are Equal have the same Hash. The converse doesn't hold,
+// values with the same Hash may differ and Equal must be used to
+// confirm a match. Like Equal, Hash takes the type a value is stored
+// with into account, so an int32 and an int64 of the same number will
+// usually hash differently. The hash of an Object doesn't depend on the
+// order of its members, while that of an Array depends on the order of
+// its elements. Values of a type registered with RegisterValueType are
+// hashed by their type and RFC7951String, such types must ensure that
+// Equal values have the same string form. The hash is stable for the
+// lifetime of the process but isn't guaranteed to be stable between
+// versions of this package.
+func (val *Value) Hash() uint64 {
+	if val == nil {
+		return hashData(nil)
+	}
+	return hashData(val.data)
+}
+
+const (
+	hashNil byte = iota
+	hashEmpty
+	hashBool
+	hashString
+	hashInt32
+	hashUint32
+	hashInt64
+	hashUint64
+	hashFloat64
+	hashBigInt
+	hashDecimal
+	hashInstanceID
+	hashObject
+	hashObjectMember
+	hashArray
+	hashRegistered
+)
+
+type hasher struct {
+	buf []byte
+}
+
+func (h *hasher) tag(t byte) *hasher {
+	h.buf = append(h.buf, t)
+	return h
+}
+
+func (h *hasher) uint64(v uint64) *hasher {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	h.buf = append(h.buf, b[:]...)
+	return h
+}
+
+func (h *hasher) string(s string) *hasher {
+	// The length prefix keeps adjacent strings from running together.
+	h.uint64(uint64(len(s)))
+	h.buf = append(h.buf, s...)
+	return h
+}
+
+func (h *hasher) sum() uint64 {
+	f := fnv.New64a()
+	f.Write(h.buf)
+	return f.Sum64()
+}
+
+func hashData(data interface{}) uint64 {
+	h := &hasher{}
+	switch d := data.(type) {
+	case nil:
+		h.tag(hashNil)
+	case empty:
+		h.tag(hashEmpty)
+	case bool:
+		h.tag(hashBool)
+		if d {
+			h.uint64(1)
+		} else {
+			h.uint64(0)
+		}
+	case string:
+		h.tag(hashString).string(d)
+	case int32:
+		h.tag(hashInt32).uint64(uint64(d))
+	case uint32:
+		h.tag(hashUint32).uint64(uint64(d))
+	case int64:
+		h.tag(hashInt64).uint64(uint64(d))
+	case uint64:
+		h.tag(hashUint64).uint64(d)
+	case float64:
+		if d == 0 {
+			// 0 and -0 are Equal but have different bits.
+			d = 0
+		}
+		h.tag(hashFloat64).uint64(math.Float64bits(d))
+	case BigInt:
+		h.tag(hashBigInt).string(d.String())
+	case Decimal:
+		// Equal Decimals may be written differently.
+		h.tag(hashDecimal).string(d.rat().RatString())
+	case *InstanceID:
+		h.tag(hashInstanceID).string(d.String())
+	case *Object:
+		// Members are combined by addition so that the order in which
+		// they are visited doesn't matter.
+		var members uint64
+		d.Range(func(key string, v *Value) {
+			members += (&hasher{}).
+				tag(hashObjectMember).
+				string(key).
+				uint64(v.Hash()).
+				sum()
+		})
+		h.tag(hashObject).string(d.module).uint64(members)
+	case *Array:
+		h.tag(hashArray).string(d.module)
+		d.Range(func(v *Value) {
+			h.uint64(v.Hash())
+		})
+	case interface{ RFC7951String() string }:
+		h.tag(hashRegistered).
+			string(reflect.TypeOf(d).String()).
+			string(d.RFC7951String())
+	}
+	return h.sum()
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValueHash(t *testing.T) {
+	t.Run("equal values", func(t *testing.T) {
+		tests := []struct {
+			name string
+			a, b *Value
+		}{
+			{"nil", ValueNew(nil), ValueNew(nil)},
+			{"empty", Empty(), ValueNew([]interface{}{nil})},
+			{"string", ValueNew("foo"), ValueNew("foo")},
+			{"int", ValueNew(int8(-1)), ValueNew(int32(-1))},
+			{"float", ValueNew(0.0), ValueNew(math.Copysign(0, -1))},
+			{"instance-identifier",
+				ValueNew(InstanceIDNew("/module-v1:foo/bar")),
+				ValueNew(InstanceIDNew("/module-v1:foo/module-v1:bar"))},
+			{"object",
+				ValueNew(ObjectWith(
+					PairNew("module-v1:a", 1),
+					PairNew("module-v1:b", ArrayWith("x", "y")),
+					PairNew("module-v1:c", ObjectWith(PairNew("d", true))),
+				)),
+				ValueNew(ObjectWith(
+					PairNew("module-v1:c", ObjectWith(PairNew("d", true))),
+					PairNew("module-v1:b", ArrayWith("x", "y")),
+					PairNew("module-v1:a", 1),
+				))},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				if !test.a.Equal(test.b) {
+					t.Fatalf("expected %s to equal %s", test.a, test.b)
+				}
+				if test.a.Hash() != test.b.Hash() {
+					t.Fatalf("expected equal hashes for %s and %s",
+						test.a, test.b)
+				}
+			})
+		}
+	})
+	t.Run("different values", func(t *testing.T) {
+		tests := []struct {
+			name string
+			a, b *Value
+		}{
+			{"nil and empty", ValueNew(nil), Empty()},
+			{"number and string", ValueNew(1), ValueNew("1")},
+			{"int widths", ValueNew(int32(-1)), ValueNew(int64(-1 << 40))},
+			{"array order", ValueNew(ArrayWith(1, 2)), ValueNew(ArrayWith(2, 1))},
+			{"array split",
+				ValueNew(ArrayWith("ab", "c")), ValueNew(ArrayWith("a", "bc"))},
+			{"object members",
+				ValueNew(ObjectWith(PairNew("module-v1:a", 1))),
+				ValueNew(ObjectWith(PairNew("module-v1:a", 2)))},
+			{"object keys",
+				ValueNew(ObjectWith(PairNew("module-v1:a", 1))),
+				ValueNew(ObjectWith(PairNew("module-v1:b", 1)))},
+			{"object and array", ValueNew(ObjectNew()), ValueNew(ArrayNew())},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				if test.a.Hash() == test.b.Hash() {
+					t.Fatalf("expected different hashes for %s and %s",
+						test.a, test.b)
+				}
+			})
+		}
+	})
+	t.Run("dedup", func(t *testing.T) {
+		vals := []*Value{
+			ValueNew(ObjectWith(PairNew("module-v1:a", 1))),
+			ValueNew("foo"),
+			ValueNew(ObjectWith(PairNew("module-v1:a", 1))),
+			ValueNew("foo"),
+			ValueNew("bar"),
+		}
+		set := make(map[uint64][]*Value)
+		count := 0
+	outer:
+		for _, v := range vals {
+			for _, seen := range set[v.Hash()] {
+				if seen.Equal(v) {
+					continue outer
+				}
+			}
+			set[v.Hash()] = append(set[v.Hash()], v)
+			count++
+		}
+		if count != 3 {
+			t.Fatalf("expected: %d\ngot: %d\n", 3, count)
+		}
+	})
+}
@@ -7,6 +7,7 @@ package data
 
 import (
 	"errors"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -20,11 +21,54 @@ const (
 	wsp  = sp + htab
 )
 
-// InstanceIDNew parses an instance identifier string into an InstanceID object
+// InstanceIDNew parses an instance identifier string into an InstanceID object.
+// InstanceIDNew panics with an *InstanceIDError if instance is not a
+// valid instance-identifier, InstanceIDParse returns the error instead.
 func InstanceIDNew(instance string) *InstanceID {
 	return (&InstanceID{}).parse(instance)
 }
 
+// InstanceIDParse parses an instance identifier string into an
+// InstanceID object. Unlike InstanceIDNew it returns an *InstanceIDError
+// if instance is not a valid instance-identifier, errors.Is may be used
+// to compare it with the Err* errors to determine what was wrong.
+func InstanceIDParse(instance string) (id *InstanceID, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		idErr, isInvalid := r.(*InstanceIDError)
+		if !isInvalid {
+			panic(r)
+		}
+		id, err = nil, idErr
+	}()
+	return InstanceIDNew(instance), nil
+}
+
+// RelativeInstanceIDNew parses a relative instance-identifier, one that
+// is only meaningful once joined onto a base instance-identifier with
+// Resolve. This is an extension beyond RFC7951, where every
+// instance-identifier is absolute. A relative instance-identifier
+// doesn't start with a "/" and may start with any number of ".."
+// node-identifiers, each of which steps up one node from the base,
+// before the node-identifiers that are appended to it:
+//
+//     relative-instance-identifier = parent *("/" parent)
+//                                    ["/" relative-path] / relative-path
+//     parent              = ".."
+//     relative-path       = (node-identifier *predicate)
+//                           *("/" (node-identifier *predicate))
+//
+// The leading node-identifiers of relative-path may omit their prefix
+// and inherit it from the node they are appended to.
+// RelativeInstanceIDNew panics with an *InstanceIDError if instance is
+// not a valid relative instance-identifier.
+func RelativeInstanceIDNew(instance string) *InstanceID {
+	return (&InstanceID{}).parseRelative(instance)
+}
+
 // InstanceID is an RFC7951 instance-identifier type.
 // It is defined here https://tools.ietf.org/html/rfc7951#section-6.11
 //
@@ -33,12 +77,14 @@ func InstanceIDNew(instance string) *InstanceID {
 //     predicate           = "[" *WSP (predicate-expr / pos) *WSP "]"
 //     predicate-expr      = (node-identifier / ".") *WSP "=" *WSP
 //                           ((DQUOTE string DQUOTE) /
-//                            (SQUOTE string SQUOTE))
-//     pos                 = non-negative-integer-value
+//                            (SQUOTE string SQUOTE) /
+//                            "*")
+//     pos                 = integer-value / "last()"
 //     node-identifier     = [prefix ":"] identifier
 //     identifier          = (ALPHA / "_")
 //                           *(ALPHA / DIGIT / "_" / "-" / ".")
 //     prefix              = identifier
+//     integer-value       = ["-"] non-negative-integer-value
 //     non-negative-integer-value = "0" / positive-integer-value
 //     positive-integer-value = (non-zero-digit *DIGIT)
 //     string              = < an unquoted string as returned by the scanner >
@@ -53,8 +99,27 @@ func InstanceIDNew(instance string) *InstanceID {
 //                           ; " (Double Quote)
 //     SQUOTE              = %x27
 //                           ; ' (Single Quote)
+//
+// As an extension to the RFC7951 grammar a predicate-expr value of "*",
+// quoted or not, is a wildcard that matches every entry of a list or
+// leaf-list. Find returns an array of all the nodes matched through a
+// wildcard.
+//
+// As a further extension a pos may be negative, counting back from the
+// end of the list or leaf-list, and "last()" is equivalent to -1.
+//
+// XPath has no way to escape quotes, a value ends at the first quote
+// of the kind that started it and backslashes have no special meaning.
+// So a value may contain either kind of quote but not both. String
+// quotes values with single quotes unless they contain one, in which
+// case double quotes are used.
 type InstanceID struct {
 	ids []*nodeID
+	// relative is set for instance-identifiers created by
+	// RelativeInstanceIDNew, up is their number of leading ".."
+	// node-identifiers.
+	relative bool
+	up       int
 }
 
 // path returns the path of the instance ID up to the last fully
@@ -76,6 +141,50 @@ func (i *InstanceID) path() *InstanceID {
 	return out
 }
 
+// ancestry returns the instance-identifiers of each addressable node
+// from the outermost to i itself, as successive calls to path would
+// produce them.
+func (i *InstanceID) ancestry() []*InstanceID {
+	var out []*InstanceID
+	for id := i; len(id.ids) != 0; id = id.path() {
+		out = append(out, id)
+	}
+	for l, r := 0, len(out)-1; l < r; l, r = l+1, r-1 {
+		out[l], out[r] = out[r], out[l]
+	}
+	return out
+}
+
+// Parent returns the instance-identifier of the node containing the
+// one addressed by i. The final node-identifier and its predicates are
+// removed. Parent returns nil if i has only one node-identifier.
+// The original instance-identifier is not modified.
+func (i *InstanceID) Parent() *InstanceID {
+	if len(i.ids) <= 1 {
+		return nil
+	}
+	out := i.copy()
+	out.ids = out.ids[:len(out.ids)-1]
+	return out
+}
+
+// position returns the index of the positional predicate terminating
+// the instance-identifier, if it ends in exactly one such predicate.
+func (i *InstanceID) position() (int, bool) {
+	if len(i.ids) == 0 {
+		return 0, false
+	}
+	last := i.ids[len(i.ids)-1]
+	if last.predicates == nil || len(last.predicates.preds) != 1 {
+		return 0, false
+	}
+	pos, isPos := last.predicates.preds[0].instanceIDSelector.(*posPredicate)
+	if !isPos {
+		return 0, false
+	}
+	return int(pos.pos), true
+}
+
 // copy returns a copy of the instance-identifier that can subsequently
 // be modified without effecting the original.
 func (i *InstanceID) copy() *InstanceID {
@@ -84,7 +193,9 @@ func (i *InstanceID) copy() *InstanceID {
 		newIds[i] = v.copy()
 	}
 	return &InstanceID{
-		ids: newIds,
+		ids:      newIds,
+		relative: i.relative,
+		up:       i.up,
 	}
 }
 
@@ -138,11 +249,174 @@ func (i *InstanceID) MarshalRFC7951() ([]byte, error) {
 
 // Equal determines if two instance-identifiers are the same.
 // It implements a common equality interface so other must be
-// interface{}.
+// interface{}. Node-identifiers are compared using their resolved
+// prefixes so "/m:a/m:b" is equal to "/m:a/b". A relative
+// instance-identifier is only equal to another that steps up the same
+// number of nodes.
 func (i *InstanceID) Equal(other interface{}) bool {
 	oi, isInstanceID := other.(*InstanceID)
-	return isInstanceID &&
-		oi.String() == i.String()
+	if !isInstanceID || len(oi.ids) != len(i.ids) ||
+		oi.relative != i.relative || oi.up != i.up {
+		return false
+	}
+	for n, id := range i.ids {
+		if !id.equal(oi.ids[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare imposes a total order on instance-identifiers consistent
+// with Equal. It returns a negative number if i sorts before other,
+// zero if they are equal and a positive number otherwise.
+// Instance-identifiers are compared node by node; node-identifiers
+// are ordered lexically by their resolved prefix and then identifier,
+// followed by their predicates. Positional predicates are ordered
+// numerically and before expression predicates, which are ordered
+// lexically by key and then value. Where one instance-identifier is a
+// prefix of the other the shorter sorts first. Absolute
+// instance-identifiers sort before relative ones, which are ordered by
+// the number of nodes they step up before their node-identifiers.
+func (i *InstanceID) Compare(other *InstanceID) int {
+	switch {
+	case i.relative != other.relative:
+		if i.relative {
+			return 1
+		}
+		return -1
+	case i.up != other.up:
+		return i.up - other.up
+	}
+	for n, id := range i.ids {
+		if n == len(other.ids) {
+			return 1
+		}
+		if c := id.compare(other.ids[n]); c != 0 {
+			return c
+		}
+	}
+	if len(i.ids) < len(other.ids) {
+		return -1
+	}
+	return 0
+}
+
+// HasPrefix determines if prefix's node-identifiers match the leading
+// node-identifiers of the instance-identifier. Nodes are compared as
+// they are by Equal, so their predicates must match exactly;
+// "/m:a/l" is not a prefix of "/m:a/l[k='x']/v". Both must be
+// absolute, or both relative stepping up the same number of nodes.
+func (i *InstanceID) HasPrefix(prefix *InstanceID) bool {
+	if prefix == nil || len(prefix.ids) > len(i.ids) ||
+		prefix.relative != i.relative || prefix.up != i.up {
+		return false
+	}
+	for n, id := range prefix.ids {
+		if !id.equal(i.ids[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// NodeInfo describes a node-identifier of an instance-identifier, as
+// returned by Nodes.
+type NodeInfo struct {
+	Prefix     string
+	Identifier string
+	Predicates []PredicateInfo
+}
+
+// PredicateInfo describes a predicate of a node-identifier. A
+// positional predicate has IsPosition set and its index in Position.
+// Otherwise the predicate matches the Key, qualified by KeyPrefix,
+// against Value; Key is "." for a leaf-list predicate. Wildcard is set,
+// and Value is "*", if the predicate matches any value.
+type PredicateInfo struct {
+	IsPosition bool
+	Position   int
+	KeyPrefix  string
+	Key        string
+	Value      string
+	Wildcard   bool
+}
+
+// Nodes returns a description of each of the instance-identifier's
+// node-identifiers and their predicates. Prefixes are reported whether
+// they were written explicitly or inherited from the previous node.
+// The returned slice is a copy, modifying it doesn't affect the
+// instance-identifier.
+func (i *InstanceID) Nodes() []NodeInfo {
+	out := make([]NodeInfo, 0, len(i.ids))
+	for _, id := range i.ids {
+		out = append(out, id.info())
+	}
+	return out
+}
+
+// Last returns a description of the instance-identifier's final
+// node-identifier and its predicates, as Nodes would for the last
+// node. The zero NodeInfo is returned for an instance-identifier with
+// no node-identifiers, such as that of a tree's root.
+func (i *InstanceID) Last() NodeInfo {
+	if i == nil || len(i.ids) == 0 {
+		return NodeInfo{}
+	}
+	return i.ids[len(i.ids)-1].info()
+}
+
+func (id *nodeID) info() NodeInfo {
+	out := NodeInfo{
+		Prefix:     id.prefix,
+		Identifier: id.identifier,
+	}
+	if id.predicates == nil {
+		return out
+	}
+	for _, pred := range id.predicates.preds {
+		switch sel := pred.instanceIDSelector.(type) {
+		case *posPredicate:
+			out.Predicates = append(out.Predicates, PredicateInfo{
+				IsPosition: true,
+				Position:   int(sel.pos),
+			})
+		case *exprPredicate:
+			out.Predicates = append(out.Predicates, PredicateInfo{
+				KeyPrefix: sel.nodeID.prefix,
+				Key:       sel.nodeID.identifier,
+				Value:     sel.value,
+				Wildcard:  sel.wildcard,
+			})
+		}
+	}
+	return out
+}
+
+// Append returns a new instance-identifier with nodeID added as the
+// final node-identifier. nodeID may include predicates. As when parsing,
+// a nodeID without a prefix inherits the prefix of the previous node.
+// Append panics with an "invalid instance identifier" error if nodeID
+// is not a valid node-identifier.
+func (i *InstanceID) Append(nodeID string) *InstanceID {
+	defer wrapInstanceIDPanic()
+	return i.push(nodeID)
+}
+
+// AppendIndex returns a new instance-identifier with a positional
+// predicate for pos added to the final node-identifier.
+// AppendIndex panics with an "invalid instance identifier" error if
+// pos is negative or i has no node-identifiers.
+func (i *InstanceID) AppendIndex(pos int) *InstanceID {
+	defer wrapInstanceIDPanic()
+	if pos < 0 {
+		panic(invalidInstanceID(ErrInvalidPosition,
+			"invalid position "+strconv.Itoa(pos)))
+	}
+	if len(i.ids) == 0 {
+		panic(ErrEmptyInstanceID)
+	}
+	return i.addPosPredicate(pos)
 }
 
 func (i *InstanceID) push(nodeIDstring string) *InstanceID {
@@ -167,7 +441,7 @@ func (i *InstanceID) addPosPredicate(pos int) *InstanceID {
 		last.predicates = &predicates{}
 	}
 	last.predicates.preds = append(last.predicates.preds, &predicate{
-		instanceIDSelector: &posPredicate{uint64(pos)},
+		instanceIDSelector: &posPredicate{int64(pos)},
 	})
 	return out
 }
@@ -193,12 +467,13 @@ type predicate struct {
 }
 
 type posPredicate struct {
-	pos uint64
+	pos int64
 }
 
 type exprPredicate struct {
-	nodeID *nodeID
-	value  string
+	nodeID   *nodeID
+	value    string
+	wildcard bool
 }
 
 // stringer exists so we don't need to import fmt for the definition
@@ -212,33 +487,18 @@ type stringer interface {
 // overkill so just parse the nodes inline to build a matcher.
 func (i *InstanceID) parse(input string) *InstanceID {
 	// instance-identifier = 1*("/" (node-identifier *predicate))
-	defer func() {
-		errstr := "invalid instance identifier"
-		v := recover()
-		if v == nil {
-			return
-		}
-		switch v := v.(type) {
-		case string:
-			errstr += ": " + v
-		case error:
-			errstr += ": " + v.Error()
-		case stringer:
-			errstr += ": " + v.String()
-		}
-		panic(errors.New(errstr))
-	}()
+	defer wrapInstanceIDPanic()
 
 	nodeIDstrings := i.getNodeIDStrings(input)
 	if len(nodeIDstrings) == 0 {
-		panic("must specify at least one node-identifier")
+		panic(ErrEmptyInstanceID)
 	}
 	if nodeIDstrings[0] != "" {
-		panic("must start with a \"/\"")
+		panic(ErrNotAbsolute)
 	}
 	nodeIDstrings = nodeIDstrings[1:]
 	if len(nodeIDstrings) == 0 {
-		panic("must specify at least one node-identifier")
+		panic(ErrEmptyInstanceID)
 	}
 	nodeIDs := make([]*nodeID, 0, len(nodeIDstrings))
 	node := &nodeID{}
@@ -253,33 +513,321 @@ func (i *InstanceID) parse(input string) *InstanceID {
 	return i
 }
 
+// relativePrefix stands in for the prefix of the leading nodes of a
+// relative instance-identifier until it is resolved, it can't occur
+// in a valid prefix.
+const relativePrefix = "\x00"
+
+func (i *InstanceID) parseRelative(input string) *InstanceID {
+	defer wrapInstanceIDPanic()
+
+	if strings.HasPrefix(input, "/") {
+		panic(ErrNotRelative)
+	}
+	nodeIDstrings := i.getNodeIDStrings(input)
+	if len(nodeIDstrings) == 0 {
+		panic(ErrEmptyInstanceID)
+	}
+	i.relative = true
+	for len(nodeIDstrings) > 0 && nodeIDstrings[0] == ".." {
+		i.up++
+		nodeIDstrings = nodeIDstrings[1:]
+	}
+	node := &nodeID{prefix: relativePrefix}
+	for _, nodeIDstring := range nodeIDstrings {
+		prefix := node.prefix
+		node = &nodeID{}
+		node.parse(prefix, nodeIDstring)
+		i.ids = append(i.ids, node)
+	}
+	return i
+}
+
+// mustBeAbsolute panics with an *InstanceIDError if i is relative, so
+// that an unresolved instance-identifier can't be used to address a
+// node.
+func (i *InstanceID) mustBeAbsolute() {
+	if i.relative {
+		panic(invalidInstanceID(ErrUnresolved,
+			i.String()+" must be resolved before use"))
+	}
+}
+
+// Resolve joins a relative instance-identifier onto base, returning the
+// resulting absolute instance-identifier. Each leading ".." removes the
+// last node-identifier of base and the remaining node-identifiers are
+// appended to what is left; those without a prefix inherit the prefix
+// of the node they follow. An instance-identifier that isn't relative
+// is returned unchanged. Resolve panics with an "invalid instance
+// identifier" error if the relative instance-identifier steps above
+// the first node of base or its first node-identifier can't inherit a
+// prefix.
+func (i *InstanceID) Resolve(base *InstanceID) *InstanceID {
+	if !i.relative {
+		return i
+	}
+	if i.up > len(base.ids) {
+		panic(invalidInstanceID(ErrAboveRoot,
+			i.String()+" is above the root of "+base.String()))
+	}
+	ss := make([]string, 0, len(base.ids)-i.up+len(i.ids))
+	for _, id := range base.ids[:len(base.ids)-i.up] {
+		ss = append(ss, id.String())
+	}
+	for _, id := range i.ids {
+		ss = append(ss, id.String())
+	}
+	return InstanceIDNew("/" + strings.Join(ss, "/"))
+}
+
+// The causes of an InstanceIDError.
+var (
+	// ErrEmptyInstanceID means there were no node-identifiers.
+	ErrEmptyInstanceID = errors.New(
+		"must specify at least one node-identifier")
+	// ErrNotAbsolute means an instance-identifier didn't start
+	// with a "/".
+	ErrNotAbsolute = errors.New("must start with a \"/\"")
+	// ErrNotRelative means a relative instance-identifier started
+	// with a "/".
+	ErrNotRelative = errors.New(
+		"relative instance identifier must not start with a \"/\"")
+	// ErrAboveRoot means a relative instance-identifier stepped
+	// above the first node of the base it was resolved against.
+	ErrAboveRoot = errors.New("above the root")
+	// ErrUnterminatedQuote means a predicate value was missing its
+	// closing quote.
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+	// ErrMissingPrefix means the first node-identifier had no prefix
+	// and there was none to inherit.
+	ErrMissingPrefix = errors.New("unable to determine prefix")
+	// ErrInvalidNodeID means a prefix or identifier contained
+	// characters that aren't allowed or started with "xml".
+	ErrInvalidNodeID = errors.New("invalid node-identifier")
+	// ErrNestedPredicate means a predicate contained another.
+	ErrNestedPredicate = errors.New("nested predicates are not allowed")
+	// ErrUnterminatedPredicate means a predicate was missing its
+	// closing "]".
+	ErrUnterminatedPredicate = errors.New("unterminated predicate")
+	// ErrInvalidPredicate means a predicate was neither a valid
+	// position nor a valid predicate-expr.
+	ErrInvalidPredicate = errors.New("invalid predicate")
+	// ErrInvalidPosition means a negative position was appended.
+	ErrInvalidPosition = errors.New("invalid position")
+	// ErrUnresolved means a relative instance-identifier was used to
+	// address a node without first being resolved.
+	ErrUnresolved = errors.New(
+		"relative instance identifier must be resolved")
+)
+
+// InstanceIDError is the error returned by InstanceIDParse, and that
+// the other functions parsing instance-identifiers panic with, when
+// the input isn't valid. Err is one of the Err* errors and
+// Reason describes the problem in more detail.
+type InstanceIDError struct {
+	Err    error
+	Reason string
+}
+
+func invalidInstanceID(err error, reason string) *InstanceIDError {
+	return &InstanceIDError{Err: err, Reason: reason}
+}
+
+// Error returns the reason the instance-identifier is invalid.
+func (e *InstanceIDError) Error() string {
+	return "invalid instance identifier: " + e.Reason
+}
+
+// Unwrap returns the cause of the error.
+func (e *InstanceIDError) Unwrap() error {
+	return e.Err
+}
+
+// wrapInstanceIDPanic must be deferred. It converts panics raised
+// while parsing into an *InstanceIDError, wrapping any other error,
+// and re-panics with it.
+func wrapInstanceIDPanic() {
+	errstr := "invalid instance identifier"
+	v := recover()
+	if v == nil {
+		return
+	}
+	switch v := v.(type) {
+	case *InstanceIDError:
+		panic(v)
+	case error:
+		panic(invalidInstanceID(v, v.Error()))
+	case string:
+		errstr += ": " + v
+	case stringer:
+		errstr += ": " + v.String()
+	}
+	panic(errors.New(errstr))
+}
+
+// quoteScanner tracks whether the runes of an instance-identifier are
+// within a quoted predicate value. A value is only ended by the kind of
+// quote that started it.
+type quoteScanner struct {
+	quote rune
+}
+
+// scan consumes r and returns whether it is outside of a quoted value.
+func (s *quoteScanner) scan(r rune) bool {
+	switch {
+	case s.quote == 0:
+		if r != '\'' && r != '"' {
+			return true
+		}
+		s.quote = r
+	case r == s.quote:
+		s.quote = 0
+	}
+	return false
+}
+
+func (s *quoteScanner) inQuote() bool {
+	return s.quote != 0
+}
+
 func (i *InstanceID) getNodeIDStrings(input string) []string {
-	var inSingleQ, inDoubleQ bool
+	var quotes quoteScanner
 	var out []string
 	var first int
 	for i, r := range input {
-		switch r {
-		case '\'':
-			inSingleQ = !inSingleQ
-		case '"':
-			inDoubleQ = !inDoubleQ
-		case '/':
-			if !inDoubleQ && !inSingleQ {
-				out = append(out, input[first:i])
-				first = i + 1
-			}
-		default:
+		if quotes.scan(r) && r == '/' {
+			out = append(out, input[first:i])
+			first = i + 1
 		}
 	}
 	if first < len(input) {
 		out = append(out, input[first:len(input)])
 	}
-	if inDoubleQ || inSingleQ {
-		panic("unterminated quote")
+	if quotes.inQuote() {
+		panic(ErrUnterminatedQuote)
 	}
 	return out
 }
 
+func (id *nodeID) equal(other *nodeID) bool {
+	return id.prefix == other.prefix &&
+		id.identifier == other.identifier &&
+		id.predicates.equal(other.predicates)
+}
+
+func (p *predicates) equal(other *predicates) bool {
+	var preds, otherPreds []*predicate
+	if p != nil {
+		preds = p.preds
+	}
+	if other != nil {
+		otherPreds = other.preds
+	}
+	if len(preds) != len(otherPreds) {
+		return false
+	}
+	for i, pred := range preds {
+		if !pred.equal(otherPreds[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *predicate) equal(other *predicate) bool {
+	switch sel := p.instanceIDSelector.(type) {
+	case *posPredicate:
+		osel, ok := other.instanceIDSelector.(*posPredicate)
+		return ok && sel.pos == osel.pos
+	case *exprPredicate:
+		osel, ok := other.instanceIDSelector.(*exprPredicate)
+		return ok && sel.nodeID.prefix == osel.nodeID.prefix &&
+			sel.nodeID.identifier == osel.nodeID.identifier &&
+			sel.value == osel.value &&
+			sel.wildcard == osel.wildcard
+	default:
+		return false
+	}
+}
+
+func (id *nodeID) compare(other *nodeID) int {
+	if c := strings.Compare(id.prefix, other.prefix); c != 0 {
+		return c
+	}
+	if c := strings.Compare(id.identifier, other.identifier); c != 0 {
+		return c
+	}
+	return id.predicates.compare(other.predicates)
+}
+
+func (p *predicates) compare(other *predicates) int {
+	var preds, otherPreds []*predicate
+	if p != nil {
+		preds = p.preds
+	}
+	if other != nil {
+		otherPreds = other.preds
+	}
+	for i, pred := range preds {
+		if i == len(otherPreds) {
+			return 1
+		}
+		if c := pred.compare(otherPreds[i]); c != 0 {
+			return c
+		}
+	}
+	if len(preds) < len(otherPreds) {
+		return -1
+	}
+	return 0
+}
+
+func (p *predicate) compare(other *predicate) int {
+	switch sel := p.instanceIDSelector.(type) {
+	case *posPredicate:
+		osel, ok := other.instanceIDSelector.(*posPredicate)
+		switch {
+		case !ok:
+			return -1
+		case sel.pos < osel.pos:
+			return -1
+		case sel.pos > osel.pos:
+			return 1
+		default:
+			return 0
+		}
+	case *exprPredicate:
+		osel, ok := other.instanceIDSelector.(*exprPredicate)
+		if !ok {
+			return 1
+		}
+		return sel.compare(osel)
+	default:
+		return 0
+	}
+}
+
+// compare orders expression predicates by key and then value, a
+// wildcard sorts after every value.
+func (p *exprPredicate) compare(other *exprPredicate) int {
+	if c := strings.Compare(p.nodeID.prefix, other.nodeID.prefix); c != 0 {
+		return c
+	}
+	c := strings.Compare(p.nodeID.identifier, other.nodeID.identifier)
+	switch {
+	case c != 0:
+		return c
+	case p.wildcard && other.wildcard:
+		return 0
+	case p.wildcard:
+		return 1
+	case other.wildcard:
+		return -1
+	default:
+		return strings.Compare(p.value, other.value)
+	}
+}
+
 func (id *nodeID) parse(prefix, input string) *nodeID {
 	// (node-identifier *predicate)
 	// node-identifier     = [prefix ":"] identifier
@@ -292,7 +840,7 @@ func (id *nodeID) parse(prefix, input string) *nodeID {
 			id.prefix = prefix
 			id.prefixInferred = true
 		} else {
-			panic("unable to determine prefix")
+			panic(ErrMissingPrefix)
 		}
 	case 2:
 		id.prefix, id.identifier = idParts[0], idParts[1]
@@ -300,7 +848,10 @@ func (id *nodeID) parse(prefix, input string) *nodeID {
 			id.prefixInferred = true
 		}
 	}
-	id.checkIDPart(id.prefix)
+	if !id.prefixInferred {
+		// An inherited prefix was checked on the earlier node.
+		id.checkIDPart(id.prefix)
+	}
 	if strings.ContainsRune(id.identifier, '[') {
 		predsStart := strings.IndexRune(id.identifier, '[')
 		predString := id.identifier[predsStart:]
@@ -314,12 +865,13 @@ func (id *nodeID) parse(prefix, input string) *nodeID {
 func (id *nodeID) checkIDPart(str string) {
 	// identifier          = (ALPHA / "_")
 	//                 *(ALPHA / DIGIT / "_" / "-" / ".")
-	errInval := errors.New("invalid node-identifier " + str)
+	errInval := invalidInstanceID(ErrInvalidNodeID,
+		"invalid node-identifier "+str)
 
 	if len(str) >= 3 {
 		if strings.ToUpper(str[:3]) == "XML" {
-			panic(errors.New("invalid identifier," +
-				" not allowed to start with xml: " + str))
+			panic(invalidInstanceID(ErrInvalidNodeID, "invalid identifier,"+
+				" not allowed to start with xml: "+str))
 		}
 	}
 	for i, r := range str {
@@ -347,36 +899,31 @@ func (p *predicates) parse(prefix, input string) *predicates {
 }
 
 func (p *predicates) getPredicateStrings(input string) []string {
-	var inSingleQ, inDoubleQ, inPredicate bool
+	var quotes quoteScanner
+	var inPredicate bool
 	var out []string
 	var first int
 	for i, r := range input {
+		if !quotes.scan(r) {
+			continue
+		}
 		switch r {
 		case '[':
-			if !inDoubleQ && !inSingleQ {
-				if inPredicate {
-					panic("nested predicates are not allowed")
-				}
-				inPredicate = true
+			if inPredicate {
+				panic(ErrNestedPredicate)
 			}
+			inPredicate = true
 		case ']':
-			if !inDoubleQ && !inSingleQ {
-				out = append(out, input[first:i+1])
-				first = i + 1
-				inPredicate = false
-			}
-		case '\'':
-			inSingleQ = !inSingleQ
-		case '"':
-			inDoubleQ = !inDoubleQ
-		default:
+			out = append(out, input[first:i+1])
+			first = i + 1
+			inPredicate = false
 		}
 	}
-	if inDoubleQ || inSingleQ {
-		panic("unterminated quote")
+	if quotes.inQuote() {
+		panic(ErrUnterminatedQuote)
 	}
 	if inPredicate {
-		panic("unterminated predicate")
+		panic(ErrUnterminatedPredicate)
 	}
 	return out
 }
@@ -384,12 +931,12 @@ func (p *predicates) getPredicateStrings(input string) []string {
 func (p *predicate) parse(prefix, input string) *predicate {
 	// predicate           = "[" *WSP (predicate-expr / pos) *WSP "]"
 	if input[0] != '[' || input[len(input)-1] != ']' {
-		panic("invalid predicate \"" + input + "\"")
+		panic(invalidInstanceID(ErrInvalidPredicate,
+			"invalid predicate \""+input+"\""))
 	}
 	input = strings.Trim(input, "[]")
 	input = strings.Trim(input, wsp)
-	_, err := strconv.ParseUint(input, 10, 64)
-	if err == nil {
+	if isPosition(input) || input == "last()" {
 		p.instanceIDSelector = (&posPredicate{}).parse(prefix, input)
 	} else {
 		p.instanceIDSelector = (&exprPredicate{}).parse(prefix, input)
@@ -398,13 +945,32 @@ func (p *predicate) parse(prefix, input string) *predicate {
 	return p
 }
 
+// isPosition reports whether input is an integer-value. Only a
+// negative position may be signed, so "+1" and "-0" are not positions.
+func isPosition(input string) bool {
+	digits := strings.TrimPrefix(input, "-")
+	if digits == "" || (digits != input && strings.Trim(digits, "0") == "") {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *posPredicate) parse(prefix, input string) *posPredicate {
-	// pos                 = non-negative-integer-value
-	u, err := strconv.ParseUint(input, 10, 64)
+	// pos                 = integer-value / "last()"
+	if input == "last()" {
+		p.pos = -1
+		return p
+	}
+	i, err := strconv.ParseInt(input, 10, 64)
 	if err != nil {
-		panic(err)
+		panic(invalidInstanceID(ErrInvalidPredicate, err.Error()))
 	}
-	p.pos = u
+	p.pos = i
 	return p
 }
 
@@ -414,7 +980,8 @@ func (p *exprPredicate) parse(prefix, input string) *exprPredicate {
 	//                          (SQUOTE string SQUOTE))
 	exprParts := strings.SplitN(input, "=", 2)
 	if len(exprParts) < 2 {
-		panic("invalid predicate expression " + input)
+		panic(invalidInstanceID(ErrInvalidPredicate,
+			"invalid predicate expression "+input))
 	}
 	for i, v := range exprParts {
 		exprParts[i] = strings.Trim(v, wsp)
@@ -429,31 +996,51 @@ func (p *exprPredicate) parse(prefix, input string) *exprPredicate {
 		p.nodeID = (&nodeID{}).parse(prefix, exprParts[0])
 	}
 	expr := exprParts[1]
+	if expr == "*" {
+		p.wildcard = true
+		p.value = expr
+		return p
+	}
 	var end int
-	switch expr[0] {
-	case '"':
-		end = strings.IndexRune(expr[1:], '"')
-	case '\'':
-		end = strings.IndexRune(expr[1:], '\'')
+	switch quote := rune(expr[0]); quote {
+	case '"', '\'':
+		end = strings.IndexRune(expr[1:], quote)
 	default:
-		panic("invalid predicate, expected ''' or '\"'")
+		panic(invalidInstanceID(ErrInvalidPredicate,
+			"invalid predicate, expected ''' or '\"'"))
 	}
-	expr = expr[1:]
-	if end != len(expr)-1 {
-		panic("unterminated expression value")
+	if end < 0 || end != len(expr)-2 {
+		panic(invalidInstanceID(ErrInvalidPredicate,
+			"unterminated expression value"))
 	}
-	expr = expr[0:end]
-	p.value = expr
+	p.value = expr[1 : end+1]
+	p.wildcard = p.value == "*"
 	return p
 }
 
+// predicateValue returns the text of value that is compared with the
+// value of a predicate. Strings are used as they are, RFC7951String
+// would escape any quotes or backslashes within them.
+func predicateValue(value *Value) string {
+	if str, isString := value.data.(string); isString {
+		return str
+	}
+	return value.RFC7951String()
+}
+
 // String will format an instance-identifier as a string.
 // This instance-identifier is normalized to the RFC7951 spec.
 func (i *InstanceID) String() string {
-	ss := make([]string, 0, len(i.ids))
+	ss := make([]string, 0, i.up+len(i.ids))
+	for n := 0; n < i.up; n++ {
+		ss = append(ss, "..")
+	}
 	for _, id := range i.ids {
 		ss = append(ss, id.String())
 	}
+	if i.relative {
+		return strings.Join(ss, "/")
+	}
 	return "/" + strings.Join(ss, "/")
 }
 
@@ -483,10 +1070,16 @@ func (p *predicate) String() string {
 }
 
 func (p *posPredicate) String() string {
-	return strconv.FormatUint(p.pos, 10)
+	return strconv.FormatInt(p.pos, 10)
 }
 
 func (p *exprPredicate) String() string {
+	if p.wildcard {
+		return p.nodeID.String() + "=*"
+	}
+	if strings.ContainsRune(p.value, '\'') {
+		return p.nodeID.String() + "=" + `"` + p.value + `"`
+	}
 	return p.nodeID.String() + "=" + "'" + p.value + "'"
 }
 
@@ -498,18 +1091,74 @@ func (i *InstanceID) RFC7951String() string {
 }
 
 // Find will traverse the tree to find the Value
-// to which the instance-identifier refers.
+// to which the instance-identifier refers. Find panics with an
+// *InstanceIDError if the instance-identifier is relative, it must be
+// resolved first.
 func (i *InstanceID) Find(value *Value) (*Value, bool) {
+	i.mustBeAbsolute()
 	var found bool
-	for _, nodeID := range i.ids {
+	for n, nodeID := range i.ids {
 		value, found = nodeID.Find(value)
 		if !found {
 			return nil, false
 		}
+		if nodeID.hasWildcard() {
+			return (&InstanceID{ids: i.ids[n+1:]}).findAll(value)
+		}
 	}
 	return value, found
 }
 
+// findAll applies the instance-identifier to each of the matches of a
+// wildcard and collects the results into a single array.
+func (i *InstanceID) findAll(matches *Value) (*Value, bool) {
+	if len(i.ids) == 0 {
+		return matches, true
+	}
+	out := ArrayNew().Transform(func(out *TArray) {
+		matches.AsArray().Range(func(match *Value) {
+			v, found := i.Find(match)
+			if !found {
+				return
+			}
+			if i.hasWildcard() {
+				v.AsArray().Range(func(v *Value) {
+					out.Append(v)
+				})
+				return
+			}
+			out.Append(v)
+		})
+	})
+	return ValueNew(out), out.Length() != 0
+}
+
+func (i *InstanceID) hasWildcard() bool {
+	for _, id := range i.ids {
+		if id.hasWildcard() {
+			return true
+		}
+	}
+	return false
+}
+
+func (id *nodeID) hasWildcard() bool {
+	return id.predicates.hasWildcard()
+}
+
+func (p *predicates) hasWildcard() bool {
+	if p == nil {
+		return false
+	}
+	for _, pred := range p.preds {
+		expr, isExpr := pred.instanceIDSelector.(*exprPredicate)
+		if isExpr && expr.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
 func (id *nodeID) Find(value *Value) (*Value, bool) {
 	if value == nil {
 		return nil, false
@@ -550,6 +1199,13 @@ func (p *predicates) Find(value *Value) (*Value, bool) {
 			break
 		}
 	}
+	if p.hasWildcard() {
+		// A wildcard always results in the array of all matches.
+		if found && !out.IsArray() {
+			out = ValueNew(ArrayWith(out))
+		}
+		return out, found && out.AsArray().Length() != 0
+	}
 	ret := ValueNew(out.Perform(
 		func(arr *Array) *Value {
 			if arr.Length() != 1 {
@@ -580,10 +1236,14 @@ func (p *posPredicate) Find(value *Value) (*Value, bool) {
 func (p *exprPredicate) Find(value *Value) (*Value, bool) {
 	var found bool
 	out := ValueNew(value.Perform(func(a *Array) *Value {
+		if p.wildcard {
+			found = true
+			return ValueNew(a.selectItems(p.matches))
+		}
 		if p.nodeID.identifier == "." {
 			//only leaf-lists can be referenced this way
 			return a.detect(func(value *Value) bool {
-				found = value.RFC7951String() == p.value
+				found = predicateValue(value) == p.value
 				return found
 			})
 		}
@@ -593,7 +1253,7 @@ func (p *exprPredicate) Find(value *Value) (*Value, bool) {
 			if !foundSelector {
 				return false
 			}
-			matched := value.RFC7951String() == p.value
+			matched := predicateValue(value) == p.value
 			found = found || matched
 			return matched
 		}))
@@ -634,86 +1294,159 @@ func (id *nodeID) computeIdentifier(value *Value) interface{} {
 
 func (p *predicates) computeIdentifier(value *Value) interface{} {
 	return value.Perform(func(a *Array) interface{} {
-		// Start with all indicies matched
-		matched := make(map[int]struct{})
-		for i := 0; i < a.Length(); i++ {
-			matched[i] = struct{}{}
-		}
-		for _, pred := range p.preds {
-			id := pred.computeIdentifier(value)
-			if id == nil {
-				matched = map[int]struct{}{}
-				break
-			}
-			switch v := id.(type) {
-			case []int:
-				// We got more than one match, filter the
-				// previously matched indicies based on the
-				// ones matched by the current predicate.
-				got := make(map[int]struct{})
-				for _, id := range v {
-					_, seen := matched[id]
-					if seen {
-						got[id] = struct{}{}
-					}
-				}
-				matched = got
-			case int:
-				got := make(map[int]struct{})
-				_, seen := matched[v]
+		return p.index(a)
+	})
+}
+
+// elements is the read access to the entries of a list or leaf-list
+// that predicates need to select them. It is implemented by both
+// *Array and *TArray so that a TTree may select entries of a list
+// without making it persistent.
+type elements interface {
+	Length() int
+	At(int) *Value
+}
+
+// elementSelector is implemented by each kind of predicate.
+type elementSelector interface {
+	index(elements) interface{}
+}
+
+// index returns the index of the single element of arr matched by
+// every one of the predicates, or nil if there isn't exactly one.
+func (p *predicates) index(arr elements) interface{} {
+	matched := p.matchElements(arr)
+	// If we fully matched more than one index then the id
+	// is not valid
+	if len(matched) != 1 {
+		return nil
+	}
+	return matched[0]
+}
+
+// matchIndices returns the indices of all of the elements of the array
+// value that are matched by every one of the predicates, in ascending
+// order.
+func (p *predicates) matchIndices(value *Value) []int {
+	out, _ := value.Perform(func(a *Array) []int {
+		return p.matchElements(a)
+	}).([]int)
+	return out
+}
+
+func (p *predicates) matchElements(arr elements) []int {
+	// Start with all indicies matched
+	matched := make(map[int]struct{})
+	for i := 0; i < arr.Length(); i++ {
+		matched[i] = struct{}{}
+	}
+	for _, pred := range p.preds {
+		id := pred.instanceIDSelector.(elementSelector).index(arr)
+		if id == nil {
+			return nil
+		}
+		switch v := id.(type) {
+		case []int:
+			// We got more than one match, filter the
+			// previously matched indicies based on the
+			// ones matched by the current predicate.
+			got := make(map[int]struct{})
+			for _, id := range v {
+				_, seen := matched[id]
 				if seen {
-					got[v] = struct{}{}
+					got[id] = struct{}{}
 				}
-				matched = got
 			}
-
-		}
-		// If we fully matched more than one index then the id
-		// is not valid
-		if len(matched) > 1 || len(matched) == 0 {
-			return nil
+			matched = got
+		case int:
+			got := make(map[int]struct{})
+			_, seen := matched[v]
+			if seen {
+				got[v] = struct{}{}
+			}
+			matched = got
 		}
-		// Extract the single matched index from the map.
-		var out int
-		for out = range matched {
+
+	}
+	out := make([]int, 0, len(matched))
+	for i := range matched {
+		out = append(out, i)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// selectElements returns the indices of the elements of arr for which
+// fn returns true.
+func selectElements(arr elements, fn func(*Value) bool) []int {
+	out := []int{}
+	for i := 0; i < arr.Length(); i++ {
+		if fn(arr.At(i)) {
+			out = append(out, i)
 		}
-		return out
-	})
+	}
+	return out
 }
 
 func (p *posPredicate) computeIdentifier(value *Value) interface{} {
-	return int(p.pos)
+	if p.pos >= 0 {
+		return int(p.pos)
+	}
+	return value.Perform(func(arr *Array) interface{} {
+		return p.index(arr)
+	})
+}
+
+func (p *posPredicate) index(arr elements) interface{} {
+	if p.pos >= 0 {
+		return int(p.pos)
+	}
+	pos := arr.Length() + int(p.pos)
+	if pos < 0 {
+		return nil
+	}
+	return pos
+}
+
+// matches reports whether an array element is selected by the
+// predicate's wildcard. Every leaf-list entry matches, list entries
+// match if they contain the predicate's key.
+func (p *exprPredicate) matches(value *Value) bool {
+	if p.nodeID.identifier == "." {
+		return true
+	}
+	_, found := p.nodeID.Find(value)
+	return found
 }
 
 func (p *exprPredicate) computeIdentifier(value *Value) interface{} {
 	return value.Perform(func(arr *Array) interface{} {
-		if p.nodeID.identifier == "." {
-			//only leaf-lists can be referenced this way
-			ret := []int{}
-			arr.Range(func(idx int, value *Value) {
-				if value.RFC7951String() == p.value {
-					ret = append(ret, idx)
-				}
-			})
-			if len(ret) == 1 {
-				return ret[0]
-			}
-			return ret
-		}
+		return p.index(arr)
+	})
+}
+
+func (p *exprPredicate) index(arr elements) interface{} {
+	var ret []int
+	switch {
+	case p.wildcard:
+		return selectElements(arr, p.matches)
+	case p.nodeID.identifier == ".":
+		//only leaf-lists can be referenced this way
+		ret = selectElements(arr, func(value *Value) bool {
+			return predicateValue(value) == p.value
+		})
+	default:
 		//only lists can be referenced this way
-		ret := []int{}
-		arr.Range(func(idx int, value *Value) {
+		ret = selectElements(arr, func(value *Value) bool {
 			value, found := p.nodeID.Find(value)
-			if found && value != nil &&
-				value.RFC7951String() == p.value {
-				ret = append(ret, idx)
-			}
+			return found && value != nil &&
+				predicateValue(value) == p.value
 		})
-		if len(ret) == 1 {
-			return ret[0]
-		}
-		return ret
-	})
+	}
+	if len(ret) == 1 {
+		return ret[0]
+	}
+	return ret
 }
 
 func (id *nodeID) computeIdentifierDefault(v *Value) interface{} {
@@ -725,14 +1458,20 @@ func (id *nodeID) computeIdentifierDefault(v *Value) interface{} {
 }
 
 func (p *predicates) computeIdentifierDefault(v *Value) interface{} {
-	id := p.computeIdentifier(v)
+	return v.Perform(func(a *Array) interface{} {
+		return p.indexDefault(a)
+	}, func(_ interface{}) interface{} {
+		return 0
+	})
+}
+
+// indexDefault is like index but returns the length of arr, so that
+// a new element is appended, if no element matches.
+func (p *predicates) indexDefault(arr elements) interface{} {
+	id := p.index(arr)
 	if id == nil {
-		return v.Perform(func(a *Array) int {
-			// Append by default
-			return a.Length()
-		}, func(_ interface{}) int {
-			return 0
-		})
+		// Append by default
+		return arr.Length()
 	}
 	return id
 }
@@ -740,7 +1479,7 @@ func (p *predicates) computeIdentifierDefault(v *Value) interface{} {
 func (p *posPredicate) computeIdentifierDefault(v *Value) interface{} {
 	id := p.computeIdentifier(v)
 	if id == nil {
-		return 0
+		panic(errors.New("position " + p.String() + " out of range"))
 	}
 	return id
 }
@@ -767,7 +1506,7 @@ func (p *predicates) modifyMatchCriteria(v *Value) *Value {
 }
 
 func (p *exprPredicate) modifyMatchCriteria(v *Value) *Value {
-	if p.nodeID.identifier == "." {
+	if p.nodeID.identifier == "." || p.wildcard {
 		return v
 	}
 	return v.Perform(func(o *Object) *Value {
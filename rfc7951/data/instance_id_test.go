@@ -6,7 +6,13 @@
 package data
 
 import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	"jsouthworth.net/go/try"
 )
 
 func TestInstanceIDParsing(t *testing.T) {
@@ -39,6 +45,8 @@ func TestInstanceIDParsing(t *testing.T) {
 	runTest("/m:foo/bar[id=\"baz\"][id2=\"quux\"]",
 		"/m:foo/bar[id='baz'][id2='quux']")
 	runTest("/m:foo[0]", "/m:foo[0]")
+	runTest("/m:foo[-1]", "/m:foo[-1]")
+	runTest("/m:foo[last()]", "/m:foo[-1]")
 	runTest("/m:foo[.='123']", "/m:foo[.='123']")
 }
 
@@ -87,6 +95,152 @@ func TestInstanceIDParsingFailures(t *testing.T) {
 	tFunc("/m:foo[b=c]", "invalid instance identifier: invalid predicate, expected ''' or '\"'")
 }
 
+func TestInstanceIDParse(t *testing.T) {
+	id, err := InstanceIDParse("/m:foo[name='bar']/baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(id, InstanceIDNew("/m:foo[name='bar']/baz")) {
+		t.Fatalf("expected: /m:foo[name='bar']/baz\ngot: %s\n", id)
+	}
+	failures := []struct {
+		input    string
+		expected error
+	}{
+		{"", ErrEmptyInstanceID},
+		{"/", ErrEmptyInstanceID},
+		{"foo", ErrNotAbsolute},
+		{"/foo", ErrMissingPrefix},
+		{"/foo[id='foo]", ErrUnterminatedQuote},
+		{"/xml2:m", ErrInvalidNodeID},
+		{"/foo?:m", ErrInvalidNodeID},
+		{"/m:foo[b[a='b']='c']", ErrNestedPredicate},
+		{"/m:foo[b='c'", ErrUnterminatedPredicate},
+		{"/m:foo[b]", ErrInvalidPredicate},
+		{"/m:foo[b=c]", ErrInvalidPredicate},
+		{"/m:foo[b='c'd]", ErrInvalidPredicate},
+		{"/m:foo[99999999999999999999]", ErrInvalidPredicate},
+		{"/m:foo[+1]", ErrInvalidPredicate},
+		{"/m:foo[-0]", ErrInvalidPredicate},
+		{"/m:foo[-]", ErrInvalidPredicate},
+	}
+	for _, test := range failures {
+		t.Run(test.input, func(t *testing.T) {
+			id, err := InstanceIDParse(test.input)
+			if id != nil {
+				t.Fatalf("expected nil, got: %s", id)
+			}
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("expected: %v\ngot: %v\n", test.expected, err)
+			}
+			var iidErr *InstanceIDError
+			if !errors.As(err, &iidErr) || iidErr.Err != test.expected {
+				t.Fatalf("expected an *InstanceIDError, got: %#v", err)
+			}
+			_, panicked := try.Apply(InstanceIDNew, test.input)
+			if panicked == nil || panicked.Error() != err.Error() {
+				t.Fatalf("expected: %v\ngot: %v\n", err, panicked)
+			}
+		})
+	}
+	t.Run("other functions", func(t *testing.T) {
+		_, err := try.Apply(RelativeInstanceIDNew, "/m:foo")
+		if !errors.Is(err, ErrNotRelative) {
+			t.Fatalf("expected: %v\ngot: %v\n", ErrNotRelative, err)
+		}
+		_, err = try.Apply((*InstanceID).Resolve,
+			RelativeInstanceIDNew("../../bar"), InstanceIDNew("/m:foo"))
+		if !errors.Is(err, ErrAboveRoot) {
+			t.Fatalf("expected: %v\ngot: %v\n", ErrAboveRoot, err)
+		}
+		_, err = try.Apply((*InstanceID).AppendIndex,
+			InstanceIDNew("/m:foo"), -1)
+		if !errors.Is(err, ErrInvalidPosition) {
+			t.Fatalf("expected: %v\ngot: %v\n", ErrInvalidPosition, err)
+		}
+	})
+}
+
+func TestInstanceIDQuotes(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		value    string
+		expected string
+	}{
+		{"double quote in single quotes", `/m:foo[name='say "hi"']/leaf`,
+			`say "hi"`, `/m:foo[name='say "hi"']/leaf`},
+		{"single quote in double quotes", `/m:foo[name="it's"]/leaf`,
+			`it's`, `/m:foo[name="it's"]/leaf`},
+		{"trailing backslash", `/m:foo[name="a\"]/leaf`,
+			`a\`, `/m:foo[name='a\']/leaf`},
+		{"double backslash", `/m:foo[name="a\\b"]/leaf`,
+			`a\\b`, `/m:foo[name='a\\b']/leaf`},
+		{"backslash and single quote", `/m:foo[name="it's a \"]/leaf`,
+			`it's a \`, `/m:foo[name="it's a \"]/leaf`},
+		{"brackets and slashes", `/m:foo[name="[it's/here]"]/leaf`,
+			`[it's/here]`, `/m:foo[name="[it's/here]"]/leaf`},
+		{"leaf-list value", `/m:foo[.="it's"]`,
+			`it's`, `/m:foo[.="it's"]`},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			id := InstanceIDNew(test.input)
+			if got := id.String(); got != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+			if !equal(InstanceIDNew(id.String()), id) {
+				t.Fatalf("%s didn't round-trip", id)
+			}
+			obj := ObjectWith(
+				PairNew("m:foo", ArrayWith(
+					ObjectWith(PairNew("name", "other"),
+						PairNew("leaf", 1)),
+					ObjectWith(PairNew("name", test.value),
+						PairNew("leaf", 2)))))
+			if strings.HasSuffix(test.input, "]") {
+				obj = ObjectWith(PairNew("m:foo",
+					ArrayWith("other", test.value)))
+			}
+			got := id.MatchAgainst(ValueNew(obj))
+			if got == nil {
+				t.Fatalf("%s didn't match %q", id, test.value)
+			}
+		})
+	}
+	t.Run("tree", func(t *testing.T) {
+		path := `/m:foo[name="it's \x"]/leaf`
+		tree := TreeNew().Assoc(path, 1)
+		if got := tree.At(path); !equal(got, ValueNew(1)) {
+			t.Fatalf("expected: 1\ngot: %s\n", got)
+		}
+		key := tree.At("/m:foo[0]/name")
+		if !equal(key, ValueNew(`it's \x`)) {
+			t.Fatalf("expected: %s\ngot: %s\n", `it's \x`, key)
+		}
+	})
+	failures := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"unterminated single", `/m:foo[name='it's']`,
+			"invalid instance identifier: unterminated quote"},
+		{"both quotes", `/m:foo[name="it's "x""]`,
+			"invalid instance identifier: unterminated expression value"},
+		{"trailing characters", `/m:foo[name="a"b"]`,
+			"invalid instance identifier: unterminated quote"},
+	}
+	for _, test := range failures {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := try.Apply(InstanceIDNew, test.input)
+			if err == nil || err.Error() != test.expected {
+				t.Fatalf("expected: %s\ngot: %v\n", test.expected, err)
+			}
+		})
+	}
+}
+
 func TestInstanceIDMatchAgainst(t *testing.T) {
 	//Test Matching semantics against an example
 	obj := ObjectWith(
@@ -393,3 +547,602 @@ var TESTOBJ_STR = []string{
 	"/module-v1:leaf-list[6]",
 	"/module-v1:leaf",
 }
+
+func TestInstanceIDParent(t *testing.T) {
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"/module-v1:foo/bar/baz", "/module-v1:foo/bar"},
+		{"/module-v1:foo/bar[key='a']/baz", "/module-v1:foo/bar[key='a']"},
+		{"/module-v1:foo/bar[key='a']", "/module-v1:foo"},
+		{"/module-v1:foo/module-v2:bar", "/module-v1:foo"},
+		{"/module-v1:foo[0]/bar[1]", "/module-v1:foo[0]"},
+	}
+	for _, test := range cases {
+		t.Run(test.path, func(t *testing.T) {
+			iid := InstanceIDNew(test.path)
+			parent := iid.Parent()
+			if parent.String() != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, parent)
+			}
+			if iid.String() != InstanceIDNew(test.path).String() {
+				t.Fatalf("original was modified: %s\n", iid)
+			}
+		})
+	}
+	t.Run("single-element", func(t *testing.T) {
+		for _, path := range []string{
+			"/module-v1:foo",
+			"/module-v1:foo[key='a']",
+		} {
+			parent := InstanceIDNew(path).Parent()
+			if parent != nil {
+				t.Fatalf("expected nil parent for %s, got %s\n",
+					path, parent)
+			}
+		}
+	})
+}
+
+func TestInstanceIDAppend(t *testing.T) {
+	t.Run("Append", func(t *testing.T) {
+		cases := []struct {
+			base, node, expected string
+		}{
+			{"/module-v1:foo", "bar", "/module-v1:foo/bar"},
+			{"/module-v1:foo", "module-v1:bar", "/module-v1:foo/bar"},
+			{"/module-v1:foo", "module-v2:bar",
+				"/module-v1:foo/module-v2:bar"},
+			{"/module-v1:foo/module-v2:bar", "baz",
+				"/module-v1:foo/module-v2:bar/baz"},
+			{"/module-v1:foo", "bar[key='a']",
+				"/module-v1:foo/bar[key='a']"},
+		}
+		for _, test := range cases {
+			base := InstanceIDNew(test.base)
+			got := base.Append(test.node)
+			if got.String() != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, got)
+			}
+			if base.String() != test.base {
+				t.Fatalf("original was modified: %s\n", base)
+			}
+			if !got.Equal(InstanceIDNew(test.expected)) {
+				t.Fatalf("expected %s to equal parsed %s\n",
+					got, test.expected)
+			}
+		}
+	})
+	t.Run("AppendIndex", func(t *testing.T) {
+		base := InstanceIDNew("/module-v1:foo/bar")
+		got := base.AppendIndex(3)
+		if got.String() != "/module-v1:foo/bar[3]" {
+			t.Fatalf("expected: %s\ngot: %s\n",
+				"/module-v1:foo/bar[3]", got)
+		}
+		if base.String() != "/module-v1:foo/bar" {
+			t.Fatalf("original was modified: %s\n", base)
+		}
+		got = got.Append("baz").AppendIndex(0)
+		if got.String() != "/module-v1:foo/bar[3]/baz[0]" {
+			t.Fatalf("expected: %s\ngot: %s\n",
+				"/module-v1:foo/bar[3]/baz[0]", got)
+		}
+	})
+	t.Run("failures", func(t *testing.T) {
+		tFunc := func(name, expFailure string, fn func()) {
+			t.Run(name, func(t *testing.T) {
+				defer func() {
+					r := recover()
+					err, ok := r.(error)
+					if !ok {
+						t.Fatalf("expected error panic, got %v", r)
+					}
+					if err.Error() != expFailure {
+						t.Fatalf("expected: %s\ngot: %s\n",
+							expFailure, err)
+					}
+				}()
+				fn()
+			})
+		}
+		base := InstanceIDNew("/module-v1:foo")
+		tFunc("bad-identifier",
+			"invalid instance identifier: invalid node-identifier ?bar",
+			func() { base.Append("?bar") })
+		tFunc("xml-identifier",
+			"invalid instance identifier: invalid identifier, not allowed to start with xml: xmlbar",
+			func() { base.Append("xmlbar") })
+		tFunc("no-prefix",
+			"invalid instance identifier: unable to determine prefix",
+			func() { (&InstanceID{}).Append("bar") })
+		tFunc("negative-index",
+			"invalid instance identifier: invalid position -1",
+			func() { base.AppendIndex(-1) })
+	})
+}
+
+func TestInstanceIDWildcard(t *testing.T) {
+	obj := ObjectWith(
+		PairNew("module-v1:foo", ObjectWith(
+			PairNew("leaf-list", ArrayWith("quux", "foo")))),
+		PairNew("module-v2:baz", ArrayWith(
+			ObjectWith(
+				PairNew("quux", "foo"),
+				PairNew("baz", "bar")),
+			ObjectWith(
+				PairNew("quux", "bar"),
+				PairNew("baz", "foo")),
+			ObjectWith(
+				PairNew("quux", "bar"),
+				PairNew("baz", "baz")))),
+		PairNew("module-v2:nested", ArrayWith(
+			ObjectWith(
+				PairNew("name", "a"),
+				PairNew("inner", ArrayWith(
+					ObjectWith(PairNew("id", "1")),
+					ObjectWith(PairNew("id", "2"))))),
+			ObjectWith(
+				PairNew("name", "b"),
+				PairNew("inner", ArrayWith(
+					ObjectWith(PairNew("id", "3"))))))))
+	t.Run("parse", func(t *testing.T) {
+		for _, path := range []string{
+			"/module-v2:baz[quux=*]",
+			"/module-v2:baz[quux='*']",
+			"/module-v2:baz[quux=\"*\"]",
+			"/module-v2:baz[ quux = * ]",
+		} {
+			got := InstanceIDNew(path).String()
+			if got != "/module-v2:baz[quux=*]" {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					"/module-v2:baz[quux=*]", got)
+			}
+			if InstanceIDNew(got).String() != got {
+				t.Fatalf("%s did not round trip", got)
+			}
+		}
+	})
+	cases := []struct {
+		path     string
+		expected []string
+	}{
+		{"/module-v2:baz[quux=*]/baz", []string{"bar", "foo", "baz"}},
+		{"/module-v2:baz[quux='bar'][baz=*]/baz", []string{"foo", "baz"}},
+		{"/module-v2:baz[nope=*]/baz", nil},
+		{"/module-v1:foo/leaf-list[.=*]", []string{"quux", "foo"}},
+		{"/module-v2:nested[name=*]/inner[id=*]/id",
+			[]string{"1", "2", "3"}},
+		{"/module-v2:nested[name=*]/inner[id='3']/id", []string{"3"}},
+	}
+	val := ValueNew(obj)
+	for _, test := range cases {
+		t.Run(test.path, func(t *testing.T) {
+			v, found := InstanceIDNew(test.path).Find(val)
+			if test.expected == nil {
+				if found {
+					t.Fatalf("unexpected match %s", v)
+				}
+				return
+			}
+			if !found || !v.IsArray() {
+				t.Fatalf("expected array of matches, got %s", v)
+			}
+			var got []string
+			v.AsArray().Range(func(v *Value) {
+				got = append(got, v.RFC7951String())
+			})
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected: %v\ngot: %v\n",
+					test.expected, got)
+			}
+		})
+	}
+	t.Run("computeIdentifier", func(t *testing.T) {
+		iid := InstanceIDNew("/module-v2:baz[quux=*]")
+		arr := iid.path().MatchAgainst(val)
+		id := iid.ids[0].predicates.preds[0].computeIdentifier(arr)
+		if !reflect.DeepEqual(id, []int{0, 1, 2}) {
+			t.Fatalf("expected: %v\ngot: %v\n", []int{0, 1, 2}, id)
+		}
+	})
+}
+
+func TestInstanceIDEqual(t *testing.T) {
+	relative := func(path string) *InstanceID {
+		// Drop the first node leaving an instance-identifier whose
+		// first node has an inferred prefix.
+		iid := InstanceIDNew(path)
+		return &InstanceID{ids: iid.ids[1:]}
+	}
+	cases := []struct {
+		name     string
+		a, b     *InstanceID
+		expected bool
+	}{
+		{"leaf",
+			InstanceIDNew("/m:a/m:b"), InstanceIDNew("/m:a/b"), true},
+		{"leaf/inferred-first-node",
+			relative("/m:a/b/c"), relative("/m:x/m:b/m:c"), true},
+		{"leaf/different-prefix",
+			InstanceIDNew("/m:a/n:b"), InstanceIDNew("/m:a/b"), false},
+		{"list",
+			InstanceIDNew("/m:a/m:l[m:k='x']"),
+			InstanceIDNew("/m:a/l[k='x']"), true},
+		{"list/inferred-first-node",
+			relative("/m:a/l[k='x']"),
+			relative("/n:a/m:l[m:k='x']"), true},
+		{"list/different-value",
+			InstanceIDNew("/m:a/l[k='x']"),
+			InstanceIDNew("/m:a/l[k='y']"), false},
+		{"list/different-key-prefix",
+			InstanceIDNew("/m:a/m:l[n:k='x']"),
+			InstanceIDNew("/m:a/l[k='x']"), false},
+		{"leaf-list",
+			InstanceIDNew("/m:a/m:ll[.='x']"),
+			InstanceIDNew("/m:a/ll[.='x']"), true},
+		{"leaf-list/inferred-first-node",
+			relative("/m:a/ll[.='x']"),
+			relative("/n:a/m:ll[.='x']"), true},
+		{"position",
+			InstanceIDNew("/m:a/m:l[0]"), InstanceIDNew("/m:a/l[1]"), false},
+		{"length",
+			InstanceIDNew("/m:a/b"), InstanceIDNew("/m:a/b/c"), false},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			if test.a.Equal(test.b) != test.expected {
+				t.Fatalf("expected %s == %s to be %v",
+					test.a, test.b, test.expected)
+			}
+			if test.b.Equal(test.a) != test.expected {
+				t.Fatal("Equal is not symmetric")
+			}
+		})
+	}
+	if InstanceIDNew("/m:a").Equal("/m:a") {
+		t.Fatal("instance-identifier should not equal a string")
+	}
+}
+
+func TestInstanceIDHasPrefix(t *testing.T) {
+	cases := []struct {
+		name     string
+		id       string
+		prefix   string
+		expected bool
+	}{
+		{"self", "/if:interfaces", "/if:interfaces", true},
+		{"container",
+			"/if:interfaces/interface[name='eth0']", "/if:interfaces",
+			true},
+		{"list entry",
+			"/if:interfaces/interface[name='eth0']/mtu",
+			"/if:interfaces/interface[name='eth0']", true},
+		{"inferred prefix",
+			"/if:interfaces/if:interface[if:name='eth0']/mtu",
+			"/if:interfaces/interface[name='eth0']", true},
+		{"different key",
+			"/if:interfaces/interface[name='eth0']/mtu",
+			"/if:interfaces/interface[name='eth1']", false},
+		{"missing predicate",
+			"/if:interfaces/interface[name='eth0']/mtu",
+			"/if:interfaces/interface", false},
+		{"different position",
+			"/m:a/l[0]/b", "/m:a/l[1]", false},
+		{"string prefix",
+			"/if:interfaces-state", "/if:interfaces", false},
+		{"longer",
+			"/if:interfaces", "/if:interfaces/interface", false},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := InstanceIDNew(test.id).
+				HasPrefix(InstanceIDNew(test.prefix))
+			if got != test.expected {
+				t.Fatalf("expected %s to have prefix %s to be %v",
+					test.id, test.prefix, test.expected)
+			}
+		})
+	}
+}
+
+func TestInstanceIDCompare(t *testing.T) {
+	expected := []string{
+		"/m:a",
+		"/m:a/b",
+		"/m:a/l",
+		"/m:a/l[2]",
+		"/m:a/l[10]",
+		"/m:a/l[10]/x",
+		"/m:a/l[k='a']",
+		"/m:a/l[k='a'][k2='z']",
+		"/m:a/l[k='b']",
+		"/m:a/l[k=*]",
+		"/m:a/l[n='a']",
+		"/m:a/n:b",
+		"/m:b",
+		"/n:a",
+	}
+	ids := make([]*InstanceID, len(expected))
+	for i := range expected {
+		// Shuffle deterministically by reversing.
+		ids[i] = InstanceIDNew(expected[len(expected)-1-i])
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Compare(ids[j]) < 0
+	})
+	got := make([]string, len(ids))
+	for i, id := range ids {
+		got[i] = id.String()
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+	}
+	for i, a := range ids {
+		for j, b := range ids {
+			c := a.Compare(b)
+			switch {
+			case i < j && c >= 0, i > j && c <= 0, i == j && c != 0:
+				t.Fatalf("inconsistent order for %s and %s: %d",
+					a, b, c)
+			}
+			if (c == 0) != a.Equal(b) {
+				t.Fatalf("Compare is inconsistent with Equal for %s and %s",
+					a, b)
+			}
+		}
+	}
+	if InstanceIDNew("/m:a/m:l[m:k='x']").
+		Compare(InstanceIDNew("/m:a/l[k='x']")) != 0 {
+		t.Fatal("expected inferred prefixes to compare equal")
+	}
+}
+
+func TestInstanceIDNegativePosition(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith("a", "b", "c")).
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("key", "x")),
+			ObjectWith(PairNew("key", "y"))))
+	cases := []struct {
+		path     string
+		expected *Value
+	}{
+		{"/module-v1:leaf-list[-1]", ValueNew("c")},
+		{"/module-v1:leaf-list[last()]", ValueNew("c")},
+		{"/module-v1:leaf-list[-3]", ValueNew("a")},
+		{"/module-v1:leaf-list[-4]", nil},
+		{"/module-v1:list[-1]/key", ValueNew("y")},
+		{"/module-v1:list[last()]/key", ValueNew("y")},
+	}
+	for _, test := range cases {
+		t.Run(test.path, func(t *testing.T) {
+			got := tree.At(test.path)
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+			if tree.Contains(test.path) != (test.expected != nil) {
+				t.Fatalf("Contains disagrees with At for %s", test.path)
+			}
+		})
+	}
+	t.Run("assoc", func(t *testing.T) {
+		got := tree.Assoc("/module-v1:leaf-list[-1]", "z").
+			At("/module-v1:leaf-list").String()
+		expected := `["a","b","z"]`
+		if got != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		got := tree.Delete("/module-v1:list[last()]").
+			At("/module-v1:list").String()
+		expected := `[{"key":"x"}]`
+		if got != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+}
+
+func TestInstanceIDNodes(t *testing.T) {
+	id := InstanceIDNew(
+		"/m:list[name='a'][m2:k=*]/inner[2]/m3:leaf-list[.='x']")
+	expected := []NodeInfo{
+		{
+			Prefix:     "m",
+			Identifier: "list",
+			Predicates: []PredicateInfo{
+				{KeyPrefix: "m", Key: "name", Value: "a"},
+				{KeyPrefix: "m2", Key: "k", Value: "*", Wildcard: true},
+			},
+		},
+		{
+			Prefix:     "m",
+			Identifier: "inner",
+			Predicates: []PredicateInfo{
+				{IsPosition: true, Position: 2},
+			},
+		},
+		{
+			Prefix:     "m3",
+			Identifier: "leaf-list",
+			Predicates: []PredicateInfo{
+				{KeyPrefix: "m3", Key: ".", Value: "x"},
+			},
+		},
+	}
+	got := id.Nodes()
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %+v\ngot: %+v\n", expected, got)
+	}
+	got[0].Identifier = "changed"
+	got[0].Predicates[0].Value = "changed"
+	if id.String() != "/m:list[name='a'][m2:k=*]/inner[2]/m3:leaf-list[.='x']" {
+		t.Fatalf("modifying Nodes changed the instance-identifier: %s",
+			id)
+	}
+}
+
+func TestInstanceIDLast(t *testing.T) {
+	cases := []struct {
+		name     string
+		id       *InstanceID
+		expected NodeInfo
+	}{
+		{"leaf", InstanceIDNew("/m:list[name='a/b']/m2:leaf"),
+			NodeInfo{Prefix: "m2", Identifier: "leaf"}},
+		{"inherited prefix", InstanceIDNew("/m:container/list[name='a/b']"),
+			NodeInfo{
+				Prefix:     "m",
+				Identifier: "list",
+				Predicates: []PredicateInfo{
+					{KeyPrefix: "m", Key: "name", Value: "a/b"},
+				},
+			}},
+		{"position", InstanceIDNew("/m:leaf-list[-1]"),
+			NodeInfo{
+				Prefix:     "m",
+				Identifier: "leaf-list",
+				Predicates: []PredicateInfo{
+					{IsPosition: true, Position: -1},
+				},
+			}},
+		{"empty", &InstanceID{}, NodeInfo{}},
+		{"nil", nil, NodeInfo{}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.id.Last()
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected: %+v\ngot: %+v\n", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestInstanceIDResolve(t *testing.T) {
+	base := InstanceIDNew("/m:a/b[name='x']/m2:c/d")
+	cases := []struct {
+		relative string
+		expected string
+	}{
+		{"e", "/m:a/b[name='x']/m2:c/d/e"},
+		{"e/f[k='v']", "/m:a/b[name='x']/m2:c/d/e/f[k='v']"},
+		{"m3:e/f", "/m:a/b[name='x']/m2:c/d/m3:e/f"},
+		{"../e", "/m:a/b[name='x']/m2:c/e"},
+		{"../../../b[name='y']/m2:c", "/m:a/b[name='y']/m2:c"},
+		{"..", "/m:a/b[name='x']/m2:c"},
+		{"../..", "/m:a/b[name='x']"},
+		{"../../../../m:z", "/m:z"},
+		{"e[0]", "/m:a/b[name='x']/m2:c/d/e[0]"},
+	}
+	for _, test := range cases {
+		t.Run(test.relative, func(t *testing.T) {
+			rel := RelativeInstanceIDNew(test.relative)
+			if rel.String() != test.relative {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.relative, rel.String())
+			}
+			got := rel.Resolve(base)
+			if got.String() != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, got.String())
+			}
+			if !got.Equal(InstanceIDNew(test.expected)) {
+				t.Fatalf("%s is not equal to the parsed %s",
+					got, test.expected)
+			}
+		})
+	}
+	t.Run("absolute", func(t *testing.T) {
+		abs := InstanceIDNew("/m:z")
+		if abs.Resolve(base) != abs {
+			t.Fatal("expected an absolute id to be returned unchanged")
+		}
+	})
+	failures := []struct {
+		relative string
+		expected string
+	}{
+		{"/m:a", "invalid instance identifier: relative instance identifier must not start with a \"/\""},
+		{"", "invalid instance identifier: must specify at least one node-identifier"},
+		{"e/../f", "invalid instance identifier: invalid node-identifier .."},
+		{"e[k='v'", "invalid instance identifier: unterminated predicate"},
+	}
+	for _, test := range failures {
+		t.Run("parse "+test.relative, func(t *testing.T) {
+			defer func() {
+				err, ok := recover().(error)
+				if !ok || err.Error() != test.expected {
+					t.Fatalf("expected: %s\ngot: %v\n",
+						test.expected, err)
+				}
+			}()
+			RelativeInstanceIDNew(test.relative)
+		})
+	}
+	resolveFailures := []struct {
+		relative string
+		expected string
+	}{
+		{"../../../../../e", "invalid instance identifier: ../../../../../e is above the root of /m:a/b[name='x']/m2:c/d"},
+		{"../../../../e", "invalid instance identifier: unable to determine prefix"},
+	}
+	for _, test := range resolveFailures {
+		t.Run("resolve "+test.relative, func(t *testing.T) {
+			defer func() {
+				err, ok := recover().(error)
+				if !ok || err.Error() != test.expected {
+					t.Fatalf("expected: %s\ngot: %v\n",
+						test.expected, err)
+				}
+			}()
+			RelativeInstanceIDNew(test.relative).Resolve(base)
+		})
+	}
+	t.Run("equal", func(t *testing.T) {
+		a, b := RelativeInstanceIDNew("../e"), RelativeInstanceIDNew("../../e")
+		if a.Equal(b) || a.Compare(b) >= 0 || b.Compare(a) <= 0 {
+			t.Fatalf("%s and %s should differ", a, b)
+		}
+		if !a.Equal(RelativeInstanceIDNew("../e")) ||
+			a.Compare(RelativeInstanceIDNew("../e")) != 0 {
+			t.Fatalf("%s should equal itself", a)
+		}
+		abs := InstanceIDNew("/m:e")
+		rel := RelativeInstanceIDNew("m:e")
+		if abs.Equal(rel) || rel.Equal(abs) || abs.Compare(rel) >= 0 {
+			t.Fatal("absolute and relative ids should differ")
+		}
+		if abs.HasPrefix(rel) || rel.HasPrefix(abs) ||
+			RelativeInstanceIDNew("../m:e/f").HasPrefix(rel) {
+			t.Fatal("relative ids should only prefix ones that " +
+				"step up as far")
+		}
+		if !RelativeInstanceIDNew("m:e/f").HasPrefix(rel) {
+			t.Fatal("expected a relative prefix")
+		}
+	})
+	t.Run("unresolved", func(t *testing.T) {
+		rel := RelativeInstanceIDNew("../e")
+		tree := TreeNew().Assoc("/m:a", 1)
+		for name, fn := range map[string]func(){
+			"AssocIn": func() { tree.AssocIn(rel, 1) },
+			"GetIn":   func() { tree.GetIn(rel) },
+			"Find":    func() { rel.Find(tree.Root()) },
+			"Transform": func() {
+				tree.Transform(func(tt *TTree) { tt.AssocIn(rel, 1) })
+			},
+		} {
+			_, err := try.Apply(fn)
+			if !errors.Is(err, ErrUnresolved) {
+				t.Fatalf("%s: expected: %v\ngot: %v\n",
+					name, ErrUnresolved, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/danos/encoding/rfc7951"
+	"jsouthworth.net/go/try"
+)
+
+// ToJSONPatch converts the EditOperation into an RFC 6902 JSON Patch
+// document that, when applied to the RFC7951 encoding of target,
+// produces the encoding of target.Edit(e).
+//
+// Instance-identifiers are translated into RFC 6901 JSON Pointers by
+// resolving them against the tree as each action is applied, so list
+// key predicates become array indices. EditDelete becomes "remove"
+// and is omitted if the node doesn't exist. EditAssoc and EditMerge
+// become "replace" with the resulting value when the node exists;
+// otherwise they become "add" of the outermost node that had to be
+// created. EditInsert becomes "add" at the inserted position,
+// EditMove becomes "move" and EditTest becomes "test" of the node's
+// value.
+//
+// An error is returned if an action can't be applied to target, if a
+// path contains wildcard predicates, which have no JSON Pointer
+// equivalent, or if an EditTest asserts that a node doesn't exist,
+// which JSON Patch can't express.
+func (e *EditOperation) ToJSONPatch(target *Tree) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	var n int
+	tree := target
+	for _, entry := range e.Actions {
+		if entry.Path.hasWildcard() {
+			return nil, fmt.Errorf(
+				"wildcard %v can't be represented as a JSON Pointer",
+				entry.Path)
+		}
+		next, err := try.Apply(entry.eval(), tree)
+		if err != nil {
+			return nil, err
+		}
+		op, err := entry.jsonPatchOp(tree, next.(*Tree))
+		if err != nil {
+			return nil, err
+		}
+		tree = next.(*Tree)
+		if op == nil {
+			continue
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		err = op.marshalRFC7951(&buf)
+		if err != nil {
+			return nil, err
+		}
+		n++
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// jsonPatchOp is a single RFC 6902 operation.
+type jsonPatchOp struct {
+	op      string
+	from    string
+	pointer string
+	module  string
+	value   *Value
+}
+
+func (o *jsonPatchOp) marshalRFC7951(w marshalWriter) error {
+	path, err := rfc7951.Marshal(o.pointer)
+	if err != nil {
+		return err
+	}
+	_, err = w.WriteString(`{"op":"` + o.op + `",`)
+	if err != nil {
+		return err
+	}
+	if o.op == "move" {
+		from, err := rfc7951.Marshal(o.from)
+		if err != nil {
+			return err
+		}
+		_, err = w.WriteString(`"from":` + string(from) + `,`)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.WriteString(`"path":` + string(path))
+	if err != nil {
+		return err
+	}
+	if o.value != nil {
+		_, err = w.WriteString(`,"value":`)
+		if err == nil {
+			err = o.value.marshalRFC7951(w, o.module)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.WriteByte('}')
+}
+
+// jsonPatchOp returns the JSON Patch operation that transforms before
+// into after, the result of applying the entry to before. A nil
+// operation is returned if the entry has no effect.
+func (e *EditEntry) jsonPatchOp(before, after *Tree) (*jsonPatchOp, error) {
+	if e.Action == EditDelete {
+		pointer, _, found := jsonPointer(before.Root(), e.Path)
+		if !found {
+			return nil, nil
+		}
+		return &jsonPatchOp{op: "remove", pointer: pointer}, nil
+	}
+	if e.Action == EditMove {
+		from, _, found := jsonPointer(before.Root(), e.From)
+		if !found {
+			return nil, fmt.Errorf("unable to resolve %v as a JSON Pointer",
+				e.From)
+		}
+		pointer, _, found := jsonPointer(after.Root(), e.Path)
+		if !found {
+			return nil, fmt.Errorf("unable to resolve %v as a JSON Pointer",
+				e.Path)
+		}
+		return &jsonPatchOp{op: "move", from: from, pointer: pointer}, nil
+	}
+	if e.Action == EditTest {
+		pointer, module, found := jsonPointer(before.Root(), e.Path)
+		if !found {
+			return nil, fmt.Errorf(
+				"test for absence of %v can't be represented in JSON Patch",
+				e.Path)
+		}
+		return &jsonPatchOp{
+			op:      "test",
+			pointer: pointer,
+			module:  module,
+			value:   before.at(e.Path),
+		}, nil
+	}
+
+	// Find the outermost node the entry creates, if the entry
+	// creates nothing it replaces the node at its path. Inserts
+	// always create the node at their path.
+	op, path := "replace", e.Path
+	ancestry := e.Path.ancestry()
+	if e.Action == EditInsert {
+		op, ancestry = "add", ancestry[:len(ancestry)-1]
+	}
+	for _, id := range ancestry {
+		if _, found := before.find(id); !found {
+			op, path = "add", id
+			break
+		}
+	}
+
+	pointer, module, found := jsonPointer(after.Root(), path)
+	if !found {
+		return nil, fmt.Errorf("unable to resolve %v as a JSON Pointer",
+			path)
+	}
+	return &jsonPatchOp{
+		op:      op,
+		pointer: pointer,
+		module:  module,
+		value:   after.at(path),
+	}, nil
+}
+
+// jsonPointer returns the RFC 6901 JSON Pointer addressing the node
+// at id within root's RFC7951 encoding, the module that node's
+// contents are encoded relative to, and whether the node exists.
+func jsonPointer(root *Value, id *InstanceID) (string, string, bool) {
+	var buf strings.Builder
+	var module string
+	value := root
+	for _, node := range id.ancestry() {
+		selector := node.selector()
+		next, found := selector.Find(value)
+		if !found {
+			return "", "", false
+		}
+		switch ident := selector.computeIdentifier(value).(type) {
+		case string:
+			mod, key := value.AsObject().parseKey(ident)
+			if mod != module {
+				key = ident
+			}
+			module = mod
+			buf.WriteByte('/')
+			buf.WriteString(jsonPointerEscaper.Replace(key))
+		case int:
+			buf.WriteByte('/')
+			buf.WriteString(strconv.Itoa(ident))
+		default:
+			return "", "", false
+		}
+		value = next
+	}
+	return buf.String(), module, true
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
@@ -0,0 +1,283 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// applyJSONPatch is a minimal RFC 6902 implementation supporting the
+// operations produced by ToJSONPatch. It fails the test if the patch
+// isn't applicable to doc.
+func applyJSONPatch(t *testing.T, doc interface{}, patch []byte) interface{} {
+	var ops []struct {
+		Op    string      `json:"op"`
+		From  string      `json:"from"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		t.Fatalf("invalid JSON Patch %s: %s", patch, err)
+	}
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	var apply func(node interface{}, tokens []string,
+		op string, value interface{}) interface{}
+	apply = func(node interface{}, tokens []string,
+		op string, value interface{}) interface{} {
+		token := unescape.Replace(tokens[0])
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, found := n[token]
+			switch {
+			case len(tokens) > 1 && found:
+				n[token] = apply(child, tokens[1:], op, value)
+			case len(tokens) > 1, op != "add" && !found:
+				t.Fatalf("%s: member %q doesn't exist", op, token)
+			case op == "test":
+				if !reflect.DeepEqual(child, value) {
+					t.Fatalf("test: %v != %v", child, value)
+				}
+			case op == "remove":
+				delete(n, token)
+			default:
+				n[token] = value
+			}
+			return n
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i > len(n) ||
+				(i == len(n) && (op != "add" || len(tokens) > 1)) {
+				t.Fatalf("%s: invalid index %q", op, token)
+			}
+			switch {
+			case len(tokens) > 1:
+				n[i] = apply(n[i], tokens[1:], op, value)
+				return n
+			case op == "add":
+				n = append(n[:i], append([]interface{}{value},
+					n[i:]...)...)
+			case op == "test":
+				if !reflect.DeepEqual(n[i], value) {
+					t.Fatalf("test: %v != %v", n[i], value)
+				}
+			case op == "remove":
+				n = append(n[:i], n[i+1:]...)
+			default:
+				n[i] = value
+			}
+			return n
+		default:
+			t.Fatalf("%s: can't address %q in %v", op, token, node)
+			return nil
+		}
+	}
+	get := func(node interface{}, tokens []string) interface{} {
+		for _, token := range tokens {
+			token = unescape.Replace(token)
+			switch n := node.(type) {
+			case map[string]interface{}:
+				node = n[token]
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(n) {
+					t.Fatalf("invalid index %q", token)
+				}
+				node = n[i]
+			}
+		}
+		return node
+	}
+	for _, op := range ops {
+		if !strings.HasPrefix(op.Path, "/") {
+			t.Fatalf("%s: invalid path %q", op.Op, op.Path)
+		}
+		if op.Op == "move" {
+			from := strings.Split(op.From[1:], "/")
+			op.Value = get(doc, from)
+			doc = apply(doc, from, "remove", nil)
+			op.Op = "add"
+		}
+		doc = apply(doc, strings.Split(op.Path[1:], "/"),
+			op.Op, op.Value)
+	}
+	return doc
+}
+
+func decodeJSON(t *testing.T, tree *Tree) interface{} {
+	var out interface{}
+	if err := json.Unmarshal([]byte(tree.String()), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestEditOperationToJSONPatch(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:container/leaf", "foo").
+		Assoc("/module-v1:container/module-v2:aug", "bar").
+		Assoc("/module-v1:list[key='a']/leaf", "x").
+		Assoc("/module-v1:list[key='b']/leaf", "y").
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 2, 3))
+	cases := []struct {
+		name     string
+		edit     *EditOperation
+		expected string
+	}{
+		{
+			name: "replace leaf",
+			edit: EditOperationNew(
+				EditEntryNew("assoc", "/module-v1:container/leaf",
+					EditEntryValue("baz"))),
+			expected: `[{"op":"replace","path":"/module-v1:container/leaf","value":"baz"}]`,
+		},
+		{
+			name: "replace augmented leaf",
+			edit: EditOperationNew(
+				EditEntryNew("assoc",
+					"/module-v1:container/module-v2:aug",
+					EditEntryValue("baz"))),
+			expected: `[{"op":"replace","path":"/module-v1:container/module-v2:aug","value":"baz"}]`,
+		},
+		{
+			name: "replace list entry leaf",
+			edit: EditOperationNew(
+				EditEntryNew("assoc", "/module-v1:list[key='b']/leaf",
+					EditEntryValue("z"))),
+			expected: `[{"op":"replace","path":"/module-v1:list/1/leaf","value":"z"}]`,
+		},
+		{
+			name: "add list entry",
+			edit: EditOperationNew(
+				EditEntryNew("assoc", "/module-v1:list[key='c']/leaf",
+					EditEntryValue("z"))),
+			expected: `[{"op":"add","path":"/module-v1:list/2","value":{"key":"c","leaf":"z"}}]`,
+		},
+		{
+			name: "add container",
+			edit: EditOperationNew(
+				EditEntryNew("assoc", "/module-v1:new/inner/leaf",
+					EditEntryValue("z"))),
+			expected: `[{"op":"add","path":"/module-v1:new","value":{"inner":{"leaf":"z"}}}]`,
+		},
+		{
+			name: "remove list entry",
+			edit: EditOperationNew(
+				EditEntryNew("delete", "/module-v1:list[key='a']")),
+			expected: `[{"op":"remove","path":"/module-v1:list/0"}]`,
+		},
+		{
+			name: "remove missing",
+			edit: EditOperationNew(
+				EditEntryNew("delete", "/module-v1:missing")),
+			expected: `[]`,
+		},
+		{
+			name: "insert",
+			edit: EditOperationNew(
+				EditEntryNew("insert", "/module-v1:leaf-list[1]",
+					EditEntryValue(9))),
+			expected: `[{"op":"add","path":"/module-v1:leaf-list/1","value":9}]`,
+		},
+		{
+			name: "test",
+			edit: EditOperationNew(
+				EditEntryNew("test", "/module-v1:list[key='b']/leaf",
+					EditEntryValue("y")),
+				EditEntryNew("assoc", "/module-v1:list[key='b']/leaf",
+					EditEntryValue("z"))),
+			expected: `[{"op":"test","path":"/module-v1:list/1/leaf","value":"y"},` +
+				`{"op":"replace","path":"/module-v1:list/1/leaf","value":"z"}]`,
+		},
+		{
+			name: "move",
+			edit: EditOperationNew(
+				EditEntryNew("move", "/module-v1:leaf-list[2]",
+					EditEntryFrom("/module-v1:leaf-list[0]"))),
+			expected: `[{"op":"move","from":"/module-v1:leaf-list/0","path":"/module-v1:leaf-list/2"}]`,
+		},
+		{
+			name: "diff with moves",
+			edit: tree.Diff(tree.
+				Assoc("/module-v1:leaf-list", ArrayWith(3, 1, 2, 4)).
+				Assoc("/module-v1:list", ArrayWith(
+					ObjectWith(PairNew("key", "b"), PairNew("leaf", "y")),
+					ObjectWith(PairNew("key", "a"), PairNew("leaf", "z"))))),
+		},
+		{
+			name: "merge",
+			edit: EditOperationNew(
+				EditEntryNew("merge", "/module-v1:container",
+					EditEntryValue(ObjectWith(
+						PairNew("other", "z"))))),
+		},
+		{
+			name: "sequence",
+			edit: EditOperationNew(
+				EditEntryNew("delete", "/module-v1:list[key='a']"),
+				EditEntryNew("assoc", "/module-v1:list[key='b']/leaf",
+					EditEntryValue("z")),
+				EditEntryNew("assoc", "/module-v1:list[key='c']/leaf",
+					EditEntryValue("z")),
+				EditEntryNew("insert", "/module-v1:leaf-list[3]",
+					EditEntryValue(4)),
+				EditEntryNew("delete", "/module-v1:leaf-list[0]"),
+				EditEntryNew("delete", "/module-v1:container"),
+				EditEntryNew("assoc", "/module-v1:container/leaf",
+					EditEntryValue(true))),
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			patch, err := test.edit.ToJSONPatch(tree)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expected != "" {
+				// Compare decoded patches, object members
+				// are not encoded in a stable order.
+				var expected, got interface{}
+				err := json.Unmarshal([]byte(test.expected), &expected)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := json.Unmarshal(patch, &got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(expected, got) {
+					t.Fatalf("expected: %s\ngot: %s\n",
+						test.expected, patch)
+				}
+			}
+			expected := decodeJSON(t, tree.Edit(test.edit))
+			got := applyJSONPatch(t, decodeJSON(t, tree), patch)
+			if !reflect.DeepEqual(expected, got) {
+				t.Fatalf("applying %s\nexpected: %v\ngot: %v\n",
+					patch, expected, got)
+			}
+		})
+	}
+	t.Run("wildcard", func(t *testing.T) {
+		_, err := EditOperationNew(
+			EditEntryNew("delete", "/module-v1:list[key=*]")).
+			ToJSONPatch(tree)
+		if err == nil {
+			t.Fatal("expected wildcard to be rejected")
+		}
+	})
+	t.Run("invalid edit", func(t *testing.T) {
+		_, err := EditOperationNew(
+			EditEntryNew("insert", "/module-v1:leaf-list[7]",
+				EditEntryValue(9))).
+			ToJSONPatch(tree)
+		if err == nil {
+			t.Fatal("expected out of range insert to fail")
+		}
+	})
+}
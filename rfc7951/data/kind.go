@@ -0,0 +1,250 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// ValueKind identifies the kind of data stored in a Value.
+type ValueKind int
+
+const (
+	// KindNull is the kind of a null value.
+	KindNull ValueKind = iota
+	// KindObject is the kind of an *Object.
+	KindObject
+	// KindArray is the kind of an *Array.
+	KindArray
+	// KindString is the kind of a string.
+	KindString
+	// KindInt32 is the kind of a negative 32 bit integer.
+	KindInt32
+	// KindUint32 is the kind of a non-negative 32 bit integer.
+	KindUint32
+	// KindInt64 is the kind of a negative 64 bit integer.
+	KindInt64
+	// KindUint64 is the kind of a non-negative 64 bit integer.
+	KindUint64
+	// KindFloat is the kind of a floating point number.
+	KindFloat
+	// KindBool is the kind of a boolean.
+	KindBool
+	// KindEmpty is the kind of the YANG empty value.
+	KindEmpty
+	// KindInstanceID is the kind of an *InstanceID.
+	KindInstanceID
+	// KindBigInt is the kind of an integer that does not fit in 64 bits.
+	KindBigInt
+	// KindDecimal is the kind of a Decimal.
+	KindDecimal
+	// KindOther is the kind of a value of a type registered with
+	// RegisterValueType.
+	KindOther
+)
+
+var valueKindNames = [...]string{
+	KindNull:       "null",
+	KindObject:     "object",
+	KindArray:      "array",
+	KindString:     "string",
+	KindInt32:      "int32",
+	KindUint32:     "uint32",
+	KindInt64:      "int64",
+	KindUint64:     "uint64",
+	KindFloat:      "float64",
+	KindBool:       "boolean",
+	KindEmpty:      "empty",
+	KindInstanceID: "instance-identifier",
+	KindBigInt:     "big integer",
+	KindDecimal:    "decimal",
+	KindOther:      "other",
+}
+
+// String returns a human readable name for the kind.
+func (k ValueKind) String() string {
+	if k < 0 || int(k) >= len(valueKindNames) {
+		return fmt.Sprintf("ValueKind(%d)", int(k))
+	}
+	return valueKindNames[k]
+}
+
+// Type returns the kind of data stored in the value. The kind reflects
+// how ValueNew stores the data, non-negative integers are stored as
+// unsigned so ValueNew(5) is of KindUint32 while ValueNew(-5) is of
+// KindInt32.
+func (val *Value) Type() ValueKind {
+	switch val.data.(type) {
+	case nil:
+		return KindNull
+	case *Object:
+		return KindObject
+	case *Array:
+		return KindArray
+	case string:
+		return KindString
+	case int32:
+		return KindInt32
+	case uint32:
+		return KindUint32
+	case int64:
+		return KindInt64
+	case uint64:
+		return KindUint64
+	case float64:
+		return KindFloat
+	case bool:
+		return KindBool
+	case empty:
+		return KindEmpty
+	case *InstanceID:
+		return KindInstanceID
+	case BigInt:
+		return KindBigInt
+	case Decimal:
+		return KindDecimal
+	default:
+		return KindOther
+	}
+}
+
+// KindError is returned by the As*E methods of Value when the value
+// doesn't hold data that can be used as the requested kind.
+type KindError struct {
+	Requested ValueKind
+	Actual    ValueKind
+}
+
+func (e *KindError) Error() string {
+	return "cannot use " + e.Actual.String() + " value as " +
+		e.Requested.String()
+}
+
+func (val *Value) kindError(requested ValueKind) error {
+	return &KindError{Requested: requested, Actual: val.Type()}
+}
+
+// convertibleTo returns whether the value's data can be converted to
+// the type with reflect.Value.Convert.
+func (val *Value) convertibleTo(typ reflect.Type) bool {
+	t := reflect.TypeOf(val.data)
+	return t != nil && t.ConvertibleTo(typ)
+}
+
+// valueKindName returns a human readable name for the kind of data
+// held in the value for use in error messages.
+func valueKindName(val *Value) string {
+	if val == nil {
+		return "nothing"
+	}
+	kind := val.Type()
+	if kind == KindOther {
+		return fmt.Sprintf("%T", val.data)
+	}
+	return kind.String()
+}
+
+// coerce converts a scalar value to the requested kind, returning false
+// if the value can't be represented as that kind. Numbers may be
+// converted between the integer kinds when they are in range for the
+// target, integers may become floats, and numbers and booleans may be
+// converted to and from their string form. The result is created with
+// ValueNew, so a non-negative integer coerced to KindInt32 or KindInt64
+// is stored as unsigned exactly as it would be by schema aware code.
+// Values that are already of the requested kind are returned unchanged,
+// as are values whose kind has no conversion defined.
+func (val *Value) coerce(kind ValueKind) (*Value, bool) {
+	if val.Type() == kind {
+		return val, true
+	}
+	switch kind {
+	case KindString:
+		switch val.Type() {
+		case KindInt32, KindUint32, KindInt64, KindUint64,
+			KindFloat, KindBool, KindBigInt, KindDecimal:
+			return ValueNew(val.RFC7951String()), true
+		}
+	case KindInt32, KindUint32, KindInt64, KindUint64, KindBigInt:
+		i, ok := coerceInteger(val)
+		if !ok || !integerFitsKind(i, kind) {
+			return val, false
+		}
+		switch kind {
+		case KindInt32:
+			return ValueNew(int32(i.Int64())), true
+		case KindUint32:
+			return ValueNew(uint32(i.Uint64())), true
+		case KindInt64:
+			return ValueNew(i.Int64()), true
+		case KindUint64:
+			return ValueNew(i.Uint64()), true
+		default:
+			return ValueNew(i), true
+		}
+	case KindFloat:
+		if n, ok := numericValue(val.data); ok {
+			f, _ := n.Float64()
+			return ValueNew(f), true
+		}
+		if s, ok := val.data.(string); ok {
+			f, err := strconv.ParseFloat(s, 64)
+			if err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				return ValueNew(f), true
+			}
+		}
+	case KindDecimal:
+		if d, err := val.AsDecimalE(); err == nil {
+			return ValueNew(d), true
+		}
+	case KindBool:
+		if s, ok := val.data.(string); ok {
+			switch s {
+			case "true":
+				return ValueNew(true), true
+			case "false":
+				return ValueNew(false), true
+			}
+		}
+	}
+	return val, false
+}
+
+func coerceInteger(val *Value) (*big.Int, bool) {
+	if i, ok := integerValue(val.data); ok {
+		return i, true
+	}
+	switch d := val.data.(type) {
+	case string:
+		return new(big.Int).SetString(d, 10)
+	case float64:
+		if math.IsInf(d, 0) || math.IsNaN(d) || d != math.Trunc(d) {
+			return nil, false
+		}
+		i, _ := big.NewFloat(d).Int(nil)
+		return i, true
+	}
+	return nil, false
+}
+
+func integerFitsKind(i *big.Int, kind ValueKind) bool {
+	switch kind {
+	case KindInt32:
+		return i.IsInt64() && i.Int64() >= math.MinInt32 &&
+			i.Int64() <= math.MaxInt32
+	case KindUint32:
+		return i.IsUint64() && i.Uint64() <= math.MaxUint32
+	case KindInt64:
+		return i.IsInt64()
+	case KindUint64:
+		return i.IsUint64()
+	default:
+		return true
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestValueType(t *testing.T) {
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+	cases := []struct {
+		name     string
+		value    *Value
+		expected ValueKind
+	}{
+		{"null", ValueNew(nil), KindNull},
+		{"object", ValueNew(ObjectNew()), KindObject},
+		{"array", ValueNew(ArrayWith(1, 2)), KindArray},
+		{"string", ValueNew("foo"), KindString},
+		{"negative-int", ValueNew(-5), KindInt32},
+		{"positive-int", ValueNew(5), KindUint32},
+		{"positive-int32", ValueNew(int32(5)), KindUint32},
+		{"negative-int64", ValueNew(int64(-5)), KindInt64},
+		{"positive-int64", ValueNew(int64(5)), KindUint64},
+		{"uint64", ValueNew(uint64(5)), KindUint64},
+		{"float32", ValueNew(float32(1.5)), KindFloat},
+		{"float64", ValueNew(1.5), KindFloat},
+		{"bool", ValueNew(true), KindBool},
+		{"empty", Empty(), KindEmpty},
+		{"empty-literal", ValueNew([]interface{}{nil}), KindEmpty},
+		{"instance-identifier", ValueNew(InstanceIDNew("/m:foo")),
+			KindInstanceID},
+		{"big-integer", ValueNew(huge), KindBigInt},
+		{"decimal", ValueNew(mustDecimal(t, "1.5")), KindDecimal},
+		{"registered", ValueNew(testIdentity("m:foo")), KindOther},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.value.Type()
+			if got != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestValueKindString(t *testing.T) {
+	if KindEmpty.String() != "empty" {
+		t.Fatalf("expected: empty\ngot: %s\n", KindEmpty)
+	}
+	if ValueKind(-1).String() != "ValueKind(-1)" {
+		t.Fatalf("expected: ValueKind(-1)\ngot: %s\n", ValueKind(-1))
+	}
+}
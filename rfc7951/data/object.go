@@ -8,6 +8,10 @@ package data
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/danos/encoding/rfc7951"
@@ -119,48 +123,52 @@ func (obj *Object) with(pairs ...Pair) *Object {
 //     func(*Value) iterates over only the values
 //     func(*Value bool
 func (obj *Object) Range(fn interface{}) *Object {
+	rangeFn := genObjectRangeFunc(fn)
+	obj.store.Range(func(e hashmap.Entry) bool {
+		return rangeFn(e.Key().(string), e.Value().(*Value))
+	})
+	return obj
+}
+
+func genObjectRangeFunc(fn interface{}) func(string, *Value) bool {
 	switch f := fn.(type) {
 	case func(Pair):
-		fn = func(e hashmap.Entry) bool {
-			f(PairNew(e.Key().(string), e.Value()))
+		return func(k string, v *Value) bool {
+			f(PairNew(k, v))
 			return true
 		}
 	case func(Pair) bool:
-		fn = func(e hashmap.Entry) bool {
-			return f(PairNew(e.Key().(string), e.Value()))
+		return func(k string, v *Value) bool {
+			return f(PairNew(k, v))
 		}
 	case func(string, *Value):
-		fn = func(e hashmap.Entry) bool {
-			f(e.Key().(string), e.Value().(*Value))
+		return func(k string, v *Value) bool {
+			f(k, v)
 			return true
 		}
 	case func(string, *Value) bool:
-		fn = func(e hashmap.Entry) bool {
-			return f(e.Key().(string), e.Value().(*Value))
-		}
+		return f
 	case func(*Value):
-		fn = func(e hashmap.Entry) bool {
-			f(e.Value().(*Value))
+		return func(_ string, v *Value) bool {
+			f(v)
 			return true
 		}
 	case func(*Value) bool:
-		fn = func(e hashmap.Entry) bool {
-			return f(e.Value().(*Value))
+		return func(_ string, v *Value) bool {
+			return f(v)
 		}
 	case func(string):
-		fn = func(e hashmap.Entry) bool {
-			f(e.Key().(string))
+		return func(k string, _ *Value) bool {
+			f(k)
 			return true
 		}
 	case func(string) bool:
-		fn = func(e hashmap.Entry) bool {
-			return f(e.Key().(string))
+		return func(k string, _ *Value) bool {
+			return f(k)
 		}
 	default:
 		panic("invalid range function")
 	}
-	obj.store.Range(fn)
-	return obj
 }
 
 // At returns the Value at the key's location or nil if it doesn't exist.
@@ -194,6 +202,154 @@ func (obj *Object) Find(key string) (*Value, bool) {
 	return out.(*Value), ok
 }
 
+// GetPath descends through nested objects following the keys in turn
+// and returns the value reached, or nil if a key doesn't exist or its
+// value isn't an object when there are keys left to follow. As with
+// At each key may be either 'module:key' or just key if the module is
+// the same as the containing object's module. Unlike an
+// instance-identifier, lists can't be descended into.
+func (obj *Object) GetPath(path ...string) *Value {
+	out := ValueNew(obj)
+	for _, key := range path {
+		if !out.IsObject() {
+			return nil
+		}
+		out = out.AsObject().At(key)
+		if out == nil {
+			return nil
+		}
+	}
+	return out
+}
+
+// GetObject returns the *Object at the key or an error if the key does
+// not exist or its value is not an Object.
+func (obj *Object) GetObject(key string) (*Object, error) {
+	v, err := obj.get(key, "an object", (*Value).IsObject)
+	if err != nil {
+		return nil, err
+	}
+	return v.AsObject(), nil
+}
+
+// GetArray returns the *Array at the key or an error if the key does
+// not exist or its value is not an Array.
+func (obj *Object) GetArray(key string) (*Array, error) {
+	v, err := obj.get(key, "an array", (*Value).IsArray)
+	if err != nil {
+		return nil, err
+	}
+	return v.AsArray(), nil
+}
+
+// GetString returns the string at the key or an error if the key does
+// not exist or its value is not a string.
+func (obj *Object) GetString(key string) (string, error) {
+	v, err := obj.get(key, "a string", (*Value).IsString)
+	if err != nil {
+		return "", err
+	}
+	return v.AsString(), nil
+}
+
+// GetInt32 returns the int32 at the key or an error if the key does
+// not exist or its value is not an integer that fits in an int32.
+func (obj *Object) GetInt32(key string) (int32, error) {
+	v, err := obj.get(key, "an int32", integerFits(math.MinInt32, math.MaxInt32))
+	if err != nil {
+		return 0, err
+	}
+	return v.AsInt32(), nil
+}
+
+// GetUint32 returns the uint32 at the key or an error if the key does
+// not exist or its value is not an integer that fits in a uint32.
+func (obj *Object) GetUint32(key string) (uint32, error) {
+	v, err := obj.get(key, "a uint32", integerFits(0, math.MaxUint32))
+	if err != nil {
+		return 0, err
+	}
+	return v.AsUint32(), nil
+}
+
+// GetInt64 returns the int64 at the key or an error if the key does
+// not exist or its value is not an integer that fits in an int64.
+func (obj *Object) GetInt64(key string) (int64, error) {
+	v, err := obj.get(key, "an int64", integerFits(math.MinInt64, math.MaxInt64))
+	if err != nil {
+		return 0, err
+	}
+	return v.AsInt64(), nil
+}
+
+// GetUint64 returns the uint64 at the key or an error if the key does
+// not exist or its value is not a non-negative integer.
+func (obj *Object) GetUint64(key string) (uint64, error) {
+	v, err := obj.get(key, "a uint64", func(v *Value) bool {
+		i, isInteger := integerValue(v.data)
+		return isInteger && i.Sign() >= 0
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.AsUint64(), nil
+}
+
+// GetFloat returns the value at the key as a float64 or an error if
+// the key does not exist or its value is not a number.
+func (obj *Object) GetFloat(key string) (float64, error) {
+	v, err := obj.get(key, "a number", func(v *Value) bool {
+		_, isNumber := numericValue(v.data)
+		return isNumber
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.AsFloat(), nil
+}
+
+// GetBoolean returns the bool at the key or an error if the key does
+// not exist or its value is not a bool. As with AsBoolean the Empty
+// value is treated as true.
+func (obj *Object) GetBoolean(key string) (bool, error) {
+	v, err := obj.get(key, "a boolean", (*Value).IsBoolean)
+	if err != nil {
+		return false, err
+	}
+	return v.AsBoolean(), nil
+}
+
+// GetInstanceID returns the *InstanceID at the key or an error if the
+// key does not exist or its value is not an instance-identifier.
+func (obj *Object) GetInstanceID(key string) (*InstanceID, error) {
+	v, err := obj.get(key, "an instance-identifier", (*Value).IsInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	return v.AsInstanceID(), nil
+}
+
+func (obj *Object) get(key, kind string, is func(*Value) bool) (*Value, error) {
+	v, found := obj.Find(key)
+	if !found {
+		return nil, fmt.Errorf("key %s not found", key)
+	}
+	if v == nil || !is(v) {
+		return nil, fmt.Errorf("key %s is not %s, got %s",
+			key, kind, valueKindName(v))
+	}
+	return v, nil
+}
+
+func integerFits(min int64, max uint64) func(*Value) bool {
+	lower := big.NewInt(min)
+	upper := new(big.Int).SetUint64(max)
+	return func(v *Value) bool {
+		i, isInteger := integerValue(v.data)
+		return isInteger && i.Cmp(lower) >= 0 && i.Cmp(upper) <= 0
+	}
+}
+
 // Assoc associates a new value with the key.
 // The key may be either 'module:key' or just key if the module is the same
 // as the containing object's module.
@@ -209,11 +365,103 @@ func (obj *Object) Assoc(key string, value interface{}) *Object {
 	}
 }
 
+// Update associates the key with the result of applying fn to the
+// value currently at the key, or to nil if there is none. If fn returns
+// nil the key is removed instead. As with Assoc the key may be either
+// 'module:key' or just key if the module is the same as the containing
+// object's module.
+func (obj *Object) Update(key string, fn func(*Value) *Value) *Object {
+	new := fn(obj.At(key))
+	if new == nil {
+		return obj.Delete(key)
+	}
+	return obj.Assoc(key, new)
+}
+
+// AssocIn associates the value with the location pointed to by the
+// instance-identifier, creating any intermediate nodes, as Tree.AssocIn
+// does with the object as the tree's root.
+func (obj *Object) AssocIn(id *InstanceID, value interface{}) *Object {
+	return TreeFromObject(obj).AssocIn(id, value).Root().AsObject()
+}
+
+// GetIn returns the Value at the location pointed to by the
+// instance-identifier or nil if it doesn't exist, as Tree.GetIn does
+// with the object as the tree's root.
+func (obj *Object) GetIn(id *InstanceID) *Value {
+	return TreeFromObject(obj).GetIn(id)
+}
+
 // Length returns the number of elements in the object.
 func (obj *Object) Length() int {
 	return obj.store.Length()
 }
 
+// Keys returns the full 'module:key' form of all the keys in the object
+// sorted lexically.
+func (obj *Object) Keys() []string {
+	out := make([]string, 0, obj.Length())
+	obj.Range(func(key string) {
+		out = append(out, key)
+	})
+	sort.Strings(out)
+	return out
+}
+
+// Values returns the values in the object ordered lexically by their
+// keys.
+func (obj *Object) Values() []*Value {
+	keys := obj.Keys()
+	out := make([]*Value, len(keys))
+	for i, key := range keys {
+		out[i] = obj.At(key)
+	}
+	return out
+}
+
+// Entries returns the members of the object as Pairs sorted lexically
+// by their full 'module:key' form. An empty object returns an empty,
+// non-nil slice.
+func (obj *Object) Entries() []Pair {
+	out := make([]Pair, 0, obj.Length())
+	obj.Range(func(pair Pair) {
+		out = append(out, pair)
+	})
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key() < out[j].Key()
+	})
+	return out
+}
+
+// Filter returns a new object containing only the members for which
+// fn returns true. The returned object belongs to the same module as
+// the original.
+func (obj *Object) Filter(fn func(key string, v *Value) bool) *Object {
+	return obj.Transform(func(out *TObject) {
+		obj.Range(func(key string, v *Value) {
+			if !fn(key, v) {
+				out.Delete(key)
+			}
+		})
+	})
+}
+
+// Map returns a new object with the same keys as the original, each
+// associated with the result of applying fn to its key and value. If fn
+// returns nil the key is associated with a null value rather than being
+// removed.
+func (obj *Object) Map(fn func(key string, v *Value) *Value) *Object {
+	return obj.Transform(func(out *TObject) {
+		obj.Range(func(key string, v *Value) {
+			new := fn(key, v)
+			if new == nil {
+				new = ValueNew(nil)
+			}
+			out.Assoc(key, new)
+		})
+	})
+}
+
 // Delete removes a key from the object.
 // The key may be either 'module:key' or just key if the module is the same
 // as the containing object's module.
@@ -229,6 +477,18 @@ func (obj *Object) Delete(key string) *Object {
 	}
 }
 
+// Without returns a new object with all of the supplied keys removed.
+// As with Delete each key may be either 'module:key' or just key if the
+// module is the same as the containing object's module. Keys that are
+// not present are ignored.
+func (obj *Object) Without(keys ...string) *Object {
+	return obj.Transform(func(out *TObject) {
+		for _, key := range keys {
+			out.Delete(key)
+		}
+	})
+}
+
 // toNative produces a go native map[string]interface{} from the object.
 func (obj *Object) toNative() interface{} {
 	out := make(map[string]interface{})
@@ -268,9 +528,11 @@ func (obj *Object) belongsTo(orig *Value, moduleName string) *Value {
 				module, _ := obj.parseKey(key)
 				switch module {
 				case "", oldModule:
+					// Delete before associating, the adapted key
+					// is usually the same as the original.
 					k, v := new.adaptValue(key, val)
-					newStore.Assoc(k, v)
 					newStore.Delete(obj.adaptKey(key))
+					newStore.Assoc(k, v)
 				default:
 					return
 				}
@@ -280,6 +542,70 @@ func (obj *Object) belongsTo(orig *Value, moduleName string) *Value {
 	return ValueNew(new)
 }
 
+// RenameModule returns a new object in which every key belonging to
+// the module from instead belongs to the module to, for example when
+// migrating data between module revisions. Nested objects and the
+// elements of arrays are renamed recursively. Keys belonging to other
+// modules are left untouched.
+func (obj *Object) RenameModule(from, to string) *Object {
+	return obj.renameModule(from, to).AsObject()
+}
+
+func (obj *Object) renameModule(from, to string) *Value {
+	out := &Object{
+		module: renameModule(obj.module, from, to),
+		store: hashmap.Empty().Transform(
+			func(store *hashmap.TMap) *hashmap.TMap {
+				obj.Range(func(key string, val *Value) {
+					module, k := obj.parseKey(key)
+					if module == from {
+						key = to + ":" + k
+					}
+					store = store.Assoc(key,
+						val.renameModule(from, to))
+				})
+				return store
+			}),
+	}
+	return ValueNew(out)
+}
+
+func renameModule(module, from, to string) string {
+	if module == from {
+		return to
+	}
+	return module
+}
+
+func (obj *Object) detach() *Value {
+	out := &Object{
+		module: cloneString(obj.module),
+		store: hashmap.Empty().Transform(
+			func(store *hashmap.TMap) *hashmap.TMap {
+				obj.Range(func(key string, val *Value) {
+					store = store.Assoc(cloneString(key),
+						val.Detach())
+				})
+				return store
+			}),
+	}
+	return ValueNew(out)
+}
+
+func (obj *Object) clone() *Value {
+	out := &Object{
+		module: obj.module,
+		store: hashmap.Empty().Transform(
+			func(store *hashmap.TMap) *hashmap.TMap {
+				obj.Range(func(key string, val *Value) {
+					store = store.Assoc(key, val.Clone())
+				})
+				return store
+			}),
+	}
+	return ValueNew(out)
+}
+
 func (obj *Object) adaptKey(key string) string {
 	module, key := obj.parseKey(key)
 	if module == "" {
@@ -309,14 +635,14 @@ func (obj *Object) copy() *Object {
 // merge merges one object with another. The returned object is the
 // old object with any existing keys replaced with counterparts from the
 // new object and any new keys added. Merge is accretive only and will
-// not remove non-existant keys.
-func (obj *Object) merge(new *Value) *Value {
+// not remove non-existant keys. Arrays are merged according to policy.
+func (obj *Object) merge(new *Value, policy ArrayMergePolicy) *Value {
 	return new.Perform(func(n *Object) *Value {
 		out := obj.Transform(func(out *TObject) {
 			obj.Range(func(key string, val *Value) {
 				if n.Contains(key) {
 					out = out.Assoc(key,
-						val.Merge(n.At(key)))
+						val.MergeArrays(n.At(key), policy))
 				}
 			})
 			n.Range(func(key string, val *Value) {
@@ -333,6 +659,39 @@ func (obj *Object) merge(new *Value) *Value {
 	}).(*Value)
 }
 
+// MergeWith merges new into the object like Merge but calls resolve
+// to determine the result whenever both objects contain the same key
+// with leaf values, that is values that are neither objects nor
+// arrays. Nested objects are merged recursively with the same resolve
+// function, arrays are merged as they are by Merge. If resolve is nil
+// MergeWith behaves exactly like Merge.
+func (obj *Object) MergeWith(
+	new *Object,
+	resolve func(key string, old, new *Value) *Value,
+) *Object {
+	if resolve == nil {
+		return obj.merge(ValueNew(new), ArrayByIndex).AsObject()
+	}
+	return obj.Transform(func(out *TObject) {
+		new.Range(func(key string, nv *Value) {
+			if !obj.Contains(key) {
+				out = out.Assoc(key, nv)
+				return
+			}
+			ov := obj.At(key)
+			switch {
+			case ov.IsObject() && nv.IsObject():
+				out = out.Assoc(key, ov.AsObject().
+					MergeWith(nv.AsObject(), resolve))
+			case ov.IsContainer() || nv.IsContainer():
+				out = out.Assoc(key, ov.Merge(nv))
+			default:
+				out = out.Assoc(key, resolve(key, ov, nv))
+			}
+		})
+	})
+}
+
 // Equal implements equality for objects. An object is equal to another
 // object if all their keys contains equal values. Equality checks are linear
 // with respect to the number of keys.
@@ -351,33 +710,118 @@ func (obj *Object) String() string {
 	return buf.String()
 }
 
-func (obj *Object) marshalRFC7951(buf *bytes.Buffer, module string) error {
-	buf.WriteByte('{')
+// StringIndent returns a multi-line representation of the Object like
+// String but with each element beginning on a new line starting with
+// prefix followed by one or more copies of indent according to the
+// nesting, as rfc7951.Indent does. Empty objects and arrays remain on
+// one line.
+func (obj *Object) StringIndent(prefix, indent string) string {
+	return indentString(obj.String(), prefix, indent)
+}
+
+func indentString(s, prefix, indent string) string {
+	var buf bytes.Buffer
+	err := rfc7951.Indent(&buf, []byte(s), prefix, indent)
+	if err != nil {
+		// Our own encoding is always valid.
+		panic(err)
+	}
+	return buf.String()
+}
+
+// WriteRFC7951 writes the Object encoded as RFC7951 data to w. The
+// encoding is streamed to w as it is produced.
+func (obj *Object) WriteRFC7951(w io.Writer) error {
+	return writeRFC7951(w, obj)
+}
+
+// AppendRFC7951 appends the Object encoded as RFC7951 data to dst and
+// returns the extended slice, see Value.AppendRFC7951.
+func (obj *Object) AppendRFC7951(dst []byte) ([]byte, error) {
+	return appendRFC7951(dst, obj)
+}
+
+// MarshalCanonical returns the Object encoded as RFC7951 data with
+// the members of every object, including those nested within other
+// objects and arrays, emitted in lexical order of their module
+// qualified keys. The output is deterministic unlike MarshalRFC7951.
+func (obj *Object) MarshalCanonical() ([]byte, error) {
+	return marshalCanonical(obj)
+}
+
+// everyPair reports whether fn returns true for every member of the
+// object, stopping at the first that it does not.
+func (obj *Object) everyPair(fn func(string, *Value) bool) bool {
+	out := true
+	obj.Range(func(key string, v *Value) bool {
+		out = fn(key, v)
+		return out
+	})
+	return out
+}
+
+// rangeSorted calls fn for each member of the object in the order
+// returned by Keys.
+func (obj *Object) rangeSorted(fn func(Pair) bool) {
+	for _, key := range obj.Keys() {
+		if !fn(PairNew(key, obj.At(key))) {
+			return
+		}
+	}
+}
+
+func (obj *Object) marshalRFC7951(w marshalWriter, module string) error {
+	if cw, caching := w.(cachingWriter); caching {
+		return cw.marshal(obj, module, obj.encodeRFC7951)
+	}
+	return obj.encodeRFC7951(w, module)
+}
+
+func (obj *Object) encodeRFC7951(w marshalWriter, module string) error {
+	rangePairs := func(fn func(Pair) bool) { obj.Range(fn) }
+	if _, canonical := w.(canonicalWriter); canonical {
+		rangePairs = obj.rangeSorted
+	}
+	return obj.encodePairs(w, module, rangePairs)
+}
+
+// encodePairs writes the object with its members in the order they
+// are visited by rangePairs.
+func (obj *Object) encodePairs(
+	w marshalWriter, module string, rangePairs func(func(Pair) bool),
+) error {
+	err := w.WriteByte('{')
+	if err != nil {
+		return err
+	}
 	var n int
-	obj.Range(func(pair Pair) {
+	rangePairs(func(pair Pair) bool {
 		k := pair.Key()
 		mod, key := obj.parseKey(k)
 		if mod == module {
 			k = key
 		}
-		buf.WriteByte('"')
-		buf.WriteString(k)
-		buf.WriteByte('"')
-		buf.WriteByte(':')
-		pair.Value().marshalRFC7951(buf, mod)
-		if n < obj.Length()-1 {
-			buf.WriteByte(',')
+		err = writeObjectKey(w, k)
+		if err == nil {
+			err = pair.Value().marshalRFC7951(w, mod)
+		}
+		if err == nil && n < obj.Length()-1 {
+			err = w.WriteByte(',')
 		}
 		n = n + 1
+		return err == nil
 	})
-	buf.WriteByte('}')
-	return nil
+	if err != nil {
+		return err
+	}
+	return w.WriteByte('}')
 }
 
 func (obj *Object) unmarshalRFC7951(
 	msg []byte, module string,
 	strs *stringInterner,
 	vals *valueInterner,
+	opts *decodeOpts,
 ) error {
 	// This can't be fully immutable, the caller has to ensure
 	// the object isn't used until unmarshal is finished, this
@@ -391,7 +835,7 @@ func (obj *Object) unmarshalRFC7951(
 				val := valueNew(nil)
 				module, _ := obj.parseKey(k)
 				module = strs.Intern(module)
-				val.unmarshalRFC7951(v, module, strs, vals)
+				val.unmarshalRFC7951(v, module, strs, vals, opts)
 				k, v := obj.adaptValue(k, val)
 				k = strs.Intern(k)
 				v = vals.Intern(v)
@@ -402,6 +846,82 @@ func (obj *Object) unmarshalRFC7951(
 	return nil
 }
 
+// diffMerge returns the edits needed to change the members of obj into
+// those of new. Each changed member is edited independently, see
+// mergePatch for the edits made within a member.
+func (obj *Object) diffMerge(new *Object, path *InstanceID) []EditEntry {
+	out := []EditEntry{}
+	obj.Range(func(k string, v *Value) {
+		if !new.Contains(k) {
+			out = append(out,
+				EditEntry{Action: EditDelete, Path: path.push(k)})
+		}
+	})
+	new.Range(func(k string, v *Value) {
+		old, found := obj.Find(k)
+		switch {
+		case !found:
+			out = append(out,
+				EditEntry{Action: EditAssoc, Path: path.push(k), Value: v})
+		case !equal(old, v):
+			out = append(out, diffMergeValues(old, v, path.push(k))...)
+		}
+	})
+	return out
+}
+
+// mergePatch returns the smallest object that when merged into obj
+// produces new, or nil if no merge is required, along with any edits
+// that a merge can't perform. Merges are accretive so deleted members
+// are returned as EditDelete entries. Objects can't be replaced by
+// other types, nor arrays be shrunk, by a merge, so these changes are
+// returned as separate edits too.
+func (obj *Object) mergePatch(new *Object, path *InstanceID) (*Object, []EditEntry) {
+	out := []EditEntry{}
+	patch := ObjectNew().Transform(func(patch *TObject) {
+		obj.Range(func(k string, v *Value) {
+			if !new.Contains(k) {
+				out = append(out,
+					EditEntry{Action: EditDelete, Path: path.push(k)})
+			}
+		})
+		new.Range(func(k string, v *Value) {
+			old, found := obj.Find(k)
+			switch {
+			case !found:
+				patch.Assoc(k, v)
+			case equal(old, v):
+			case old.IsObject() && v.IsObject():
+				sub, edits := old.AsObject().
+					mergePatch(v.AsObject(), path.push(k))
+				if sub != nil {
+					patch.Assoc(k, sub)
+				}
+				out = append(out, edits...)
+			case old.IsContainer():
+				out = append(out,
+					diffMergeValues(old, v, path.push(k))...)
+			default:
+				patch.Assoc(k, v)
+			}
+		})
+	})
+	if patch.Length() == 0 {
+		return nil, out
+	}
+	return patch, out
+}
+
+// Diff compares the object with other and returns the operations
+// required to edit the object to produce other. The paths of the
+// operations are rooted at the object, so applying them with Edit to
+// TreeFromObject(obj) produces a tree whose root is equal to other.
+func (obj *Object) Diff(other *Object) *EditOperation {
+	return &EditOperation{
+		Actions: obj.diff(ValueNew(other), &InstanceID{}),
+	}
+}
+
 func (obj *Object) diff(new *Value, path *InstanceID) []EditEntry {
 	out := []EditEntry{}
 	new.Perform(func(other *Object) {
@@ -440,14 +960,16 @@ func (obj *Object) diff(new *Value, path *InstanceID) []EditEntry {
 // transient object to provide a faster, less memory intensive, object
 // editing mechanism.
 func (obj *Object) Transform(fn func(*TObject)) *Object {
-	tobj := &TObject{
+	tobj := obj.asTransient()
+	fn(tobj)
+	return tobj.asPersistent()
+}
+
+func (obj *Object) asTransient() *TObject {
+	return &TObject{
 		orig:  obj,
 		store: obj.store.AsTransient(),
 	}
-	fn(tobj)
-	out := obj.copy()
-	out.store = tobj.store.AsPersistent()
-	return out
 }
 
 // TObject is a transient object that may be used to perform
@@ -459,6 +981,12 @@ type TObject struct {
 	store *hashmap.TMap
 }
 
+func (obj *TObject) asPersistent() *Object {
+	out := obj.orig.copy()
+	out.store = obj.store.AsPersistent()
+	return out
+}
+
 // Assoc associates a new value with the key. The key may be either
 // 'module:key' or just key if the module is the same as the containing
 // object's module.
@@ -587,25 +1115,30 @@ func (obj *TObject) String() string {
 	return buf.String()
 }
 
-func (obj *TObject) marshalRFC7951(buf *bytes.Buffer, module string) error {
-	buf.WriteByte('{')
+func (obj *TObject) marshalRFC7951(w marshalWriter, module string) error {
+	err := w.WriteByte('{')
+	if err != nil {
+		return err
+	}
 	var n int
-	obj.Range(func(pair Pair) {
+	obj.Range(func(pair Pair) bool {
 		k := pair.Key()
 		mod, key := obj.orig.parseKey(k)
 		if mod == module {
 			k = key
 		}
-		buf.WriteByte('"')
-		buf.WriteString(k)
-		buf.WriteByte('"')
-		buf.WriteByte(':')
-		pair.Value().marshalRFC7951(buf, mod)
-		if n < obj.Length()-1 {
-			buf.WriteByte(',')
+		err = writeObjectKey(w, k)
+		if err == nil {
+			err = pair.Value().marshalRFC7951(w, mod)
+		}
+		if err == nil && n < obj.Length()-1 {
+			err = w.WriteByte(',')
 		}
 		n = n + 1
+		return err == nil
 	})
-	buf.WriteByte('}')
-	return nil
+	if err != nil {
+		return err
+	}
+	return w.WriteByte('}')
 }
@@ -8,7 +8,9 @@ package data
 import (
 	"bytes"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/danos/encoding/rfc7951"
@@ -196,6 +198,57 @@ func TestObjectValuesDo(t *testing.T) {
 	})
 }
 
+func TestObjectKeysValues(t *testing.T) {
+	obj := ObjectWith(
+		PairNew("module-v2:b", 1),
+		PairNew("module-v1:c", 2),
+		PairNew("module-v1:a", 3),
+	)
+	expectedKeys := []string{"module-v1:a", "module-v1:c", "module-v2:b"}
+	if keys := obj.Keys(); !reflect.DeepEqual(keys, expectedKeys) {
+		t.Fatalf("expected: %v\ngot: %v\n", expectedKeys, keys)
+	}
+	expectedValues := []*Value{ValueNew(3), ValueNew(2), ValueNew(1)}
+	values := obj.Values()
+	if len(values) != len(expectedValues) {
+		t.Fatalf("expected: %v\ngot: %v\n", expectedValues, values)
+	}
+	for i, v := range values {
+		if !equal(v, expectedValues[i]) {
+			t.Fatalf("expected: %v\ngot: %v\n", expectedValues, values)
+		}
+	}
+	if len(ObjectNew().Keys()) != 0 || len(ObjectNew().Values()) != 0 {
+		t.Fatal("empty object should have no keys or values")
+	}
+}
+
+func TestObjectEntries(t *testing.T) {
+	obj := ObjectWith(
+		PairNew("module-v2:b", 1),
+		PairNew("module-v1:c", 2),
+		PairNew("module-v1:a", 3),
+	)
+	expected := []Pair{
+		PairNew("module-v1:a", 3),
+		PairNew("module-v1:c", 2),
+		PairNew("module-v2:b", 1),
+	}
+	entries := obj.Entries()
+	if len(entries) != len(expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, entries)
+	}
+	for i, pair := range entries {
+		if !equal(pair, expected[i]) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, entries)
+		}
+	}
+	empty := ObjectNew().Entries()
+	if empty == nil || len(empty) != 0 {
+		t.Fatalf("expected: []\ngot: %#v\n", empty)
+	}
+}
+
 func TestObjectPairsDo(t *testing.T) {
 	coll := ObjectFrom(map[string]interface{}{
 		"1": 2,
@@ -344,6 +397,28 @@ func TestCanAccessWithImplicitOrExplicitModuleName(t *testing.T) {
 	}
 }
 
+func TestObjectMovedToOtherModule(t *testing.T) {
+	// Members belonging to the object's module must survive being
+	// adapted when the object is placed in another module.
+	inner := TreeNew().
+		Assoc("/module-v2:cont/leaf", "foo").
+		Assoc("/module-v2:cont/other", "bar").
+		At("/module-v2:cont").AsObject()
+	got := ObjectWith(PairNew("module-v1:cont", inner)).
+		At("module-v1:cont").AsObject()
+	if got.Length() != 2 {
+		t.Fatalf("expected 2 members, got: %s", got)
+	}
+	for key, expected := range map[string]string{
+		"module-v2:leaf":  "foo",
+		"module-v2:other": "bar",
+	} {
+		if v := got.At(key); v == nil || v.ToString() != expected {
+			t.Fatalf("expected %s to be %s, got: %s", key, expected, got)
+		}
+	}
+}
+
 func TestObjectMarshalRFC7951(t *testing.T) {
 	obj := ObjectFrom(map[string]interface{}{
 		"module-v1:foo": map[string]interface{}{
@@ -388,7 +463,7 @@ func TestObjectMarshalRFC7951(t *testing.T) {
 	v.marshalRFC7951(&buf, "")
 	o := objectNew()
 	o.unmarshalRFC7951(buf.Bytes(), "",
-		stringInternerNew(), valueInternerNew())
+		stringInternerNew(), valueInternerNew(), &decodeOpts{})
 	got := ValueNew(o)
 	expected := `{"module-v1:bar":"baz","module-v2:baz":[{"quux":"foo","baz":"bar"},{"quux":"bar","baz":"foo"}],"module-v1:foo":{"negative-uint64":"-1234","nil":null,"false":false,"plus-in-string":"+foobar","true":true,"empty":[null],"two.one":"2.1","negative-in-dotted-string":"-2.fooboar","negative":-2,"bar":{"quux":"quuz","baz":["quux","foo"]},"negative-in-string":"-foobar","plus-in-dotted-string":"+2.foobar","negative-float":"-2.4","baz":"quux","positive-float":"+2.3","one":1,"empty-string":"","dotted-string":"192.168.1.1/24","positive":"2","uint64":"1234"}}`
 	tree := TreeNew()
@@ -426,7 +501,7 @@ func TestEscapedStringMarshalRFC7951(t *testing.T) {
 	v.marshalRFC7951(&buf, "")
 	o := objectNew()
 	o.unmarshalRFC7951(buf.Bytes(), "",
-		stringInternerNew(), valueInternerNew())
+		stringInternerNew(), valueInternerNew(), &decodeOpts{})
 	got := ValueNew(o)
 	expected := `{"module-v2:baz":[{"quux":"\"foo\"","baz":"bar"},{"quux":"\"bar\"","baz":"foo"}],"module-v1:foo":{"empty-string":"","one-quote":"\"","quotes-in-string":"\"foo\" \"bar\"","backslash-in-string":"\\foo\\bar","newline-in-string":"foo\nbar","tab-in-string":"\tfoo\tbar"}}`
 	tree := TreeNew()
@@ -477,6 +552,318 @@ func TestObjectFind(t *testing.T) {
 	})
 }
 
+func TestObjectTypedGetters(t *testing.T) {
+	obj := ObjectFrom(map[string]interface{}{
+		"module-v1:object":   map[string]interface{}{},
+		"module-v1:array":    []interface{}{1},
+		"module-v1:string":   "foo",
+		"module-v1:small":    10,
+		"module-v1:negative": -10,
+		"module-v1:large":    uint64(1 << 40),
+		"module-v1:float":    1.5,
+		"module-v1:bool":     true,
+		"module-v1:empty":    Empty(),
+		"module-v1:iid":      "/module-v1:foo",
+	})
+	t.Run("success", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			get      func() (interface{}, error)
+			expected interface{}
+		}{
+			{"GetString", func() (interface{}, error) {
+				return obj.GetString("module-v1:string")
+			}, "foo"},
+			{"GetInt32", func() (interface{}, error) {
+				return obj.GetInt32("module-v1:negative")
+			}, int32(-10)},
+			{"GetUint32", func() (interface{}, error) {
+				return obj.GetUint32("module-v1:small")
+			}, uint32(10)},
+			{"GetInt64", func() (interface{}, error) {
+				return obj.GetInt64("module-v1:small")
+			}, int64(10)},
+			{"GetUint64", func() (interface{}, error) {
+				return obj.GetUint64("module-v1:large")
+			}, uint64(1 << 40)},
+			{"GetFloat", func() (interface{}, error) {
+				return obj.GetFloat("module-v1:float")
+			}, 1.5},
+			{"GetBoolean", func() (interface{}, error) {
+				return obj.GetBoolean("module-v1:bool")
+			}, true},
+			{"GetBoolean/empty", func() (interface{}, error) {
+				return obj.GetBoolean("module-v1:empty")
+			}, true},
+			{"GetObject", func() (interface{}, error) {
+				o, err := obj.GetObject("module-v1:object")
+				return o.Length(), err
+			}, 0},
+			{"GetArray", func() (interface{}, error) {
+				a, err := obj.GetArray("module-v1:array")
+				return a.Length(), err
+			}, 1},
+			{"GetInstanceID", func() (interface{}, error) {
+				i, err := obj.GetInstanceID("module-v1:iid")
+				return i.String(), err
+			}, "/module-v1:foo"},
+		}
+		for _, test := range cases {
+			t.Run(test.name, func(t *testing.T) {
+				got, err := test.get()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != test.expected {
+					t.Fatalf("expected %T(%v), got %T(%v)",
+						test.expected, test.expected, got, got)
+				}
+			})
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			get      func() error
+			expected string
+		}{
+			{"not found", func() error {
+				_, err := obj.GetString("module-v1:missing")
+				return err
+			}, "key module-v1:missing not found"},
+			{"wrong type", func() error {
+				_, err := obj.GetString("module-v1:small")
+				return err
+			}, "key module-v1:small is not a string, got uint32"},
+			{"out of range", func() error {
+				_, err := obj.GetInt32("module-v1:large")
+				return err
+			}, "key module-v1:large is not an int32, got uint64"},
+			{"negative unsigned", func() error {
+				_, err := obj.GetUint64("module-v1:negative")
+				return err
+			}, "key module-v1:negative is not a uint64, got int32"},
+			{"not an object", func() error {
+				_, err := obj.GetObject("module-v1:array")
+				return err
+			}, "key module-v1:array is not an object, got array"},
+		}
+		for _, test := range cases {
+			t.Run(test.name, func(t *testing.T) {
+				err := test.get()
+				if err == nil || err.Error() != test.expected {
+					t.Fatalf("expected error %q, got %v",
+						test.expected, err)
+				}
+			})
+		}
+	})
+}
+
+func TestObjectWithout(t *testing.T) {
+	obj := TESTOBJ.At("module-v1:container").AsObject().
+		Assoc("module-v2:other", 1)
+	got := obj.Without("containerleaf", "module-v2:other", "missing")
+	expected := obj.Delete("containerleaf").Delete("module-v2:other")
+	if !equal(got, expected) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	if got.Contains("containerleaf") || got.Contains("module-v2:other") {
+		t.Fatalf("keys were not removed: %s", got)
+	}
+	if !equal(obj.Without(), obj) {
+		t.Fatal("Without with no keys should not change the object")
+	}
+}
+
+func TestObjectUpdate(t *testing.T) {
+	obj := TESTOBJ.At("module-v1:container").AsObject().
+		Assoc("module-v2:count", 1)
+	incr := func(v *Value) *Value {
+		if v == nil {
+			return ValueNew(0)
+		}
+		return ValueNew(v.AsUint32() + 1)
+	}
+	t.Run("existing", func(t *testing.T) {
+		got := obj.Update("module-v2:count", incr)
+		if !equal(got.At("module-v2:count"), ValueNew(2)) {
+			t.Fatalf("expected: 2\ngot: %s\n", got.At("module-v2:count"))
+		}
+		if !equal(obj.At("module-v2:count"), ValueNew(1)) {
+			t.Fatal("original object was modified")
+		}
+	})
+	t.Run("absent", func(t *testing.T) {
+		got := obj.Update("counter", incr)
+		if !equal(got.At("module-v1:counter"), ValueNew(0)) {
+			t.Fatalf("expected: 0\ngot: %s\n",
+				got.At("module-v1:counter"))
+		}
+	})
+	t.Run("implicit module", func(t *testing.T) {
+		got := obj.Update("containerleaf", func(v *Value) *Value {
+			return ValueNew(v.AsString() + "!")
+		})
+		expected := obj.Assoc("module-v1:containerleaf",
+			obj.At("containerleaf").AsString()+"!")
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		got := obj.Update("module-v2:count", func(*Value) *Value {
+			return nil
+		})
+		if !equal(got, obj.Delete("module-v2:count")) {
+			t.Fatalf("expected: %s\ngot: %s\n",
+				obj.Delete("module-v2:count"), got)
+		}
+	})
+}
+
+func TestObjectFilter(t *testing.T) {
+	obj := ObjectWith(
+		PairNew("module-v1:a", 1),
+		PairNew("module-v1:b", 2),
+		PairNew("module-v2:c", 3))
+	got := obj.Filter(func(key string, v *Value) bool {
+		return v.AsUint32()%2 == 1
+	})
+	expected := ObjectWith(
+		PairNew("module-v1:a", 1),
+		PairNew("module-v2:c", 3))
+	if !equal(got, expected) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	t.Run("empty", func(t *testing.T) {
+		nested := TESTOBJ.At("module-v1:container").AsObject()
+		got := nested.Filter(func(string, *Value) bool {
+			return false
+		})
+		if got == nil || got.Length() != 0 {
+			t.Fatalf("expected empty object, got %v", got)
+		}
+		if got.module != nested.module {
+			t.Fatalf("expected module %q, got %q",
+				nested.module, got.module)
+		}
+	})
+}
+
+func TestObjectMap(t *testing.T) {
+	obj := ObjectWith(
+		PairNew("module-v1:a", 1),
+		PairNew("module-v1:b", 2),
+		PairNew("module-v2:c", 3))
+	got := obj.Map(func(key string, v *Value) *Value {
+		if key == "module-v1:b" {
+			return nil
+		}
+		return ValueNew(key + "=" + v.RFC7951String())
+	})
+	expected := ObjectWith(
+		PairNew("module-v1:a", "module-v1:a=1"),
+		PairNew("module-v1:b", nil),
+		PairNew("module-v2:c", "module-v2:c=3"))
+	if !equal(got, expected) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	nested := TESTOBJ.At("module-v1:container").AsObject()
+	mapped := nested.Map(func(_ string, v *Value) *Value { return v })
+	if mapped.module != nested.module || !equal(mapped, nested) {
+		t.Fatalf("expected: %s\ngot: %s\n", nested, mapped)
+	}
+}
+
+func TestObjectRenameModule(t *testing.T) {
+	build := func(mod string) *Object {
+		return TreeNew().
+			Assoc("/"+mod+":container/leaf", "foo").
+			Assoc("/"+mod+":container/other:aug/leaf", "bar").
+			Assoc("/"+mod+":container/other:aug/"+mod+":leaf", "baz").
+			Assoc("/"+mod+":list[key='a']/leaf", "x").
+			Assoc("/"+mod+":leaf-list", ArrayWith(1, 2)).
+			Assoc("/other:leaf", "qux").
+			Root().AsObject()
+	}
+	orig := build("old-mod")
+	expected := build("new-mod")
+	got := orig.RenameModule("old-mod", "new-mod")
+	if !equal(expected, got) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	gotEnc, _ := got.MarshalCanonical()
+	expectedEnc, _ := expected.MarshalCanonical()
+	if string(gotEnc) != string(expectedEnc) {
+		t.Fatalf("expected: %s\ngot: %s\n", expectedEnc, gotEnc)
+	}
+	if !equal(orig, build("old-mod")) {
+		t.Fatal("RenameModule modified the original object")
+	}
+	if unchanged := orig.RenameModule("missing", "new-mod"); !equal(orig,
+		unchanged) {
+		t.Fatalf("expected: %s\ngot: %s\n", orig, unchanged)
+	}
+}
+
+func TestObjectMergeWith(t *testing.T) {
+	old := ObjectWith(
+		PairNew("module-v1:count", 1),
+		PairNew("module-v1:name", "foo"),
+		PairNew("module-v1:container", ObjectWith(
+			PairNew("count", 5),
+			PairNew("only-old", "a"))),
+		PairNew("module-v1:leaf-list", ArrayWith(1, 2)))
+	new := ObjectWith(
+		PairNew("module-v1:count", 3),
+		PairNew("module-v1:name", "bar"),
+		PairNew("module-v1:container", ObjectWith(
+			PairNew("count", 2),
+			PairNew("only-new", "b"))),
+		PairNew("module-v1:leaf-list", ArrayWith(3)),
+		PairNew("module-v1:added", "baz"))
+	t.Run("resolve", func(t *testing.T) {
+		var keys []string
+		got := old.MergeWith(new, func(key string, o, n *Value) *Value {
+			keys = append(keys, key)
+			if o.IsString() {
+				return ValueNew(o.AsString() + n.AsString())
+			}
+			if o.AsInt32() > n.AsInt32() {
+				return o
+			}
+			return n
+		})
+		expected := ObjectWith(
+			PairNew("module-v1:count", 3),
+			PairNew("module-v1:name", "foobar"),
+			PairNew("module-v1:container", ObjectWith(
+				PairNew("count", 5),
+				PairNew("only-old", "a"),
+				PairNew("only-new", "b"))),
+			PairNew("module-v1:leaf-list", ArrayWith(3, 2)),
+			PairNew("module-v1:added", "baz"))
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		sort.Strings(keys)
+		expectedKeys := []string{
+			"module-v1:count", "module-v1:count", "module-v1:name",
+		}
+		if !reflect.DeepEqual(keys, expectedKeys) {
+			t.Fatalf("expected: %v\ngot: %v\n", expectedKeys, keys)
+		}
+	})
+	t.Run("nil-resolve", func(t *testing.T) {
+		got := old.MergeWith(new, nil)
+		expected := ValueNew(old).Merge(ValueNew(new))
+		if !equal(ValueNew(got), expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+}
+
 func TestObjectToData(t *testing.T) {
 	obj := ObjectWith(PairNew("a", "b"),
 		PairNew("c", "d"),
@@ -583,3 +970,85 @@ func TestTObject(t *testing.T) {
 		})
 	})
 }
+
+func TestObjectGetPath(t *testing.T) {
+	obj := TreeNew().
+		Assoc("/module-v1:container/inner/leaf", "foo").
+		Assoc("/module-v1:container/module-v2:aug/leaf", "bar").
+		Assoc("/module-v1:container/list", ArrayWith(
+			ObjectWith(PairNew("key", "a")))).
+		Root().AsObject()
+	cases := []struct {
+		name     string
+		path     []string
+		expected *Value
+	}{
+		{"qualified", []string{"module-v1:container", "module-v1:inner",
+			"module-v1:leaf"}, ValueNew("foo")},
+		{"implicit-module", []string{"module-v1:container", "inner",
+			"leaf"}, ValueNew("foo")},
+		{"augmented", []string{"module-v1:container", "module-v2:aug",
+			"leaf"}, ValueNew("bar")},
+		{"augmented-wrong-module", []string{"module-v1:container", "aug",
+			"leaf"}, nil},
+		{"missing", []string{"module-v1:container", "missing"}, nil},
+		{"through-leaf", []string{"module-v1:container", "inner",
+			"leaf", "more"}, nil},
+		{"through-list", []string{"module-v1:container", "list",
+			"key"}, nil},
+		{"empty", nil, ValueNew(obj)},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := obj.GetPath(test.path...)
+			if (got == nil) != (test.expected == nil) ||
+				(got != nil && !equal(got, test.expected)) {
+				t.Fatalf("expected: %v\ngot: %v\n", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestObjectDiff(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:container/leaf", "foo").
+		Assoc("/module-v1:container/inner/a", "1").
+		Assoc("/module-v1:container/module-v2:aug", "bar").
+		Assoc("/module-v1:container/list", ArrayWith(
+			ObjectWith(PairNew("key", "a")),
+			ObjectWith(PairNew("key", "b"))))
+	obj := tree.At("/module-v1:container").AsObject()
+	cases := []struct {
+		name  string
+		other *Object
+		paths []string
+	}{
+		{"identical", obj, nil},
+		{"changed", obj.Assoc("leaf", "baz"),
+			[]string{"/module-v1:leaf"}},
+		{"nested", obj.Assoc("inner", ObjectWith(PairNew("a", "2"))),
+			[]string{"/module-v1:inner/a"}},
+		{"augment-deleted", obj.Delete("module-v2:aug"),
+			[]string{"/module-v2:aug"}},
+		{"added", obj.Assoc("new", "x"),
+			[]string{"/module-v1:new"}},
+		{"list-entry", obj.Assoc("list", obj.At("list").AsArray().
+			Delete(1)), []string{"/module-v1:list[1]"}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			diff := obj.Diff(test.other)
+			var paths []string
+			for _, entry := range diff.Actions {
+				paths = append(paths, entry.Path.String())
+			}
+			if strings.Join(paths, " ") != strings.Join(test.paths, " ") {
+				t.Fatalf("expected: %v\ngot: %v\n", test.paths, paths)
+			}
+			got := TreeFromObject(obj).Edit(diff)
+			if !equal(got.Root(), ValueNew(test.other)) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.other, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"jsouthworth.net/go/immutable/vector"
+)
+
+// OrderedObject is an Object that remembers the order in which its
+// members were added and marshals them in that order. RFC7159 doesn't
+// give the members of an object an order, but some consumers depend on
+// one nonetheless. Associating a key that is already present replaces
+// its value without moving it, new keys are added at the end. Like
+// Objects, OrderedObjects are immutable.
+//
+// Only the order of the OrderedObject's own members is kept, the
+// objects nested within it are ordinary Objects. An OrderedObject can't
+// be stored in a Value, Object returns the members as an Object for
+// use with the rest of the package.
+type OrderedObject struct {
+	obj   *Object
+	order *vector.Vector
+}
+
+// OrderedObjectNew creates a new empty OrderedObject.
+func OrderedObjectNew() *OrderedObject {
+	return &OrderedObject{
+		obj:   ObjectNew(),
+		order: vector.Empty(),
+	}
+}
+
+// OrderedObjectWith creates a new OrderedObject populated with the
+// supplied pairs in order.
+func OrderedObjectWith(pairs ...Pair) *OrderedObject {
+	out := OrderedObjectNew()
+	for _, pair := range pairs {
+		out = out.Assoc(pair.Key(), pair.Value())
+	}
+	return out
+}
+
+// At returns the Value at the key's location or nil if it doesn't
+// exist.
+func (o *OrderedObject) At(key string) *Value {
+	return o.obj.At(key)
+}
+
+// Contains returns whether the key exists in the object.
+func (o *OrderedObject) Contains(key string) bool {
+	return o.obj.Contains(key)
+}
+
+// Find returns the Value at the key's location and whether it exists.
+func (o *OrderedObject) Find(key string) (*Value, bool) {
+	return o.obj.Find(key)
+}
+
+// Assoc associates the value with the key. If the key is already
+// present it keeps its position, otherwise it is added after all of
+// the existing keys. As with Object the key may be either
+// 'module:key' or just key if the module is the same as the object's
+// module.
+func (o *OrderedObject) Assoc(key string, value interface{}) *OrderedObject {
+	obj := o.obj.Assoc(key, value)
+	if obj == o.obj {
+		return o
+	}
+	order := o.order
+	if !o.obj.Contains(key) {
+		order = order.Append(o.obj.adaptKey(key))
+	}
+	return &OrderedObject{obj: obj, order: order}
+}
+
+// Delete removes the key from the object. Finding the key's position
+// takes time proportional to the number of members.
+func (o *OrderedObject) Delete(key string) *OrderedObject {
+	if !o.obj.Contains(key) {
+		return o
+	}
+	k := o.obj.adaptKey(key)
+	index := -1
+	o.order.Range(func(i int, ok string) bool {
+		if ok == k {
+			index = i
+		}
+		return index == -1
+	})
+	return &OrderedObject{
+		obj:   o.obj.Delete(key),
+		order: o.order.Delete(index),
+	}
+}
+
+// Length returns the number of members in the object.
+func (o *OrderedObject) Length() int {
+	return o.obj.Length()
+}
+
+// Keys returns the object's keys in order.
+func (o *OrderedObject) Keys() []string {
+	out := make([]string, 0, o.order.Length())
+	o.order.Range(func(_ int, k string) {
+		out = append(out, k)
+	})
+	return out
+}
+
+// Range iterates over the object's members in order. It accepts the
+// same functions as Object.Range.
+func (o *OrderedObject) Range(fn interface{}) *OrderedObject {
+	rangeFn := genObjectRangeFunc(fn)
+	o.order.Range(func(_ int, k string) bool {
+		return rangeFn(k, o.obj.At(k))
+	})
+	return o
+}
+
+// Object returns the object's members as an unordered Object.
+func (o *OrderedObject) Object() *Object {
+	return o.obj
+}
+
+// Equal reports whether other is an OrderedObject with the same
+// members in the same order.
+func (o *OrderedObject) Equal(other interface{}) bool {
+	oo, isOrdered := other.(*OrderedObject)
+	return isOrdered &&
+		equal(o.obj, oo.obj) &&
+		equal(o.order, oo.order)
+}
+
+// String returns a string representation of the OrderedObject.
+func (o *OrderedObject) String() string {
+	var buf bytes.Buffer
+	o.marshalRFC7951(&buf, o.obj.module)
+	return buf.String()
+}
+
+// MarshalRFC7951 returns the object encoded in an RFC7951 compatible
+// way with its members in order.
+func (o *OrderedObject) MarshalRFC7951() ([]byte, error) {
+	var buf bytes.Buffer
+	err := o.marshalRFC7951(&buf, "")
+	return buf.Bytes(), err
+}
+
+// WriteRFC7951 writes the object encoded as RFC7951 data to w with its
+// members in order.
+func (o *OrderedObject) WriteRFC7951(w io.Writer) error {
+	return writeRFC7951(w, o)
+}
+
+func (o *OrderedObject) marshalRFC7951(w marshalWriter, module string) error {
+	return o.obj.encodePairs(w, module, func(fn func(Pair) bool) {
+		o.Range(fn)
+	})
+}
+
+// UnmarshalRFC7951 replaces the contents of the object with the
+// members of the RFC7951 encoded object in msg, in the order in which
+// they appear.
+func (o *OrderedObject) UnmarshalRFC7951(msg []byte) error {
+	dec := NewStreamDecoder(bytes.NewReader(msg))
+	ev, err := dec.Next()
+	if err != nil {
+		return err
+	}
+	if ev.Kind != StreamStartObject {
+		return errors.New("cannot unmarshal " + ev.Kind.String() +
+			" into an ordered object")
+	}
+	out := OrderedObjectNew()
+	for {
+		ev, err := dec.Next()
+		if err != nil {
+			return err
+		}
+		if ev.Kind == StreamEndObject {
+			break
+		}
+		val, err := dec.DecodeValue()
+		if err != nil {
+			return err
+		}
+		out = out.Assoc(ev.Key, val)
+	}
+	if err := expectEOF(dec); err != nil {
+		return err
+	}
+	*o = *out
+	return nil
+}
+
+// expectEOF returns an error unless dec is at the end of its input.
+func expectEOF(dec *StreamDecoder) error {
+	if dec.More() {
+		return errors.New("unexpected data after ordered object")
+	}
+	_, err := dec.Next()
+	switch err {
+	case io.EOF:
+		return nil
+	case nil:
+		return errors.New("unexpected data after ordered object")
+	}
+	return err
+}
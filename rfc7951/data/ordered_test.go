@@ -0,0 +1,140 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/danos/encoding/rfc7951"
+)
+
+func TestOrderedObject(t *testing.T) {
+	obj := OrderedObjectNew().
+		Assoc("module-v1:zulu", 1).
+		Assoc("module-v1:alpha", "a").
+		Assoc("module-v2:mike", ObjectWith(PairNew("leaf", true)))
+
+	t.Run("keys", func(t *testing.T) {
+		expected := []string{
+			"module-v1:zulu",
+			"module-v1:alpha",
+			"module-v2:mike",
+		}
+		if got := obj.Keys(); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+		}
+		var ranged []string
+		obj.Range(func(key string) {
+			ranged = append(ranged, key)
+		})
+		if !reflect.DeepEqual(ranged, expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, ranged)
+		}
+	})
+	t.Run("marshal", func(t *testing.T) {
+		expected := `{"module-v1:zulu":1,"module-v1:alpha":"a",` +
+			`"module-v2:mike":{"leaf":true}}`
+		got, err := rfc7951.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		var buf bytes.Buffer
+		if err := obj.WriteRFC7951(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, buf.String())
+		}
+	})
+	t.Run("assoc existing key", func(t *testing.T) {
+		got := obj.Assoc("module-v1:zulu", 2)
+		if !reflect.DeepEqual(got.Keys(), obj.Keys()) {
+			t.Fatalf("expected: %v\ngot: %v\n", obj.Keys(), got.Keys())
+		}
+		if got.At("module-v1:zulu").ToUint32() != 2 {
+			t.Fatalf("value not replaced: %s", got)
+		}
+		if obj.At("module-v1:zulu").ToUint32() != 1 {
+			t.Fatalf("original modified: %s", obj)
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		got := obj.Delete("module-v1:alpha").Assoc("module-v1:alpha", "b")
+		expected := []string{
+			"module-v1:zulu",
+			"module-v2:mike",
+			"module-v1:alpha",
+		}
+		if !reflect.DeepEqual(got.Keys(), expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, got.Keys())
+		}
+		if obj.Delete("module-v1:missing") != obj {
+			t.Fatal("deleting a missing key should return the object")
+		}
+	})
+	t.Run("equal", func(t *testing.T) {
+		same := OrderedObjectWith(
+			PairNew("module-v1:zulu", 1),
+			PairNew("module-v1:alpha", "a"),
+			PairNew("module-v2:mike", ObjectWith(PairNew("leaf", true))))
+		if !obj.Equal(same) {
+			t.Fatalf("expected: %s\ngot: %s\n", obj, same)
+		}
+		reordered := OrderedObjectWith(
+			PairNew("module-v1:alpha", "a"),
+			PairNew("module-v1:zulu", 1),
+			PairNew("module-v2:mike", ObjectWith(PairNew("leaf", true))))
+		if obj.Equal(reordered) {
+			t.Fatal("objects with different orders should not be equal")
+		}
+		if !equal(obj.Object(), reordered.Object()) {
+			t.Fatal("unordered objects should be equal")
+		}
+	})
+	t.Run("unmarshal", func(t *testing.T) {
+		in := `{"module-v1:b":"1","module-v1:a":{"y":[1,2]},` +
+			`"module-v2:c":[null]}`
+		var got OrderedObject
+		if err := rfc7951.Unmarshal([]byte(in), &got); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"module-v1:b", "module-v1:a", "module-v2:c"}
+		if !reflect.DeepEqual(got.Keys(), expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, got.Keys())
+		}
+		if !got.At("module-v2:c").IsEmpty() {
+			t.Fatalf("expected empty leaf, got: %s", got.At("module-v2:c"))
+		}
+		out, err := got.MarshalRFC7951()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != in {
+			t.Fatalf("expected: %s\ngot: %s\n", in, out)
+		}
+		if err := got.UnmarshalRFC7951([]byte(`[1]`)); err == nil {
+			t.Fatal("expected an error for an array")
+		}
+		for _, trailing := range []string{
+			`{"module-v1:a":1} {"x":`,
+			`{"module-v1:a":1} {}`,
+			`{"module-v1:a":1}]`,
+		} {
+			if err := got.UnmarshalRFC7951([]byte(trailing)); err == nil {
+				t.Fatalf("expected an error for trailing data in %s",
+					trailing)
+			}
+		}
+		if !reflect.DeepEqual(got.Keys(), expected) {
+			t.Fatalf("object changed by failed unmarshal: %v", got.Keys())
+		}
+	})
+}
@@ -0,0 +1,459 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/danos/encoding/rfc7951"
+)
+
+// Decoder reads RFC7951 encoded Trees and Values from an input stream.
+type Decoder struct {
+	dec  *rfc7951.Decoder
+	opts decodeOpts
+}
+
+// NewDecoder returns a new decoder that reads from r. The decoder
+// introduces its own buffering and may read data from r beyond the
+// values requested.
+func NewDecoder(r io.Reader, options ...DecodeOption) *Decoder {
+	dec := &Decoder{
+		dec: rfc7951.NewDecoder(r),
+	}
+	for _, opt := range options {
+		opt(&dec.opts)
+	}
+	return dec
+}
+
+// DecodeOption is an option to NewDecoder.
+type DecodeOption func(*decodeOpts)
+
+// ParseBigInts causes integers that are too large to be stored in 64
+// bits to be decoded as BigInt values instead of strings.
+func ParseBigInts() DecodeOption {
+	return func(opts *decodeOpts) {
+		opts.bigInts = true
+	}
+}
+
+// ParseDecimals causes quoted numbers with a fractional part, such as
+// YANG decimal64 values, to be decoded as Decimal values instead of
+// being kept as strings.
+func ParseDecimals() DecodeOption {
+	return func(opts *decodeOpts) {
+		opts.decimals = true
+	}
+}
+
+// WithInterner causes the decoder to intern strings and scalar values
+// using i rather than an interner private to each decoded value. The
+// same Interner may be given to any number of decoders.
+func WithInterner(i *Interner) DecodeOption {
+	return func(opts *decodeOpts) {
+		opts.interner = i
+	}
+}
+
+// Decode reads the next RFC7951 encoded value from the input and
+// stores it in the supplied Tree.
+func (dec *Decoder) Decode(t *Tree) error {
+	msg, err := dec.next()
+	if err != nil {
+		return err
+	}
+	if t.root == nil {
+		t.root = ValueNew(ObjectNew())
+	}
+	return t.root.unmarshalWithOpts(msg, &dec.opts)
+}
+
+// DecodeValue reads the next RFC7951 encoded value from the input and
+// stores it in the supplied Value.
+func (dec *Decoder) DecodeValue(v *Value) error {
+	msg, err := dec.next()
+	if err != nil {
+		return err
+	}
+	return v.unmarshalWithOpts(msg, &dec.opts)
+}
+
+// More reports whether there is another value in the input stream.
+func (dec *Decoder) More() bool {
+	return dec.dec.More()
+}
+
+func (dec *Decoder) next() ([]byte, error) {
+	var msg rfc7951.RawMessage
+	err := dec.dec.Decode(&msg)
+	return msg, err
+}
+
+// Encoder writes RFC7951 encoded Trees and Values to an output stream.
+type Encoder struct {
+	enc  *rfc7951.Encoder
+	opts encodeOpts
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer, options ...EncodeOption) *Encoder {
+	enc := &Encoder{
+		enc: rfc7951.NewEncoder(w),
+	}
+	for _, opt := range options {
+		opt(&enc.opts)
+	}
+	return enc
+}
+
+// EncodeOption is an option to NewEncoder.
+type EncodeOption func(*encodeOpts)
+
+type encodeOpts struct {
+	nonConformantNumbers bool
+}
+
+// NonConformantNumbers causes the encoder to write 64 bit integers as
+// plain JSON numbers instead of the quoted strings RFC7951 requires.
+// The output is NOT valid RFC7951, it is only intended for consumers
+// that expect plain JSON numbers and can represent 64 bit integers
+// exactly, this package's decoders don't accept integers wider than 32
+// bits unless they are quoted. Floats, which this package always
+// encodes as quoted strings, are unaffected.
+func NonConformantNumbers() EncodeOption {
+	return func(opts *encodeOpts) {
+		opts.nonConformantNumbers = true
+	}
+}
+
+// Encode writes the RFC7951 encoding of the Tree to the stream,
+// followed by a newline character.
+func (enc *Encoder) Encode(t *Tree) error {
+	if enc.opts.nonConformantNumbers {
+		return enc.encodeNonConformant(t.Root())
+	}
+	return enc.enc.Encode(t)
+}
+
+// EncodeValue writes the RFC7951 encoding of the Value to the stream,
+// followed by a newline character.
+func (enc *Encoder) EncodeValue(v *Value) error {
+	if enc.opts.nonConformantNumbers {
+		return enc.encodeNonConformant(v)
+	}
+	return enc.enc.Encode(v)
+}
+
+func (enc *Encoder) encodeNonConformant(v *Value) error {
+	var buf bytes.Buffer
+	err := v.marshalRFC7951(numberWriter{&buf}, "")
+	if err != nil {
+		return err
+	}
+	msg := rfc7951.RawMessage(buf.Bytes())
+	return enc.enc.Encode(&msg)
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded
+// value as if indented by rfc7951.Indent(dst, src, prefix, indent).
+// Calling SetIndent("", "") disables indentation.
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.enc.SetIndent(prefix, indent)
+}
+
+// StreamEventKind identifies the kind of a StreamEvent.
+type StreamEventKind int
+
+const (
+	// StreamStartObject marks the start of an object.
+	StreamStartObject StreamEventKind = iota
+	// StreamEndObject marks the end of an object.
+	StreamEndObject
+	// StreamStartArray marks the start of an array.
+	StreamStartArray
+	// StreamEndArray marks the end of an array.
+	StreamEndArray
+	// StreamKey is the name of the next member of an object.
+	StreamKey
+	// StreamValue is a leaf value.
+	StreamValue
+)
+
+var streamEventKindNames = [...]string{
+	StreamStartObject: "start-object",
+	StreamEndObject:   "end-object",
+	StreamStartArray:  "start-array",
+	StreamEndArray:    "end-array",
+	StreamKey:         "key",
+	StreamValue:       "value",
+}
+
+// String returns a human readable name for the kind.
+func (k StreamEventKind) String() string {
+	if k < 0 || int(k) >= len(streamEventKindNames) {
+		return fmt.Sprintf("StreamEventKind(%d)", int(k))
+	}
+	return streamEventKindNames[k]
+}
+
+// StreamEvent is a single event read by a StreamDecoder. Key is set
+// for StreamKey events and Value for StreamValue events.
+type StreamEvent struct {
+	Kind  StreamEventKind
+	Key   string
+	Value *Value
+}
+
+// StreamDecoder reads RFC7951 encoded input as a sequence of events
+// without materializing the values it reads. This allows inputs that
+// are too large to hold in memory to be processed incrementally.
+//
+// Leaves are interpreted exactly as Unmarshal interprets them, so
+// quoted integers become 64 bit integers, and [null] is
+// reported as a single StreamValue event holding Empty() rather than
+// as an array. Keys are reported qualified by their module, as they
+// are stored in an Object, with unqualified keys inheriting the module
+// of the enclosing member.
+type StreamDecoder struct {
+	dec     *rfc7951.Decoder
+	opts    decodeOpts
+	frames  []streamFrame
+	module  string
+	pending []rfc7951.Token
+}
+
+type streamFrame struct {
+	module    string
+	isObject  bool
+	expectKey bool
+}
+
+// NewStreamDecoder returns a new StreamDecoder that reads from r. The
+// decoder introduces its own buffering and may read data from r beyond
+// the events requested.
+func NewStreamDecoder(r io.Reader, options ...DecodeOption) *StreamDecoder {
+	dec := &StreamDecoder{
+		dec: rfc7951.NewDecoder(r),
+	}
+	dec.dec.UseNumber()
+	for _, opt := range options {
+		opt(&dec.opts)
+	}
+	return dec
+}
+
+// Next returns the next event in the input. At the end of the input
+// Next returns io.EOF. The input may contain several consecutive
+// values, their events are returned in turn.
+func (dec *StreamDecoder) Next() (StreamEvent, error) {
+	tok, err := dec.token()
+	if err != nil {
+		return StreamEvent{}, err
+	}
+	switch tok {
+	case rfc7951.Delim('{'):
+		dec.push(true)
+		return StreamEvent{Kind: StreamStartObject}, nil
+	case rfc7951.Delim('}'):
+		dec.pop()
+		return StreamEvent{Kind: StreamEndObject}, nil
+	case rfc7951.Delim('['):
+		isEmpty, err := dec.emptyLeaf()
+		if err != nil {
+			return StreamEvent{}, err
+		}
+		if isEmpty {
+			return StreamEvent{Kind: StreamValue, Value: Empty()}, nil
+		}
+		dec.push(false)
+		return StreamEvent{Kind: StreamStartArray}, nil
+	case rfc7951.Delim(']'):
+		dec.pop()
+		return StreamEvent{Kind: StreamEndArray}, nil
+	}
+	if dec.expectingKey() {
+		key := tok.(string)
+		module, _ := dec.parent().parseKey(key)
+		dec.module = module
+		dec.frames[len(dec.frames)-1].expectKey = false
+		return StreamEvent{
+			Kind: StreamKey,
+			Key:  dec.parent().adaptKey(key),
+		}, nil
+	}
+	val, err := dec.leaf(tok)
+	if err != nil {
+		return StreamEvent{}, err
+	}
+	dec.valueEnd()
+	return StreamEvent{Kind: StreamValue, Value: val}, nil
+}
+
+// DecodeValue reads the whole of the next value in the input and
+// returns it, building it from the value's events. This allows, for
+// instance, each entry of a large list to be decoded in turn.
+// DecodeValue must be called where a value is expected, at the top
+// level, after a StreamKey event or within an array.
+func (dec *StreamDecoder) DecodeValue() (*Value, error) {
+	if dec.expectingKey() {
+		return nil, errors.New("stream decoder: expected a key")
+	}
+	ev, err := dec.Next()
+	if err != nil {
+		return nil, err
+	}
+	return dec.build(ev)
+}
+
+func (dec *StreamDecoder) build(ev StreamEvent) (*Value, error) {
+	switch ev.Kind {
+	case StreamValue:
+		return ev.Value, nil
+	case StreamStartObject:
+		obj := objectNew()
+		obj.module = dec.module
+		tobj := obj.asTransient()
+		for {
+			ev, err := dec.Next()
+			if err != nil {
+				return nil, err
+			}
+			if ev.Kind == StreamEndObject {
+				return ValueNew(tobj.asPersistent()), nil
+			}
+			val, err := dec.DecodeValue()
+			if err != nil {
+				return nil, err
+			}
+			tobj.Assoc(ev.Key, val)
+		}
+	case StreamStartArray:
+		arr := arrayNew()
+		arr.module = dec.module
+		tarr := arr.asTransient()
+		for {
+			ev, err := dec.Next()
+			if err != nil {
+				return nil, err
+			}
+			if ev.Kind == StreamEndArray {
+				return ValueNew(tarr.asPersistent()), nil
+			}
+			val, err := dec.build(ev)
+			if err != nil {
+				return nil, err
+			}
+			tarr.Append(val)
+		}
+	default:
+		return nil, fmt.Errorf("stream decoder: unexpected %s", ev.Kind)
+	}
+}
+
+// Depth returns the number of objects and arrays that have been
+// started but not yet ended.
+func (dec *StreamDecoder) Depth() int {
+	return len(dec.frames)
+}
+
+// More reports whether there is another element in the current array
+// or object, or another value in the input stream at the top level.
+func (dec *StreamDecoder) More() bool {
+	if len(dec.pending) != 0 {
+		return dec.pending[0] != rfc7951.Delim(']')
+	}
+	return dec.dec.More()
+}
+
+func (dec *StreamDecoder) token() (rfc7951.Token, error) {
+	if len(dec.pending) != 0 {
+		tok := dec.pending[0]
+		dec.pending = dec.pending[1:]
+		return tok, nil
+	}
+	return dec.dec.Token()
+}
+
+// emptyLeaf reads ahead after the start of an array to determine
+// whether it is an empty leaf, [null]. Tokens that were read but that
+// don't complete an empty leaf are kept for subsequent events.
+func (dec *StreamDecoder) emptyLeaf() (bool, error) {
+	tok, err := dec.dec.Token()
+	if err != nil {
+		return false, err
+	}
+	dec.pending = append(dec.pending, tok)
+	if tok != nil {
+		return false, nil
+	}
+	tok, err = dec.dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok == rfc7951.Delim(']') {
+		dec.pending = dec.pending[:0]
+		dec.valueEnd()
+		return true, nil
+	}
+	dec.pending = append(dec.pending, tok)
+	return false, nil
+}
+
+func (dec *StreamDecoder) leaf(tok rfc7951.Token) (*Value, error) {
+	switch v := tok.(type) {
+	case rfc7951.Number:
+		val := valueNew(nil)
+		err := val.unmarshalRFC7951([]byte(v), dec.module,
+			nil, nil, &dec.opts)
+		return val, err
+	case string:
+		return valueNew(inferStringData(v, &dec.opts)), nil
+	default:
+		return valueNew(v), nil
+	}
+}
+
+func (dec *StreamDecoder) push(isObject bool) {
+	dec.frames = append(dec.frames, streamFrame{
+		module:    dec.module,
+		isObject:  isObject,
+		expectKey: isObject,
+	})
+}
+
+func (dec *StreamDecoder) pop() {
+	dec.frames = dec.frames[:len(dec.frames)-1]
+	dec.valueEnd()
+}
+
+// valueEnd restores the module of the enclosing container once one of
+// its values has been read.
+func (dec *StreamDecoder) valueEnd() {
+	if len(dec.frames) == 0 {
+		dec.module = ""
+		return
+	}
+	top := &dec.frames[len(dec.frames)-1]
+	dec.module = top.module
+	top.expectKey = top.isObject
+}
+
+func (dec *StreamDecoder) expectingKey() bool {
+	return len(dec.frames) != 0 &&
+		dec.frames[len(dec.frames)-1].expectKey
+}
+
+// parent returns an empty object belonging to the enclosing object's
+// module, used to qualify the keys of its members.
+func (dec *StreamDecoder) parent() *Object {
+	obj := objectNew()
+	obj.module = dec.frames[len(dec.frames)-1].module
+	return obj
+}
@@ -0,0 +1,353 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danos/encoding/rfc7951"
+)
+
+func TestDecoderDecode(t *testing.T) {
+	in := `{"module-v1:foo":{"bar":"baz"}}
+{"module-v1:foo":{"bar":"quux"}}`
+	dec := NewDecoder(strings.NewReader(in))
+	for _, expected := range []string{"baz", "quux"} {
+		if !dec.More() {
+			t.Fatal("expected more input")
+		}
+		var tree Tree
+		err := dec.Decode(&tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tree.At("/module-v1:foo/bar").ToString()
+		if got != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	}
+	if dec.More() {
+		t.Fatal("unexpected additional input")
+	}
+}
+
+func TestDecoderDecodeValue(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`[1,2,3] "10"`))
+	var arr Value
+	if err := dec.DecodeValue(&arr); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(arr.AsArray(), ArrayWith(1, 2, 3)) {
+		t.Fatalf("expected: %s\ngot: %s\n", ArrayWith(1, 2, 3), &arr)
+	}
+	var num Value
+	if err := dec.DecodeValue(&num); err != nil {
+		t.Fatal(err)
+	}
+	if num.AsUint64() != 10 {
+		t.Fatalf("expected: %d\ngot: %s\n", 10, &num)
+	}
+}
+
+func TestDecoderDecodeInvalid(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"module-v1:foo":`))
+	var tree Tree
+	if err := dec.Decode(&tree); err == nil {
+		t.Fatal("expected an error for truncated input")
+	}
+}
+
+func TestDecoderWithInterner(t *testing.T) {
+	const in = `{"module-v1:foo":{"bar":"baz","list":[{"name":"a"}]}}`
+	interner := InternerNew()
+	decode := func(options ...DecodeOption) *Tree {
+		var tree Tree
+		dec := NewDecoder(strings.NewReader(in), options...)
+		if err := dec.Decode(&tree); err != nil {
+			t.Fatal(err)
+		}
+		return &tree
+	}
+	paths := []string{
+		"/module-v1:foo/bar",
+		"/module-v1:foo/list[0]/name",
+	}
+
+	one, two := decode(WithInterner(interner)), decode(WithInterner(interner))
+	if !one.Equal(two) {
+		t.Fatalf("expected: %s\ngot: %s\n", one, two)
+	}
+	for _, path := range paths {
+		if one.At(path) != two.At(path) {
+			t.Fatalf("expected %s to be shared", path)
+		}
+	}
+	if one.At("/module-v1:foo") == two.At("/module-v1:foo") {
+		t.Fatal("containers should not be shared")
+	}
+
+	interner.Reset()
+	three := decode(WithInterner(interner))
+	for _, path := range paths {
+		if one.At(path) == three.At(path) {
+			t.Fatalf("expected %s not to be shared after Reset", path)
+		}
+	}
+	four := decode()
+	for _, path := range paths {
+		if three.At(path) == four.At(path) {
+			t.Fatalf("expected %s not to be shared without interner", path)
+		}
+	}
+}
+
+func TestEncoderEncode(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	tree := TreeNew().Assoc("/module-v1:foo/bar", "baz")
+	if err := enc.Encode(tree); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeValue(ValueNew(ArrayWith(1, 2))); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"module-v1:foo":{"bar":"baz"}}
+[1,2]
+`
+	if buf.String() != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, buf.String())
+	}
+
+	var got Tree
+	if err := NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(tree, &got) {
+		t.Fatalf("expected: %s\ngot: %s\n", tree, &got)
+	}
+}
+
+func TestEncoderNonConformantNumbers(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:foo/count", uint64(1)<<40).
+		Assoc("/module-v1:foo/offset", int64(-1)<<40).
+		Assoc("/module-v1:foo/small", int32(-5)).
+		Assoc("/module-v1:foo/ratio", 0.5).
+		Assoc("/module-v1:foo/list", ArrayWith(uint64(7), "x"))
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, NonConformantNumbers())
+	enc.SetIndent("", " ")
+	if err := enc.Encode(tree); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetIndent("", "")
+	if err := enc.EncodeValue(ValueNew(int64(-1) << 40)); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]map[string]interface{}
+	dec := rfc7951.NewDecoder(&buf)
+	dec.UseNumber()
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string]interface{}{
+		"module-v1:foo": {
+			"count":  rfc7951.Number("1099511627776"),
+			"offset": rfc7951.Number("-1099511627776"),
+			"small":  rfc7951.Number("-5"),
+			"ratio":  "0.5",
+			"list":   []interface{}{rfc7951.Number("7"), "x"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+	}
+	var num interface{}
+	if err := dec.Decode(&num); err != nil {
+		t.Fatal(err)
+	}
+	if num != rfc7951.Number("-1099511627776") {
+		t.Fatalf("expected: -1099511627776\ngot: %v\n", num)
+	}
+
+	buf.Reset()
+	if err := NewEncoder(&buf).Encode(tree); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := tree.MarshalRFC7951()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(msg)+"\n" {
+		t.Fatalf("expected: %s\ngot: %s\n", msg, buf.String())
+	}
+}
+
+func TestEncoderSetIndent(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(TreeNew().Assoc("/module-v1:foo", "bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "module-v1:foo": "bar"
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, buf.String())
+	}
+}
+
+func TestStreamDecoderEvents(t *testing.T) {
+	in := `{"module-v1:foo":{"bar":"10","m2:baz":[1,-2,"3.5"],` +
+		`"e":[null],"n":null,"l":[null,true],"a":[]}} "x"`
+	expected := []string{
+		"start-object",
+		"key module-v1:foo",
+		"start-object",
+		"key module-v1:bar",
+		"value uint64 10",
+		"key m2:baz",
+		"start-array",
+		"value uint32 1",
+		"value int32 -2",
+		"value string 3.5",
+		"end-array",
+		"key module-v1:e",
+		"value empty [null]",
+		"key module-v1:n",
+		"value null null",
+		"key module-v1:l",
+		"start-array",
+		"value null null",
+		"value boolean true",
+		"end-array",
+		"key module-v1:a",
+		"start-array",
+		"end-array",
+		"end-object",
+		"end-object",
+		"value string x",
+	}
+	dec := NewStreamDecoder(strings.NewReader(in))
+	var got []string
+	for {
+		ev, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch ev.Kind {
+		case StreamKey:
+			got = append(got, fmt.Sprintf("%s %s", ev.Kind, ev.Key))
+		case StreamValue:
+			got = append(got, fmt.Sprintf("%s %s %s",
+				ev.Kind, ev.Value.Type(), ev.Value.RFC7951String()))
+		default:
+			got = append(got, ev.Kind.String())
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected: %s\ngot: %s\n",
+			strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	if dec.Depth() != 0 {
+		t.Fatalf("expected depth 0, got %d", dec.Depth())
+	}
+}
+
+func TestStreamDecoderDecodeValue(t *testing.T) {
+	in := `{"module-v1:list":[{"key":"a","m2:leaf":"1"},` +
+		`{"key":"b","e":[null]}]}`
+	dec := NewStreamDecoder(strings.NewReader(in))
+	for _, kind := range []StreamEventKind{
+		StreamStartObject, StreamKey, StreamStartArray,
+	} {
+		ev, err := dec.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Kind != kind {
+			t.Fatalf("expected: %s\ngot: %s\n", kind, ev.Kind)
+		}
+	}
+	var entries []*Value
+	for dec.More() {
+		entry, err := dec.DecodeValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	var tree Tree
+	if err := tree.UnmarshalRFC7951([]byte(in)); err != nil {
+		t.Fatal(err)
+	}
+	expected := tree.At("/module-v1:list").AsArray()
+	if len(entries) != expected.Length() {
+		t.Fatalf("expected %d entries, got %d",
+			expected.Length(), len(entries))
+	}
+	for i, entry := range entries {
+		if !equal(entry, expected.At(i)) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected.At(i), entry)
+		}
+	}
+	if entries[0].AsObject().At("m2:leaf").Type() != KindUint64 {
+		t.Fatalf("expected quoted number to decode as uint64")
+	}
+	for _, kind := range []StreamEventKind{
+		StreamEndArray, StreamEndObject,
+	} {
+		ev, err := dec.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Kind != kind {
+			t.Fatalf("expected: %s\ngot: %s\n", kind, ev.Kind)
+		}
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	dec = NewStreamDecoder(strings.NewReader(`[null,{"m:a":"1"}] [null]`))
+	got, err := dec.DecodeValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != `[null,{"m:a":"1"}]` {
+		t.Fatalf("expected: %s\ngot: %s\n",
+			`[null,{"m:a":"1"}]`, got)
+	}
+	got, err = dec.DecodeValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(got, Empty()) {
+		t.Fatalf("expected: %s\ngot: %s\n", Empty(), got)
+	}
+}
+
+func TestStreamDecoderInvalid(t *testing.T) {
+	dec := NewStreamDecoder(strings.NewReader(`{"module-v1:foo":]`))
+	var err error
+	for err == nil {
+		_, err = dec.Next()
+	}
+	if err == io.EOF {
+		t.Fatal("expected an error for invalid input")
+	}
+}
@@ -7,8 +7,14 @@ package data
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
 
+	"github.com/danos/encoding/rfc7951"
 	"jsouthworth.net/go/immutable/vector"
+	"jsouthworth.net/go/try"
 )
 
 // TreeNew creates a new empty tree
@@ -29,6 +35,57 @@ func TreeFromValue(v *Value) *Tree {
 	return TreeFromObject(ObjectWith(PairNew("rfc7951:data", v)))
 }
 
+// TreeFromFlat builds a tree from a map of instance-identifiers to native
+// values, such as that returned by Flatten, by repeated Assoc. List and
+// leaf-list entries may be identified by key or by position. Positional
+// entries are assigned in ascending order, so the result doesn't depend
+// on the order of iteration over m. An error is returned if any path is
+// malformed or its value can't be stored.
+func TreeFromFlat(m map[string]interface{}) (*Tree, error) {
+	type flatEntry struct {
+		path string
+		id   *InstanceID
+	}
+	entries := make([]flatEntry, 0, len(m))
+	for path := range m {
+		id, err := flatInstanceID(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, flatEntry{path: path, id: id})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].id.Compare(entries[j].id) < 0
+	})
+	out := TreeNew()
+	for _, entry := range entries {
+		var err error
+		out, err = flatAssoc(out, entry.path, entry.id, m[entry.path])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func flatInstanceID(path string) (*InstanceID, error) {
+	id, err := InstanceIDParse(path)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %v", path, err)
+	}
+	return id, nil
+}
+
+func flatAssoc(
+	t *Tree, path string, id *InstanceID, value interface{},
+) (*Tree, error) {
+	out, err := try.Apply(t.AssocIn, id, value)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %v", path, err)
+	}
+	return out.(*Tree), nil
+}
+
 // Tree represents an RFC7951 tree, it is rooted at an object and
 // provides additional functionallity on top of the object
 // functionallity. Trees are indexed using instance-identifiers
@@ -45,18 +102,98 @@ func (t *Tree) Root() *Value {
 	return t.root
 }
 
+// Detach returns a deep copy of the tree that shares no storage with
+// the original tree or any interned data used to unmarshal it.
+func (t *Tree) Detach() *Tree {
+	return &Tree{
+		root: t.Root().Detach(),
+	}
+}
+
 // Merge merges two trees together by recursively calling Merge on the roots.
 func (t *Tree) Merge(new *Tree) *Tree {
+	return t.MergeValue(new.Root())
+}
+
+// MergeArrays merges two trees together like Merge but combines any
+// arrays according to the supplied policy, see Value.MergeArrays.
+func (t *Tree) MergeArrays(new *Tree, policy ArrayMergePolicy) *Tree {
 	return TreeFromObject(t.Root().
-		Merge(new.Root()).
+		MergeArrays(new.Root(), policy).
 		AsObject())
 }
 
+// MergeObject merges the object into the root of the tree.
+func (t *Tree) MergeObject(o *Object) *Tree {
+	return t.MergeValue(ValueNew(o))
+}
+
+// MergeValue merges the value into the root of the tree. Only Object
+// values can be merged with the root, any other value leaves the
+// tree unchanged.
+func (t *Tree) MergeValue(v *Value) *Tree {
+	return TreeFromObject(t.Root().
+		Merge(v).
+		AsObject())
+}
+
+// ApplyMergePatch applies an RFC 7386 JSON Merge Patch, encoded as
+// RFC7951 data, to the tree. Unlike Merge, a member of the patch whose
+// value is null deletes the member from the tree, and any value other
+// than an object, including an array, replaces the existing value
+// wholesale rather than being merged with it. Objects in the patch are
+// merged recursively. An error is returned if the patch can't be
+// decoded or isn't an object.
+func (t *Tree) ApplyMergePatch(patch []byte) (*Tree, error) {
+	// Value's unmarshalling assumes well formed input, so check it
+	// first.
+	var raw rfc7951.RawMessage
+	if err := rfc7951.Unmarshal(patch, &raw); err != nil {
+		return nil, err
+	}
+	var v Value
+	if err := v.UnmarshalRFC7951(raw); err != nil {
+		return nil, err
+	}
+	if !v.IsObject() {
+		return nil, fmt.Errorf("merge patch must be an object, got %s",
+			v.Type())
+	}
+	return TreeFromObject(applyMergePatch(t.Root(), &v).AsObject()), nil
+}
+
+// applyMergePatch implements the RFC 7386 MergePatch function.
+func applyMergePatch(target, patch *Value) *Value {
+	if !patch.IsObject() {
+		return patch
+	}
+	if target == nil || !target.IsObject() {
+		target = ValueNew(ObjectNew())
+	}
+	out := target.AsObject()
+	patch.AsObject().Range(func(key string, v *Value) {
+		if v.data == nil {
+			out = out.Delete(key)
+			return
+		}
+		out = out.Assoc(key, applyMergePatch(out.At(key), v))
+	})
+	return ValueNew(out)
+}
+
 // At returns the Value at the instance-idenfitifer provided.
 func (t *Tree) At(instanceID string) *Value {
 	return t.at(InstanceIDNew(instanceID))
 }
 
+// GetIn returns the Value at the pre-parsed instance-identifier. It is
+// equivalent to At but avoids parsing the instance-identifier on each
+// call; when the same path is used repeatedly, build it once with
+// InstanceIDNew and reuse it.
+func (t *Tree) GetIn(id *InstanceID) *Value {
+	return t.at(id)
+}
+
 func (t *Tree) at(id *InstanceID) *Value {
 	return id.MatchAgainst(t.Root())
 }
@@ -71,13 +208,121 @@ func (t *Tree) find(id *InstanceID) (*Value, bool) {
 	return id.Find(t.Root())
 }
 
+// atOrNull returns the Value at the instance-identifier or a null
+// Value if there is none, so that the Value.To* defaults apply to
+// missing paths.
+func (t *Tree) atOrNull(instanceID string) *Value {
+	if v := t.At(instanceID); v != nil {
+		return v
+	}
+	return ValueNew(nil)
+}
+
+// AtString returns the string at the instance-identifier. The default,
+// or "" if no default is supplied, is returned if the path doesn't
+// exist or Value.ToString can't convert the value found there. It saves
+// the Find, type check and default dance when reading configuration.
+func (t *Tree) AtString(instanceID string, defaultVal ...string) string {
+	return t.atOrNull(instanceID).ToString(defaultVal...)
+}
+
+// AtInt32 is like AtString but returns the value as an int32 using
+// Value.ToInt32.
+func (t *Tree) AtInt32(instanceID string, defaultVal ...int32) int32 {
+	return t.atOrNull(instanceID).ToInt32(defaultVal...)
+}
+
+// AtUint32 is like AtString but returns the value as a uint32 using
+// Value.ToUint32.
+func (t *Tree) AtUint32(instanceID string, defaultVal ...uint32) uint32 {
+	return t.atOrNull(instanceID).ToUint32(defaultVal...)
+}
+
+// AtInt64 is like AtString but returns the value as an int64 using
+// Value.ToInt64.
+func (t *Tree) AtInt64(instanceID string, defaultVal ...int64) int64 {
+	return t.atOrNull(instanceID).ToInt64(defaultVal...)
+}
+
+// AtUint64 is like AtString but returns the value as a uint64 using
+// Value.ToUint64.
+func (t *Tree) AtUint64(instanceID string, defaultVal ...uint64) uint64 {
+	return t.atOrNull(instanceID).ToUint64(defaultVal...)
+}
+
+// AtFloat is like AtString but returns the value as a float64 using
+// Value.ToFloat.
+func (t *Tree) AtFloat(instanceID string, defaultVal ...float64) float64 {
+	return t.atOrNull(instanceID).ToFloat(defaultVal...)
+}
+
+// AtBoolean is like AtString but returns the value as a bool using
+// Value.ToBoolean.
+func (t *Tree) AtBoolean(instanceID string, defaultVal ...bool) bool {
+	return t.atOrNull(instanceID).ToBoolean(defaultVal...)
+}
+
+// Subtree returns a new Tree rooted at the Object found at the
+// instance-identifier and whether such an Object was found. The
+// returned tree is independent of the original, edits to it do not
+// affect the original tree.
+func (t *Tree) Subtree(instanceID string) (*Tree, bool) {
+	v, found := t.find(InstanceIDNew(instanceID))
+	if !found || v == nil || !v.IsObject() {
+		return nil, false
+	}
+	return TreeFromObject(v.AsObject()), true
+}
+
+// Select returns the entries of the list or leaf-list at instanceID for
+// which pred returns true, in the order they appear in the array. This
+// allows entries to be matched by conditions richer than the equality
+// supported by instance-identifier predicates, such as numeric
+// comparison. Select returns nil if there is no list or leaf-list at
+// instanceID.
+func (t *Tree) Select(instanceID string, pred func(*Value) bool) []*Value {
+	v, found := t.Find(instanceID)
+	if !found || v == nil || !v.IsArray() {
+		return nil
+	}
+	var out []*Value
+	v.AsArray().selectItems(pred).Range(func(entry *Value) {
+		out = append(out, entry)
+	})
+	return out
+}
+
 // Assoc associates the value provided at the location pointed to
 // by the instance-identifier.
 func (t *Tree) Assoc(instanceID string, value interface{}) *Tree {
 	return t.assoc(InstanceIDNew(instanceID), ValueNew(value))
 }
 
+// AssocIn associates the value provided at the location pointed to by
+// the pre-parsed instance-identifier. It is equivalent to Assoc but
+// avoids parsing the instance-identifier on each call, which dominates
+// the cost of building large trees in a loop. Build the
+// instance-identifier once with InstanceIDNew and reuse it;
+// instance-identifiers are immutable so this is safe.
+func (t *Tree) AssocIn(id *InstanceID, value interface{}) *Tree {
+	return t.assoc(id, ValueNew(value))
+}
+
+// Update replaces the value at the instance-identifier with the result
+// of applying fn to it, fn is passed nil if nothing is there. If fn
+// returns nil the location is deleted, otherwise it is associated with
+// the result as Assoc would.
+func (t *Tree) Update(instanceID string, fn func(*Value) *Value) *Tree {
+	id := InstanceIDNew(instanceID)
+	new := fn(t.at(id))
+	if new == nil {
+		return t.delete(id)
+	}
+	return t.assoc(id, new)
+}
+
 func (t *Tree) assoc(i *InstanceID, v *Value) *Tree {
+	i.mustBeAbsolute()
 	type valueSelector struct {
 		value    *Value
 		selector instanceIDSelector
@@ -127,6 +372,31 @@ func (t *Tree) Delete(instanceID string) *Tree {
 	return t.delete(InstanceIDNew(instanceID))
 }
 
+// DeleteAll removes every list or leaf-list entry matched by the
+// predicates of the instance-identifier's final node, for instance all
+// entries matched by a wildcard or by a key value that isn't unique.
+// Only the final node may match more than one entry. If the final node
+// has no predicates, or they match a single entry, DeleteAll is
+// equivalent to Delete. The tree is returned unchanged if nothing
+// matches.
+func (t *Tree) DeleteAll(instanceID string) *Tree {
+	i := InstanceIDNew(instanceID)
+	preds, hasPredicates := i.selector().(*predicates)
+	if !hasPredicates {
+		return t.delete(i)
+	}
+	path := i.path()
+	v := path.MatchAgainst(t.Root())
+	if v == nil || !v.IsArray() {
+		return t
+	}
+	matched := preds.matchIndices(v)
+	if len(matched) == 0 {
+		return t
+	}
+	return t.assoc(path, ValueNew(v.AsArray().Without(matched...)))
+}
+
 func (t *Tree) delete(i *InstanceID) *Tree {
 	_, found := i.Find(t.Root())
 	if !found {
@@ -167,6 +437,46 @@ func (t *Tree) Length() int {
 	return count
 }
 
+// NodeCount returns the number of nodes in the tree, counting interior
+// objects and arrays as well as leaves but not the root. It counts the
+// same nodes that Length does without building an instance-identifier
+// for each, making it cheap enough to check untrusted data with.
+func (t *Tree) NodeCount() int {
+	count, _ := valueMetrics(t.Root())
+	return count - 1
+}
+
+// Depth returns the depth of the most deeply nested node in the tree.
+// The members of the root object are at depth 1 and each object or
+// array adds a level, an empty tree has a depth of 0.
+func (t *Tree) Depth() int {
+	_, depth := valueMetrics(t.Root())
+	return depth - 1
+}
+
+// valueMetrics returns the number of nodes in v, including v itself,
+// and the depth of its most deeply nested node with v at depth 1.
+func valueMetrics(v *Value) (count, depth int) {
+	visit := func(child *Value) {
+		c, d := valueMetrics(child)
+		count += c
+		if d > depth {
+			depth = d
+		}
+	}
+	switch d := v.data.(type) {
+	case *Object:
+		d.Range(func(_ string, child *Value) {
+			visit(child)
+		})
+	case *Array:
+		d.Range(func(_ int, child *Value) {
+			visit(child)
+		})
+	}
+	return count + 1, depth + 1
+}
+
 // Range iterates over the Trees's paths. Range can take a set of functions
 // matched by type. If the function returns a bool this is treated as a
 // loop terminataion variable if false the loop will terminate.
@@ -183,42 +493,281 @@ func (t *Tree) Length() int {
 //     func(*Value) iterates over only the values
 //     func(*Value) bool
 func (t *Tree) Range(fn interface{}) *Tree {
-	iid := &InstanceID{}
 	rangeFn := genTreeRangeFunc(fn)
-	var recur func(*InstanceID, *Value) bool
-	recur = func(iid *InstanceID, elem *Value) bool {
+	t.rangeDepth(0, func(_ int, iid *InstanceID, v *Value) bool {
+		return rangeFn(iid, v)
+	})
+	return t
+}
+
+// RangeContext iterates over the Tree's paths like Range, checking ctx
+// before visiting each node. If ctx is cancelled the iteration stops
+// and ctx.Err() is returned, otherwise RangeContext returns nil once
+// every node has been visited or fn has terminated the loop.
+// RangeContext accepts the same functions as Range.
+func (t *Tree) RangeContext(ctx context.Context, fn interface{}) error {
+	rangeFn := genTreeRangeFunc(fn)
+	var err error
+	t.walk(func(_ int, iid *InstanceID, v *Value) WalkAction {
+		if err = ctx.Err(); err != nil {
+			return WalkStop
+		}
+		if !rangeFn(iid, v) {
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	return err
+}
+
+// Validate runs each of the checks against every node of the Tree, as
+// visited by Range, and returns all of the errors they report rather
+// than stopping at the first. This allows lightweight invariants, such
+// as the presence of a list's key leaf or the range of a leaf's value,
+// to be verified without a schema. The errors are returned in the order
+// the nodes are visited, which like Range is unspecified. An empty
+// slice is returned if every check passes.
+func (t *Tree) Validate(checks ...func(*InstanceID, *Value) error) []error {
+	errs := make([]error, 0)
+	t.Range(func(path *InstanceID, v *Value) {
+		for _, check := range checks {
+			if err := check(path, v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errs
+}
+
+// Paths returns the instance-identifiers of every leaf in the Tree and
+// of every entry of its lists and leaf-lists, sorted lexically.
+// Containers and the lists themselves are not included.
+func (t *Tree) Paths() []string {
+	var out []string
+	t.Range(func(path *InstanceID, v *Value) {
+		_, isEntry := path.position()
+		if isEntry || v.IsScalar() {
+			out = append(out, path.String())
+		}
+	})
+	sort.Strings(out)
+	return out
+}
+
+// Flatten collapses the Tree into a map from the instance-identifier of
+// each leaf and leaf-list entry to its native value as returned by
+// ToNative. List entries are identified positionally. Empty leaves map
+// to their native form, []interface{}{nil}. Empty containers and lists
+// have no leaves and so do not appear in the result.
+func (t *Tree) Flatten() map[string]interface{} {
+	out := make(map[string]interface{})
+	t.Range(func(path *InstanceID, v *Value) {
+		if v.IsScalar() {
+			out[path.String()] = v.ToNative()
+		}
+	})
+	return out
+}
+
+// RangeMaxDepth iterates over the Tree's paths like Range but does not
+// descend below max depth. Nodes at depth max are visited but their
+// children are not. The members of the root object are at depth 1.
+// RangeMaxDepth accepts the same functions as Range.
+func (t *Tree) RangeMaxDepth(max int, fn interface{}) *Tree {
+	if max < 1 {
+		return t
+	}
+	rangeFn := genTreeRangeFunc(fn)
+	t.rangeDepth(max, func(_ int, iid *InstanceID, v *Value) bool {
+		return rangeFn(iid, v)
+	})
+	return t
+}
+
+// RangeDepth iterates over the Tree's paths like Range, additionally
+// supplying the nesting depth of each node. The members of the root
+// object are at depth 1, the entries of an array are one level deeper
+// than the array itself. If the function returns false the loop will
+// terminate.
+func (t *Tree) RangeDepth(fn func(depth int, path *InstanceID, v *Value) bool) *Tree {
+	t.rangeDepth(0, fn)
+	return t
+}
+
+// rangeDepth walks the tree calling rangeFn for each node. If max is
+// greater than zero the children of nodes at depth max are not visited.
+func (t *Tree) rangeDepth(
+	max int,
+	rangeFn func(int, *InstanceID, *Value) bool,
+) {
+	t.walk(func(depth int, iid *InstanceID, v *Value) WalkAction {
+		switch {
+		case !rangeFn(depth, iid, v):
+			return WalkStop
+		case depth == max:
+			return WalkSkipChildren
+		default:
+			return WalkContinue
+		}
+	})
+}
+
+// WalkAction is returned by the function passed to Walk to control
+// how the walk proceeds after visiting a node.
+type WalkAction int
+
+const (
+	// WalkContinue continues the walk, descending into the
+	// current node if it is an object or array.
+	WalkContinue WalkAction = iota
+	// WalkSkipChildren continues the walk with the next sibling
+	// of the current node without visiting its children.
+	WalkSkipChildren
+	// WalkStop terminates the walk.
+	WalkStop
+)
+
+// Walk iterates over the Tree's paths in the same order as Range.
+// The WalkAction returned by fn determines whether the walk descends
+// into the current node, skips its children, or stops entirely.
+func (t *Tree) Walk(fn func(path *InstanceID, v *Value) WalkAction) *Tree {
+	t.walk(func(_ int, iid *InstanceID, v *Value) WalkAction {
+		return fn(iid, v)
+	})
+	return t
+}
+
+// walk visits each node of the tree calling walkFn, which is also
+// supplied the node's depth. The members of the root object are at
+// depth 1.
+func (t *Tree) walk(walkFn func(int, *InstanceID, *Value) WalkAction) {
+	iid := &InstanceID{}
+	var recur func(int, *InstanceID, *Value) bool
+	recur = func(depth int, iid *InstanceID, elem *Value) bool {
 		return elem.Perform(func(o *Object) bool {
-			var cont bool
-			cont = rangeFn(iid, ValueNew(o))
-			if !cont {
+			switch walkFn(depth, iid, ValueNew(o)) {
+			case WalkStop:
 				return false
+			case WalkSkipChildren:
+				return true
 			}
+			cont := true
 			o.Range(func(key string, v *Value) bool {
-				cont = recur(iid.push(key), v)
+				cont = recur(depth+1, iid.push(key), v)
 				return cont
 			})
 			return cont
 		}, func(a *Array) bool {
-			var cont bool
-			cont = rangeFn(iid, ValueNew(a))
-			if !cont {
+			switch walkFn(depth, iid, ValueNew(a)) {
+			case WalkStop:
 				return false
+			case WalkSkipChildren:
+				return true
 			}
+			cont := true
 			a.Range(func(i int, v *Value) bool {
-				cont = recur(iid.addPosPredicate(i), v)
+				cont = recur(depth+1, iid.addPosPredicate(i), v)
 				return cont
 			})
 			return cont
-
 		}, func(other *Value) bool {
-			return rangeFn(iid, other)
+			return walkFn(depth, iid, other) != WalkStop
 		}).(bool)
 	}
 	t.root.AsObject().
 		Range(func(key string, v *Value) bool {
-			return recur(iid.push(key), v)
+			return recur(1, iid.push(key), v)
 		})
-	return t
+}
+
+// Prune returns a new tree containing only the nodes for which keep
+// returns true, along with their ancestors. When a node is kept its
+// entire subtree is kept with it; keep is not consulted for its
+// descendants. Objects and arrays left with no surviving children are
+// dropped. Surviving array elements are compacted so their indices may
+// differ from those passed to keep, which are always the paths in the
+// original tree.
+func (t *Tree) Prune(keep func(path *InstanceID) bool) *Tree {
+	var prune func(*InstanceID, *Value) *Value
+	prune = func(iid *InstanceID, v *Value) *Value {
+		if keep(iid) {
+			return v
+		}
+		// Leaves match no function and are dropped.
+		out, _ := v.Perform(func(o *Object) *Value {
+			out := pruneObject(o, iid, prune)
+			if out.Length() == 0 {
+				return nil
+			}
+			return ValueNew(out)
+		}, func(a *Array) *Value {
+			out := a.Slice(0, 0).Transform(func(out *TArray) {
+				a.Range(func(i int, v *Value) {
+					nv := prune(iid.addPosPredicate(i), v)
+					if nv != nil {
+						out.Append(nv)
+					}
+				})
+			})
+			if out.Length() == 0 {
+				return nil
+			}
+			return ValueNew(out)
+		}).(*Value)
+		return out
+	}
+	return TreeFromObject(
+		pruneObject(t.Root().AsObject(), &InstanceID{}, prune))
+}
+
+// MapLeaves returns a copy of the tree in which every leaf is replaced
+// by the value fn returns for it, this is convenient for blanket
+// transformations such as redacting sensitive values. Objects and
+// arrays are kept, even if all their members are deleted, and fn is
+// only called for the leaves within them. If fn returns the leaf it was
+// passed the leaf is unchanged and if it returns nil the leaf is
+// deleted. Elements deleted from an array are removed and those after
+// them moved down, fn is always passed the paths in the original tree.
+func (t *Tree) MapLeaves(fn func(path *InstanceID, v *Value) *Value) *Tree {
+	var mapLeaves func(*InstanceID, *Value) *Value
+	mapLeaves = func(iid *InstanceID, v *Value) *Value {
+		return v.Perform(func(o *Object) *Value {
+			return ValueNew(pruneObject(o, iid, mapLeaves))
+		}, func(a *Array) *Value {
+			return ValueNew(a.Slice(0, 0).Transform(func(out *TArray) {
+				a.Range(func(i int, v *Value) {
+					nv := mapLeaves(iid.addPosPredicate(i), v)
+					if nv != nil {
+						out.Append(nv)
+					}
+				})
+			}))
+		}, func() *Value {
+			return fn(iid, v)
+		}).(*Value)
+	}
+	return TreeFromObject(
+		pruneObject(t.Root().AsObject(), &InstanceID{}, mapLeaves))
+}
+
+// pruneObject returns o with each member replaced by the result of
+// prune, members for which prune returns nil are removed.
+func pruneObject(
+	o *Object,
+	iid *InstanceID,
+	prune func(*InstanceID, *Value) *Value,
+) *Object {
+	return o.Transform(func(out *TObject) {
+		o.Range(func(key string, v *Value) {
+			nv := prune(iid.push(key), v)
+			switch {
+			case nv == nil:
+				out.Delete(key)
+			case nv != v:
+				out.Assoc(key, nv)
+			}
+		})
+	})
 }
 
 func genTreeRangeFunc(fn interface{}) func(iid *InstanceID, v *Value) bool {
@@ -273,11 +822,80 @@ func genTreeRangeFunc(fn interface{}) func(iid *InstanceID, v *Value) bool {
 
 // MarshalRFC7951 returns the Tree encoded as RFC7951 data.
 func (t *Tree) MarshalRFC7951() ([]byte, error) {
+	return appendRFC7951(nil, t.Root())
+}
+
+// AppendRFC7951 appends the Tree encoded as RFC7951 data to dst and
+// returns the extended slice, see Value.AppendRFC7951.
+func (t *Tree) AppendRFC7951(dst []byte) ([]byte, error) {
+	return appendRFC7951(dst, t.Root())
+}
+
+// MarshalCanonical returns the Tree encoded as RFC7951 data with the
+// members of every object emitted in lexical order of their module
+// qualified keys. Unlike MarshalRFC7951 the output is deterministic,
+// making it suitable for golden files and textual comparison.
+func (t *Tree) MarshalCanonical() ([]byte, error) {
+	return t.Root().AsObject().MarshalCanonical()
+}
+
+// WriteRFC7951 writes the Tree encoded as RFC7951 data to w. Unlike
+// MarshalRFC7951 the encoding is streamed to w as it is produced
+// instead of being accumulated in memory.
+func (t *Tree) WriteRFC7951(w io.Writer) error {
+	return t.Root().WriteRFC7951(w)
+}
+
+// MarshalRFC7951MaxDepth returns the Tree encoded as RFC7951 data
+// omitting everything below max depth. The members of the root object
+// are at depth 1. Objects and arrays at depth max are elided, each is
+// replaced in the output by the number of members it contains. Leaves
+// at depth max are encoded as usual. Values of max less than 1 are
+// treated as 1.
+//
+// The count is an ordinary JSON number, so in the output an elided
+// container can't be told apart from a numeric leaf. The result is
+// intended for display, use RangeMaxDepth where the kind of each node
+// matters.
+func (t *Tree) MarshalRFC7951MaxDepth(max int) ([]byte, error) {
+	if max < 1 {
+		max = 1
+	}
+	root := t.Root().AsObject()
+	elided := root.Transform(func(out *TObject) {
+		root.Range(func(key string, v *Value) {
+			out.Assoc(key, elideValue(v, 1, max))
+		})
+	})
 	var buf bytes.Buffer
-	err := t.Root().marshalRFC7951(&buf, "")
+	err := ValueNew(elided).marshalRFC7951(&buf, "")
 	return buf.Bytes(), err
 }
 
+func elideValue(v *Value, depth, max int) *Value {
+	return v.Perform(func(o *Object) *Value {
+		if depth >= max {
+			return ValueNew(o.Length())
+		}
+		return ValueNew(o.Transform(func(out *TObject) {
+			o.Range(func(key string, v *Value) {
+				out.Assoc(key, elideValue(v, depth+1, max))
+			})
+		}))
+	}, func(a *Array) *Value {
+		if depth >= max {
+			return ValueNew(a.Length())
+		}
+		return ValueNew(a.Transform(func(out *TArray) {
+			a.Range(func(i int, v *Value) {
+				out.Assoc(i, elideValue(v, depth+1, max))
+			})
+		}))
+	}, func() *Value {
+		return v
+	}).(*Value)
+}
+
 // UnmarshalRFC7951 fills out the Tree from the RFC7951 encoded
 // message. This can't be fully immutable, the caller has to ensure
 // the array isn't used until unmarshal is finished.
@@ -298,11 +916,32 @@ func (t *Tree) Equal(other interface{}) bool {
 	return equal(t.Root(), ot.Root())
 }
 
+// EqualExcept compares the tree with other like Equal but considers
+// the trees equal if they differ only at paths for which ignore returns
+// true. The trees are diffed and ignore is called with the path of each
+// difference; list and leaf-list entries are identified by position. A
+// member that exists in only one of the trees is compared as a whole,
+// so ignore is called with its path rather than the paths within it.
+func (t *Tree) EqualExcept(other *Tree, ignore func(*InstanceID) bool) bool {
+	for _, entry := range t.Diff(other).Actions {
+		if !ignore(entry.Path) {
+			return false
+		}
+	}
+	return true
+}
+
 // String returns a string representation of the tree.
 func (t *Tree) String() string {
 	return t.Root().String()
 }
 
+// StringIndent returns a multi-line representation of the tree, see
+// Object.StringIndent.
+func (t *Tree) StringIndent(prefix, indent string) string {
+	return t.Root().AsObject().StringIndent(prefix, indent)
+}
+
 // Diff compares two trees and returns the operations required to edit
 // the original to produce the other one.
 func (t *Tree) Diff(other *Tree) *EditOperation {
@@ -311,10 +950,274 @@ func (t *Tree) Diff(other *Tree) *EditOperation {
 	}
 }
 
+// DiffMerge compares two trees like Diff but, rather than an entry per
+// changed leaf, emits a single EditMerge for each changed container
+// holding the minimal sub-object of changes within it. Deletions and
+// changes that can't be expressed as a merge, such as replacing an
+// object with a leaf or shrinking an array, are emitted as separate
+// entries.
+func (t *Tree) DiffMerge(other *Tree) *EditOperation {
+	return &EditOperation{
+		Actions: t.Root().AsObject().
+			diffMerge(other.Root().AsObject(), &InstanceID{}),
+	}
+}
+
+// diffMergeValues returns the edits needed to change old to new when
+// the pair can't be represented in a merge patch.
+func diffMergeValues(old, new *Value, path *InstanceID) []EditEntry {
+	switch {
+	case old.IsObject() && new.IsObject():
+		patch, out := old.AsObject().mergePatch(new.AsObject(), path)
+		if patch != nil {
+			out = append([]EditEntry{{
+				Action: EditMerge,
+				Path:   path,
+				Value:  ValueNew(patch),
+			}}, out...)
+		}
+		return out
+	case old.IsArray() && new.IsArray():
+		return old.AsArray().diffMerge(new.AsArray(), path)
+	default:
+		return []EditEntry{{Action: EditAssoc, Path: path, Value: new}}
+	}
+}
+
 // Edit applies an EditOperation to the tree. This allows for capturing large
 // change sets as a piece of data than can be evaluated as tree operations
-// and applied to the tree.
+// and applied to the tree. Edit panics if an action can't be applied,
+// see EditChecked.
 func (t *Tree) Edit(edit *EditOperation) *Tree {
 	op := edit.eval()
 	return op(t)
 }
+
+// EditChecked applies an EditOperation to the tree like Edit but
+// returns an error, rather than panicking, if any action fails. In
+// particular a failed EditTest action causes the whole operation to
+// fail, leaving the tree unchanged; this allows compare-and-swap style
+// updates.
+func (t *Tree) EditChecked(edit *EditOperation) (*Tree, error) {
+	out, err := try.Apply(t.Edit, edit)
+	if err != nil {
+		return nil, err
+	}
+	return out.(*Tree), nil
+}
+
+// Coerce returns a new tree in which the value at each of the supplied
+// instance-identifiers is converted to the associated kind. This allows
+// values decoded without a schema, whose types were inferred from the
+// encoding, to be given the types schema aware code would use, so that
+// Equal comparisons between the two agree. Values are converted as if
+// created by ValueNew from the requested type, so a non-negative number
+// coerced to KindInt32 is stored as a uint32 just as ValueNew(int32(1))
+// is. Integers may be converted between kinds when in range for the
+// target kind, integers and numeric strings may become floats, and
+// numbers and booleans may be converted to and from strings. A value
+// that can't be converted, such as a negative number coerced to
+// KindUint32 or an object coerced to any other kind, is left unchanged,
+// as are instance-identifiers that aren't present in the tree.
+func (t *Tree) Coerce(kinds map[string]ValueKind) *Tree {
+	return t.Transform(func(out *TTree) {
+		for path, kind := range kinds {
+			v, found := t.Find(path)
+			if !found {
+				continue
+			}
+			new, ok := v.coerce(kind)
+			if ok && new != v {
+				out.Assoc(path, new)
+			}
+		}
+	})
+}
+
+// Transform executes the provided function against a mutable transient
+// tree. Edits made through the TTree are accumulated in place, reusing
+// the object and array transients, and the result is frozen into a new
+// Tree once fn returns. This is considerably cheaper than a long
+// sequence of calls to Assoc, each of which copies every node on the
+// path to the edited node.
+func (t *Tree) Transform(fn func(*TTree)) *Tree {
+	tt := &TTree{root: &ttNode{value: t.Root()}}
+	fn(tt)
+	return TreeFromObject(tt.root.persistent().AsObject())
+}
+
+// TTree is a transient tree that may be used to perform a batch of
+// edits on a tree in a fast mutable fashion. This can only be accessed
+// via the (*Tree).Transform method. Care should be taken not to share
+// this among threads as its values are mutable.
+type TTree struct {
+	root *ttNode
+}
+
+// Assoc associates the value provided at the location pointed to
+// by the instance-identifier.
+func (t *TTree) Assoc(instanceID string, value interface{}) *TTree {
+	return t.AssocIn(InstanceIDNew(instanceID), value)
+}
+
+// AssocIn associates the value provided at the location pointed to
+// by the pre-parsed instance-identifier, see Tree.AssocIn.
+func (t *TTree) AssocIn(id *InstanceID, value interface{}) *TTree {
+	t.assoc(id, ValueNew(value))
+	return t
+}
+
+// assoc mirrors Tree.assoc but descends the instance-identifier
+// editing each node on the path in place.
+func (t *TTree) assoc(i *InstanceID, v *Value) {
+	i.mustBeAbsolute()
+	ids := i.ancestry()
+	n := t.root
+	for depth, id := range ids {
+		sel := id.selector()
+		key := n.identifier(sel)
+		mm, isMatchModifier := sel.(matchModifier)
+		if depth == len(ids)-1 {
+			if isMatchModifier {
+				v = mm.modifyMatchCriteria(v)
+			}
+			n.set(key, v)
+			return
+		}
+		next := n.child(key)
+		if next.isNil() {
+			c := ids[depth+1].selector().(nodeCreator)
+			n.set(key, c.createNode())
+			next = n.child(key)
+		}
+		if isMatchModifier {
+			n.set(key, mm.modifyMatchCriteria(next.persistent()))
+			next = n.child(key)
+		}
+		n = next
+	}
+}
+
+// At returns the Value at the instance-idenfitifer provided.
+func (t *TTree) At(instanceID string) *Value {
+	return InstanceIDNew(instanceID).MatchAgainst(t.root.persistent())
+}
+
+// Delete removes the instance-identifier from the tree.
+func (t *TTree) Delete(instanceID string) *TTree {
+	tree := TreeFromObject(t.root.persistent().AsObject())
+	t.root = &ttNode{value: tree.Delete(instanceID).Root()}
+	return t
+}
+
+// Merge merges the new tree into the tree, see Tree.Merge.
+func (t *TTree) Merge(new *Tree) *TTree {
+	tree := TreeFromObject(t.root.persistent().AsObject())
+	t.root = &ttNode{value: tree.Merge(new).Root()}
+	return t
+}
+
+// ttNode is a node of a TTree. A node is either persistent, holding
+// its value, or, once edited, holds a transient object or array along
+// with the nodes of any of its children that are being edited. The
+// transient's entries for those children are stale until the node is
+// made persistent again.
+type ttNode struct {
+	value    *Value
+	obj      *TObject
+	arr      *TArray
+	children map[interface{}]*ttNode
+}
+
+func (n *ttNode) isNil() bool {
+	return n.value == nil && n.obj == nil && n.arr == nil
+}
+
+// identifier returns the key or index selected by sel within the node.
+// Only predicates need to inspect the node's contents to determine
+// this, node-identifiers always select the same key. A list being
+// edited is kept transient, only the entries being edited are frozen
+// so that the predicates see their current keys.
+func (n *ttNode) identifier(sel instanceIDSelector) interface{} {
+	switch sel := sel.(type) {
+	case *nodeID:
+		return sel.prefix + ":" + sel.identifier
+	case *predicates:
+		if n.arr != nil {
+			n.commit()
+			return sel.indexDefault(n.arr)
+		}
+	}
+	return sel.computeIdentifierDefault(n.persistent())
+}
+
+// commit freezes any children being edited, writing their values to
+// the node's transient, which remains editable.
+func (n *ttNode) commit() {
+	for key, child := range n.children {
+		if n.obj != nil {
+			n.obj.Assoc(key.(string), child.persistent())
+		} else {
+			n.arr.Assoc(key.(int), child.persistent())
+		}
+		delete(n.children, key)
+	}
+}
+
+// persistent freezes the node and any children being edited, returning
+// its value.
+func (n *ttNode) persistent() *Value {
+	n.commit()
+	switch {
+	case n.obj != nil:
+		n.value = ValueNew(n.obj.asPersistent())
+	case n.arr != nil:
+		n.value = ValueNew(n.arr.asPersistent())
+	}
+	n.obj, n.arr, n.children = nil, nil, nil
+	return n.value
+}
+
+// edit makes the node transient so it may be modified.
+func (n *ttNode) edit() {
+	if n.obj != nil || n.arr != nil {
+		return
+	}
+	n.value.Perform(func(o *Object) {
+		n.obj = o.asTransient()
+	}, func(a *Array) {
+		n.arr = a.asTransient()
+	}, func() {
+		panic(fmt.Errorf("can't edit the children of %v", n.value))
+	})
+	n.children = make(map[interface{}]*ttNode)
+}
+
+// child returns the node for the key or index, which is nil if no such
+// child exists.
+func (n *ttNode) child(key interface{}) *ttNode {
+	n.edit()
+	if child, found := n.children[key]; found {
+		return child
+	}
+	child := &ttNode{}
+	if n.obj != nil {
+		child.value = n.obj.At(key.(string))
+	} else {
+		child.value = n.arr.At(key.(int))
+	}
+	n.children[key] = child
+	return child
+}
+
+// set associates the value with the key or index, replacing any child
+// being edited.
+func (n *ttNode) set(key interface{}, v *Value) {
+	n.edit()
+	delete(n.children, key)
+	if n.obj != nil {
+		n.obj.Assoc(key.(string), v)
+	} else {
+		n.arr.Assoc(key.(int), v)
+	}
+}
@@ -6,6 +6,16 @@
 package data
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/danos/encoding/rfc7951"
@@ -144,6 +154,34 @@ func TestTreeMerge(t *testing.T) {
 	}
 }
 
+func TestTreeMergeObject(t *testing.T) {
+	tree := TreeNew().Assoc("/module-v1:foo/bar", "baz")
+	obj := ObjectFrom(map[string]interface{}{
+		"module-v1:foo": map[string]interface{}{
+			"quux": "quuz",
+		},
+	})
+	expected := tree.Assoc("/module-v1:foo/quux", "quuz")
+	t.Run("object", func(t *testing.T) {
+		got := tree.MergeObject(obj)
+		if !equal(got, expected) {
+			t.Fatalf("got:\n\t%s\nexpected:\n\t%s\n", got, expected)
+		}
+	})
+	t.Run("value", func(t *testing.T) {
+		got := tree.MergeValue(ValueNew(obj))
+		if !equal(got, expected) {
+			t.Fatalf("got:\n\t%s\nexpected:\n\t%s\n", got, expected)
+		}
+	})
+	t.Run("non-object value", func(t *testing.T) {
+		got := tree.MergeValue(ValueNew("foo"))
+		if !equal(got, tree) {
+			t.Fatalf("got:\n\t%s\nexpected:\n\t%s\n", got, tree)
+		}
+	})
+}
+
 func TestTreeAssoc(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -244,6 +282,79 @@ func TestTreeAssoc(t *testing.T) {
 	}
 }
 
+func TestTreeAssocIn(t *testing.T) {
+	id := InstanceIDNew("/module-v1:list[key='foo']/objleaf")
+	tree := TreeNew()
+	for i := 0; i < 3; i++ {
+		tree = tree.AssocIn(id, i)
+	}
+	expected := TreeNew().Assoc("/module-v1:list[key='foo']/objleaf", 2)
+	if !equal(expected, tree) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, tree)
+	}
+	if !equal(tree.GetIn(id), ValueNew(2)) {
+		t.Fatalf("expected: 2\ngot: %s\n", tree.GetIn(id))
+	}
+	if id.String() != "/module-v1:list[key='foo']/objleaf" {
+		t.Fatalf("instance-identifier was modified: %s", id)
+	}
+	t.Run("object", func(t *testing.T) {
+		obj := ObjectNew().AssocIn(id, "bar")
+		if !equal(expected.Assoc(id.String(), "bar").Root().AsObject(),
+			obj) {
+			t.Fatalf("expected: %s\ngot: %s\n",
+				expected.Assoc(id.String(), "bar"), obj)
+		}
+		if !equal(obj.GetIn(id), ValueNew("bar")) {
+			t.Fatalf("expected: bar\ngot: %s\n", obj.GetIn(id))
+		}
+		if obj.GetIn(InstanceIDNew("/module-v1:missing")) != nil {
+			t.Fatal("expected nil for missing path")
+		}
+	})
+}
+
+func TestTreeUpdate(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:list[key='foo']/count", 1).
+		Assoc("/module-v1:leaf-list", ArrayWith("a", "b"))
+	incr := func(v *Value) *Value {
+		if v == nil {
+			return ValueNew(0)
+		}
+		return ValueNew(v.AsUint32() + 1)
+	}
+	cases := []struct {
+		name     string
+		path     string
+		fn       func(*Value) *Value
+		expected *Tree
+	}{
+		{"existing", "/module-v1:list[key='foo']/count", incr,
+			tree.Assoc("/module-v1:list[key='foo']/count", 2)},
+		{"absent", "/module-v1:list[key='bar']/count", incr,
+			tree.Assoc("/module-v1:list[key='bar']/count", 0)},
+		{"array", "/module-v1:leaf-list", func(v *Value) *Value {
+			return ValueNew(v.AsArray().Append("c"))
+		}, tree.Assoc("/module-v1:leaf-list", ArrayWith("a", "b", "c"))},
+		{"delete", "/module-v1:leaf-list[0]", func(*Value) *Value {
+			return nil
+		}, tree.Delete("/module-v1:leaf-list[0]")},
+		{"delete absent", "/module-v1:missing", func(*Value) *Value {
+			return nil
+		}, tree},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := tree.Update(test.path, test.fn)
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, got)
+			}
+		})
+	}
+}
+
 func TestTreeDelete(t *testing.T) {
 	cases := []struct {
 		name string
@@ -369,6 +480,77 @@ func TestTreeDelete(t *testing.T) {
 	})
 }
 
+func TestTreeDeleteAll(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:leaf-list", ArrayWith("a", "b", "a", "c")).
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("name", "a"), PairNew("type", "x")),
+			ObjectWith(PairNew("name", "b"), PairNew("type", "y")),
+			ObjectWith(PairNew("name", "c"), PairNew("type", "x")),
+			ObjectWith(PairNew("name", "d"))))
+	names := func(tree *Tree) []string {
+		var out []string
+		tree.At("/module-v1:list").AsArray().Range(func(v *Value) {
+			out = append(out, v.AsObject().At("name").ToString())
+		})
+		return out
+	}
+	t.Run("repeated key value", func(t *testing.T) {
+		got := tree.DeleteAll("/module-v1:list[type='x']")
+		expected := []string{"b", "d"}
+		if !reflect.DeepEqual(names(got), expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, names(got))
+		}
+	})
+	t.Run("wildcard", func(t *testing.T) {
+		got := tree.DeleteAll("/module-v1:list[type=*]")
+		expected := []string{"d"}
+		if !reflect.DeepEqual(names(got), expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, names(got))
+		}
+	})
+	t.Run("multiple predicates", func(t *testing.T) {
+		got := tree.DeleteAll("/module-v1:list[type='x'][name='c']")
+		expected := []string{"a", "b", "d"}
+		if !reflect.DeepEqual(names(got), expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, names(got))
+		}
+	})
+	t.Run("leaf-list", func(t *testing.T) {
+		got := tree.DeleteAll("/module-v1:leaf-list[.='a']")
+		expected := tree.Assoc("/module-v1:leaf-list", ArrayWith("b", "c"))
+		if !got.Equal(expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("single node", func(t *testing.T) {
+		for _, path := range []string{
+			"/module-v1:leaf",
+			"/module-v1:list[name='b']",
+			"/module-v1:list[1]",
+		} {
+			got, expected := tree.DeleteAll(path), tree.Delete(path)
+			if !got.Equal(expected) {
+				t.Fatalf("%s\nexpected: %s\ngot: %s\n",
+					path, expected, got)
+			}
+		}
+	})
+	t.Run("no match", func(t *testing.T) {
+		for _, path := range []string{
+			"/module-v1:list[type='z']",
+			"/module-v1:missing[name='a']",
+			"/module-v1:leaf[.='foo']",
+		} {
+			if got := tree.DeleteAll(path); got != tree {
+				t.Fatalf("%s: expected the tree unchanged, got: %s",
+					path, got)
+			}
+		}
+	})
+}
+
 func matchEditEntry(in EditEntry, entries []EditEntry) bool {
 	for _, entry := range entries {
 		if entry.Action == in.Action &&
@@ -457,6 +639,62 @@ func TestTreeDiff(t *testing.T) {
 	})
 }
 
+func TestTreeDiffMoves(t *testing.T) {
+	old := TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith("a", "b", "c", "d"))
+	t.Run("rotation is a single move", func(t *testing.T) {
+		new := old.Assoc("/module-v1:leaf-list",
+			ArrayWith("d", "a", "b", "c"))
+		diff := old.Diff(new)
+		if len(diff.Actions) != 1 ||
+			diff.Actions[0].Action != EditMove {
+			t.Fatalf("expected a single move, got: %s", diff)
+		}
+		if got := old.Edit(diff); !equal(got, new) {
+			t.Fatalf("expected: %s\ngot: %s\n", new, got)
+		}
+	})
+	t.Run("unchanged order has no moves", func(t *testing.T) {
+		new := old.Assoc("/module-v1:leaf-list",
+			ArrayWith("a", "x", "c", "d", "e"))
+		for _, action := range old.Diff(new).Actions {
+			if action.Action == EditMove {
+				t.Fatalf("unexpected move: %s", old.Diff(new))
+			}
+		}
+	})
+	t.Run("random", func(t *testing.T) {
+		rnd := rand.New(rand.NewSource(1))
+		randArray := func() *Array {
+			out := ArrayNew()
+			for i, n := 0, rnd.Intn(8); i < n; i++ {
+				switch rnd.Intn(3) {
+				case 0:
+					out = out.Append(ObjectWith(
+						PairNew("key", strconv.Itoa(rnd.Intn(4))),
+						PairNew("leaf", rnd.Intn(2))))
+				default:
+					out = out.Append(rnd.Intn(5))
+				}
+			}
+			return out
+		}
+		for i := 0; i < 500; i++ {
+			before := TreeNew().Assoc("/module-v1:list", randArray())
+			after := TreeNew().Assoc("/module-v1:list", randArray())
+			diff := before.Diff(after)
+			got, err := before.EditChecked(diff)
+			if err != nil {
+				t.Fatalf("%s -> %s\n%s: %s", before, after, diff, err)
+			}
+			if !equal(got, after) {
+				t.Fatalf("%s -> %s\n%s\nexpected: %s\ngot: %s\n",
+					before, after, diff, after, got)
+			}
+		}
+	})
+}
+
 func TestTreeEdit(t *testing.T) {
 	tree := TreeFromObject(TESTOBJ)
 	cases := []struct {
@@ -512,6 +750,174 @@ func TestTreeEdit(t *testing.T) {
 	}
 }
 
+func TestTreeMarshalCanonical(t *testing.T) {
+	one := TreeNew().
+		Assoc("/module-v1:c/z", "1").
+		Assoc("/module-v1:c/a", "2").
+		Assoc("/module-v1:c/module-v2:m", "3").
+		Assoc("/module-v1:b", ArrayWith(
+			ObjectWith(PairNew("y", 1), PairNew("x", 2)))).
+		Assoc("/module-v1:a", true)
+	two := TreeNew().
+		Assoc("/module-v1:a", true).
+		Assoc("/module-v1:b", ArrayWith(
+			ObjectWith(PairNew("x", 2), PairNew("y", 1)))).
+		Assoc("/module-v1:c/module-v2:m", "3").
+		Assoc("/module-v1:c/a", "2").
+		Assoc("/module-v1:c/z", "1")
+	expected := `{"module-v1:a":true,"module-v1:b":[{"x":2,"y":1}],"module-v1:c":{"a":"2","z":"1","module-v2:m":"3"}}`
+	for _, tree := range []*Tree{one, two} {
+		got, err := tree.MarshalCanonical()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	}
+	t.Run("Object", func(t *testing.T) {
+		got, err := one.Root().AsObject().MarshalCanonical()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("round-trip", func(t *testing.T) {
+		tree := TreeFromObject(TESTOBJ)
+		data, err := tree.MarshalCanonical()
+		if err != nil {
+			t.Fatal(err)
+		}
+		new := TreeNew()
+		err = rfc7951.Unmarshal(data, new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tree.Equal(new) {
+			t.Fatalf("expected: %s\ngot: %s\n", tree, new)
+		}
+	})
+}
+
+type failingWriter struct {
+	remaining int
+	writes    int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.remaining {
+		n := w.remaining
+		w.remaining = 0
+		return n, errors.New("write failed")
+	}
+	w.remaining -= len(p)
+	return len(p), nil
+}
+
+func TestAppendRFC7951(t *testing.T) {
+	tree := TreeFromObject(TESTOBJ)
+	arr := TESTOBJ.At("module-v1:list").AsArray()
+	tests := []struct {
+		name   string
+		append func([]byte) ([]byte, error)
+		marsh  func() ([]byte, error)
+	}{
+		{"Tree", tree.AppendRFC7951, tree.MarshalRFC7951},
+		{"Object", TESTOBJ.AppendRFC7951, tree.MarshalRFC7951},
+		{"Array", arr.AppendRFC7951, ValueNew(arr).MarshalRFC7951},
+		{"Value", ValueNew("foo").AppendRFC7951, ValueNew("foo").MarshalRFC7951},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expected, err := test.marsh()
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := []byte("prefix:")
+			got, err := test.append(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "prefix:"+string(expected) {
+				t.Fatalf("expected: prefix:%s\ngot: %s\n", expected, got)
+			}
+			got, err = test.append(got[:0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+			}
+		})
+	}
+}
+
+func TestTreeWriteRFC7951(t *testing.T) {
+	tree := TreeFromObject(TESTOBJ)
+	expected, err := tree.MarshalRFC7951()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Tree", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := tree.WriteRFC7951(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != string(expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, buf.String())
+		}
+	})
+	t.Run("Object", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := TESTOBJ.WriteRFC7951(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != string(expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, buf.String())
+		}
+	})
+	t.Run("Array", func(t *testing.T) {
+		arr := TESTOBJ.At("module-v1:list").AsArray()
+		exp, err := ValueNew(arr).MarshalRFC7951()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		err = arr.WriteRFC7951(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != string(exp) {
+			t.Fatalf("expected: %s\ngot: %s\n", exp, buf.String())
+		}
+	})
+	t.Run("writer-error", func(t *testing.T) {
+		entries := make([]interface{}, 1000)
+		for i := range entries {
+			entries[i] = map[string]interface{}{
+				"key":  strconv.Itoa(i),
+				"leaf": strings.Repeat("x", 64),
+			}
+		}
+		large := TreeFromObject(ObjectWith(
+			PairNew("module-v1:list", ArrayFrom(entries))))
+		w := &failingWriter{remaining: 10}
+		err := large.WriteRFC7951(w)
+		if err == nil || err.Error() != "write failed" {
+			t.Fatalf("expected write error, got %v", err)
+		}
+		if w.writes != 1 {
+			t.Fatalf("expected encoding to stop after failed write, got %d writes",
+				w.writes)
+		}
+	})
+}
+
 func TestTreeMarshalUnmarshal(t *testing.T) {
 	tree := TreeFromObject(TESTOBJ)
 	d, err := rfc7951.Marshal(tree)
@@ -529,6 +935,29 @@ func TestTreeMarshalUnmarshal(t *testing.T) {
 	}
 }
 
+func TestTreeMarshalUnsupportedValue(t *testing.T) {
+	invalid := &Value{data: complex(1, 2)}
+	cases := []struct {
+		name string
+		tree *Tree
+	}{
+		{"object", TreeNew().Assoc("/module-v1:foo/bar", invalid)},
+		{"array", TreeNew().Assoc("/module-v1:foo",
+			ArrayWith(1, invalid, 3))},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.tree.MarshalRFC7951()
+			if err == nil {
+				t.Fatal("expected an error for unsupported value")
+			}
+			if !strings.Contains(err.Error(), "complex128") {
+				t.Fatalf("error should name the type: %s", err)
+			}
+		})
+	}
+}
+
 func TestTreeMarshalEmpty(t *testing.T) {
 	tree := TreeFromObject(TESTOBJ)
 	d, err := rfc7951.Marshal(tree)
@@ -546,6 +975,32 @@ func TestTreeMarshalEmpty(t *testing.T) {
 	}
 }
 
+func TestTreeDetach(t *testing.T) {
+	tree := TreeNew()
+	err := rfc7951.Unmarshal(
+		[]byte(`{"module-v1:foo":{"a":"shared","b":"shared"}}`), tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := tree.At("/module-v1:foo/a"), tree.At("/module-v1:foo/b")
+	if a != b {
+		t.Fatal("expected unmarshalled values to be interned")
+	}
+	detached := tree.Detach()
+	if !equal(tree, detached) {
+		t.Fatalf("got:\n\t%s\nexpected:\n\t%s\n", detached, tree)
+	}
+	da, db := detached.At("/module-v1:foo/a"),
+		detached.At("/module-v1:foo/b")
+	if da == a || db == b || da == db {
+		t.Fatal("detached tree shares values with the original")
+	}
+	if detached.Root().AsObject().At("module-v1:foo").AsObject().module !=
+		"module-v1" {
+		t.Fatal("detached tree lost module information")
+	}
+}
+
 func TestTreeLength(t *testing.T) {
 	tree := TreeFromObject(TESTOBJ)
 	if tree.Length() != 102 {
@@ -553,6 +1008,50 @@ func TestTreeLength(t *testing.T) {
 	}
 }
 
+func TestTreeMetrics(t *testing.T) {
+	cases := []struct {
+		name  string
+		tree  *Tree
+		count int
+		depth int
+	}{
+		{"empty", TreeNew(), 0, 0},
+		{"leaf", TreeNew().Assoc("/module-v1:leaf", 1), 1, 1},
+		{"nested", TreeNew().
+			Assoc("/module-v1:container/list[key='a']/leaf", 1).
+			Assoc("/module-v1:container/leaf-list", ArrayWith(1, 2, 3)).
+			Assoc("/module-v1:other", Empty()), 10, 4},
+		{"empty containers", TreeNew().
+			Assoc("/module-v1:container/inner", ObjectNew()).
+			Assoc("/module-v1:list", ArrayNew()), 3, 2},
+		{"representative", TreeFromObject(TESTOBJ), 102, 5},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.tree.NodeCount(); got != test.count {
+				t.Fatalf("expected: %d\ngot: %d\n", test.count, got)
+			}
+			if got := test.tree.NodeCount(); got != test.tree.Length() {
+				t.Fatalf("expected: %d\ngot: %d\n",
+					test.tree.Length(), got)
+			}
+			if got := test.tree.Depth(); got != test.depth {
+				t.Fatalf("expected: %d\ngot: %d\n", test.depth, got)
+			}
+			var depth int
+			test.tree.rangeDepth(-1, func(d int, _ *InstanceID, _ *Value) bool {
+				if d > depth {
+					depth = d
+				}
+				return true
+			})
+			if depth != test.depth {
+				t.Fatalf("expected: %d\ngot: %d\n", depth, test.depth)
+			}
+		})
+	}
+}
+
 func TestTreeEqual(t *testing.T) {
 	tree := TreeFromObject(TESTOBJ)
 	t.Run("tree == tree", func(t *testing.T) {
@@ -614,6 +1113,88 @@ func TestTreeFind(t *testing.T) {
 	})
 }
 
+func TestTreeAtTyped(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:container/name", "foo").
+		Assoc("/module-v1:container/mtu", 1500).
+		Assoc("/module-v1:container/offset", -10).
+		Assoc("/module-v1:container/big", uint64(1)<<40).
+		Assoc("/module-v1:container/ratio", 0.5).
+		Assoc("/module-v1:container/enabled", true).
+		Assoc("/module-v1:container/present", Empty()).
+		Assoc("/module-v1:container/null", nil)
+	cases := []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+	}{
+		{"string", tree.AtString("/module-v1:container/name"), "foo"},
+		{"string default", tree.AtString("/module-v1:missing", "bar"), "bar"},
+		{"string wrong type", tree.AtString("/module-v1:container/mtu"), ""},
+		{"int32", tree.AtInt32("/module-v1:container/offset"), int32(-10)},
+		{"int32 missing", tree.AtInt32("/module-v1:missing"), int32(0)},
+		{"uint32", tree.AtUint32("/module-v1:container/mtu"), uint32(1500)},
+		{"uint32 default", tree.AtUint32("/module-v1:container/name", 9000),
+			uint32(9000)},
+		{"int64", tree.AtInt64("/module-v1:container/mtu"), int64(1500)},
+		{"int64 null", tree.AtInt64("/module-v1:container/null", 7),
+			int64(7)},
+		{"uint64", tree.AtUint64("/module-v1:container/big"),
+			uint64(1) << 40},
+		{"uint64 default", tree.AtUint64("/module-v1:missing", 1),
+			uint64(1)},
+		{"float", tree.AtFloat("/module-v1:container/ratio"), 0.5},
+		{"float default", tree.AtFloat("/module-v1:missing", 1.5), 1.5},
+		{"boolean", tree.AtBoolean("/module-v1:container/enabled"), true},
+		{"boolean empty", tree.AtBoolean("/module-v1:container/present"),
+			true},
+		{"boolean missing", tree.AtBoolean("/module-v1:missing"), false},
+		{"boolean default", tree.AtBoolean("/module-v1:missing", true),
+			true},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			if test.got != test.expected {
+				t.Fatalf("expected: %v (%T)\ngot: %v (%T)\n",
+					test.expected, test.expected, test.got, test.got)
+			}
+		})
+	}
+}
+
+func TestTreeSubtree(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:foo/bar/baz", "quux").
+		Assoc("/module-v1:foo/leaf", "foo")
+	t.Run("object", func(t *testing.T) {
+		sub, ok := tree.Subtree("/module-v1:foo/bar")
+		if !ok {
+			t.Fatal("didn't find expected subtree")
+		}
+		if got := sub.At("/module-v1:baz").ToString(); got != "quux" {
+			t.Fatalf("expected quux, got %s", got)
+		}
+		if sub.Root().AsObject().module != "module-v1" {
+			t.Fatal("subtree didn't preserve module")
+		}
+		edited := sub.Assoc("/module-v1:baz", "changed")
+		if edited.At("/module-v1:baz").ToString() != "changed" ||
+			tree.At("/module-v1:foo/bar/baz").ToString() != "quux" {
+			t.Fatal("subtree edits should not affect the original")
+		}
+	})
+	t.Run("non-object", func(t *testing.T) {
+		if _, ok := tree.Subtree("/module-v1:foo/leaf"); ok {
+			t.Fatal("leaf should not produce a subtree")
+		}
+	})
+	t.Run("missing", func(t *testing.T) {
+		if _, ok := tree.Subtree("/module-v1:missing"); ok {
+			t.Fatal("missing path should not produce a subtree")
+		}
+	})
+}
+
 func TestTreeRange(t *testing.T) {
 	tree := TreeFromObject(TESTOBJ)
 	rangeLeaves := map[string]interface{}{
@@ -858,3 +1439,1077 @@ func TestTreeRange(t *testing.T) {
 		}
 	})
 }
+
+func TestTreeRangeDepth(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:container/leaf", "bar").
+		Assoc("/module-v1:list[key='a']/leaf", "baz")
+	expected := map[string]int{
+		"/module-v1:leaf":           1,
+		"/module-v1:container":      1,
+		"/module-v1:container/leaf": 2,
+		"/module-v1:list":           1,
+		"/module-v1:list[0]":        2,
+		"/module-v1:list[0]/key":    3,
+		"/module-v1:list[0]/leaf":   3,
+	}
+	got := make(map[string]int)
+	tree.RangeDepth(func(depth int, path *InstanceID, v *Value) bool {
+		got[path.String()] = depth
+		return true
+	})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+	}
+	t.Run("terminate", func(t *testing.T) {
+		var count int
+		tree.RangeDepth(func(int, *InstanceID, *Value) bool {
+			count++
+			return false
+		})
+		if count != 1 {
+			t.Fatalf("expected range to terminate, got %d calls", count)
+		}
+	})
+}
+
+func TestTreeRangeContext(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:container/inner/leaf", "bar").
+		Assoc("/module-v1:list[key='a']/leaf", "baz")
+	t.Run("complete", func(t *testing.T) {
+		var count int
+		err := tree.RangeContext(context.Background(), func(string) {
+			count++
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 8 {
+			t.Fatalf("expected: 8 nodes\ngot: %d\n", count)
+		}
+	})
+	t.Run("terminate", func(t *testing.T) {
+		var count int
+		err := tree.RangeContext(context.Background(), func(string) bool {
+			count++
+			return false
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatalf("expected range to terminate, got %d calls", count)
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var visited []string
+		err := tree.RangeContext(ctx, func(path string) {
+			visited = append(visited, path)
+			if path == "/module-v1:container/inner" {
+				cancel()
+			}
+		})
+		if err != context.Canceled {
+			t.Fatalf("expected: %v\ngot: %v\n", context.Canceled, err)
+		}
+		last := visited[len(visited)-1]
+		if last != "/module-v1:container/inner" {
+			t.Fatalf("expected range to stop after cancel, got %v", visited)
+		}
+	})
+}
+
+func TestTreePaths(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:container/inner/leaf", "bar").
+		Assoc("/module-v1:container/empty", Empty()).
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 2)).
+		Assoc("/module-v1:list[key='a']/leaf", "baz")
+	expected := []string{
+		"/module-v1:container/empty",
+		"/module-v1:container/inner/leaf",
+		"/module-v1:leaf",
+		"/module-v1:leaf-list[0]",
+		"/module-v1:leaf-list[1]",
+		"/module-v1:list[0]",
+		"/module-v1:list[0]/key",
+		"/module-v1:list[0]/leaf",
+	}
+	got := tree.Paths()
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+	}
+	if len(TreeNew().Paths()) != 0 {
+		t.Fatal("empty tree should have no paths")
+	}
+}
+
+func TestTreeFlatten(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:container/inner/leaf", uint32(1)).
+		Assoc("/module-v1:container/empty", Empty()).
+		Assoc("/module-v1:container/none", ObjectNew()).
+		Assoc("/module-v1:leaf-list", ArrayWith("a", "b")).
+		Assoc("/module-v1:list[key='a']/leaf", true)
+	expected := map[string]interface{}{
+		"/module-v1:leaf":                 "foo",
+		"/module-v1:container/inner/leaf": uint32(1),
+		"/module-v1:container/empty":      []interface{}{nil},
+		"/module-v1:leaf-list[0]":         "a",
+		"/module-v1:leaf-list[1]":         "b",
+		"/module-v1:list[0]/key":          "a",
+		"/module-v1:list[0]/leaf":         true,
+	}
+	got := tree.Flatten()
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+	}
+	if len(TreeNew().Flatten()) != 0 {
+		t.Fatal("empty tree should flatten to an empty map")
+	}
+}
+
+func TestTreeFromFlat(t *testing.T) {
+	t.Run("round-trip", func(t *testing.T) {
+		tree := TreeNew().
+			Assoc("/module-v1:leaf", "foo").
+			Assoc("/module-v1:container/inner/leaf", uint32(1)).
+			Assoc("/module-v1:container/empty", Empty())
+		for i := 0; i < 12; i++ {
+			key := strconv.Itoa(i)
+			tree = tree.
+				Assoc("/module-v1:leaf-list["+key+"]", key).
+				Assoc("/module-v1:list[key='"+key+"']/leaf", i%2 == 0)
+		}
+		flat := tree.Flatten()
+		paths := make([]string, 0, len(flat))
+		for path := range flat {
+			paths = append(paths, path)
+		}
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 10; i++ {
+			rng.Shuffle(len(paths), func(i, j int) {
+				paths[i], paths[j] = paths[j], paths[i]
+			})
+			shuffled := make(map[string]interface{}, len(paths))
+			for _, path := range paths {
+				shuffled[path] = flat[path]
+			}
+			got, err := TreeFromFlat(shuffled)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tree) {
+				t.Fatalf("expected: %s\ngot: %s\n", tree, got)
+			}
+		}
+	})
+	t.Run("key predicates", func(t *testing.T) {
+		got, err := TreeFromFlat(map[string]interface{}{
+			"/module-v1:list[key='a']/leaf": "x",
+			"/module-v1:list[key='b']/leaf": "y",
+			"/module-v1:leaf-list[.='z']":   "z",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for path, expected := range map[string]string{
+			"/module-v1:list[key='a']/leaf": "x",
+			"/module-v1:list[key='b']/leaf": "y",
+			"/module-v1:leaf-list[0]":       "z",
+		} {
+			if v := got.At(path); v == nil || v.ToString() != expected {
+				t.Fatalf("expected %s at %s\ngot: %s\n", expected, path, got)
+			}
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		tests := []map[string]interface{}{
+			{"module-v1:leaf": "foo"},
+			{"/module-v1:list[key='a'": "foo"},
+			{"/leaf": "foo"},
+			{"/module-v1:leaf": struct{}{}},
+			{
+				"/module-v1:leaf":     "foo",
+				"/module-v1:leaf/bar": "baz",
+			},
+		}
+		for _, m := range tests {
+			if _, err := TreeFromFlat(m); err == nil {
+				t.Fatalf("expected error for %v", m)
+			}
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		got, err := TreeFromFlat(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(TreeNew()) {
+			t.Fatalf("expected empty tree, got: %s", got)
+		}
+	})
+}
+
+func TestTreeCoerce(t *testing.T) {
+	var tree Tree
+	err := rfc7951.Unmarshal([]byte(`{
+		"module-v1:i32": 5,
+		"module-v1:i64": 5,
+		"module-v1:u64": "18446744073709551615",
+		"module-v1:str": "12",
+		"module-v1:num": "-3",
+		"module-v1:dec": "1.5",
+		"module-v1:flt": 2,
+		"module-v1:bool": "true",
+		"module-v1:big": "123",
+		"module-v1:bad": -1,
+		"module-v1:wide": "4294967296",
+		"module-v1:obj": {"leaf": 1},
+		"module-v1:list": [{"key": "a", "val": 1}, {"key": "b", "val": 2}]
+	}`), &tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tree.Coerce(map[string]ValueKind{
+		"/module-v1:i32":                  KindInt32,
+		"/module-v1:i64":                  KindInt64,
+		"/module-v1:u64":                  KindUint64,
+		"/module-v1:str":                  KindString,
+		"/module-v1:num":                  KindInt32,
+		"/module-v1:dec":                  KindFloat,
+		"/module-v1:flt":                  KindFloat,
+		"/module-v1:bool":                 KindBool,
+		"/module-v1:big":                  KindBigInt,
+		"/module-v1:bad":                  KindUint32,
+		"/module-v1:wide":                 KindInt32,
+		"/module-v1:obj":                  KindString,
+		"/module-v1:list[key='b']/val":    KindString,
+		"/module-v1:missing":              KindString,
+		"/module-v1:list[key='c']/val":    KindString,
+		"/module-v1:obj/module-v1:absent": KindInt32,
+	})
+	expected := TreeNew().
+		Assoc("/module-v1:i32", int32(5)).
+		Assoc("/module-v1:i64", int64(5)).
+		Assoc("/module-v1:u64", uint64(18446744073709551615)).
+		Assoc("/module-v1:str", "12").
+		Assoc("/module-v1:num", int32(-3)).
+		Assoc("/module-v1:dec", 1.5).
+		Assoc("/module-v1:flt", 2.0).
+		Assoc("/module-v1:bool", true).
+		Assoc("/module-v1:big", big.NewInt(123)).
+		Assoc("/module-v1:bad", int32(-1)).
+		Assoc("/module-v1:wide", int64(4294967296)).
+		Assoc("/module-v1:obj/leaf", 1).
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("key", "a"), PairNew("val", 1)),
+			ObjectWith(PairNew("key", "b"), PairNew("val", "2"))))
+	if !got.Equal(expected) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	if tree.At("/module-v1:i64").IsUint64() {
+		t.Fatal("Coerce modified the original tree")
+	}
+}
+
+func TestTreeSelect(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf-list", ArrayWith(5, 1, 7, 3)).
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("key", "a"), PairNew("mtu", 1500)),
+			ObjectWith(PairNew("key", "b"), PairNew("mtu", 9000)),
+			ObjectWith(PairNew("key", "c"), PairNew("mtu", 1280)),
+			ObjectWith(PairNew("key", "d"), PairNew("mtu", 9216))))
+	t.Run("list", func(t *testing.T) {
+		got := tree.Select("/module-v1:list", func(v *Value) bool {
+			return v.AsObject().At("mtu").ToUint32() > 1500
+		})
+		var keys []string
+		for _, entry := range got {
+			keys = append(keys, entry.AsObject().At("key").ToString())
+		}
+		expected := []string{"b", "d"}
+		if !reflect.DeepEqual(keys, expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, keys)
+		}
+	})
+	t.Run("leaf-list", func(t *testing.T) {
+		got := tree.Select("/module-v1:leaf-list", func(v *Value) bool {
+			return v.ToUint32() >= 3
+		})
+		expected := []*Value{ValueNew(5), ValueNew(7), ValueNew(3)}
+		if len(got) != len(expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+		}
+		for i := range got {
+			if !got[i].Equal(expected[i]) {
+				t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+			}
+		}
+	})
+	t.Run("not an array", func(t *testing.T) {
+		all := func(*Value) bool { return true }
+		for _, path := range []string{
+			"/module-v1:missing",
+			"/module-v1:list[key='a']",
+		} {
+			if got := tree.Select(path, all); got != nil {
+				t.Fatalf("expected nil for %s, got: %v", path, got)
+			}
+		}
+	})
+}
+
+func TestTreeValidate(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:mtu", 9000).
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("name", "a"), PairNew("mtu", 1500)),
+			ObjectWith(PairNew("mtu", 20000)),
+			ObjectWith(PairNew("mtu", 1280))))
+	hasKey := func(path *InstanceID, v *Value) error {
+		if _, isEntry := path.position(); isEntry &&
+			!v.AsObject().Contains("name") {
+			return fmt.Errorf("%s: missing key name", path)
+		}
+		return nil
+	}
+	inRange := func(path *InstanceID, v *Value) error {
+		if path.Nodes()[len(path.Nodes())-1].Identifier != "mtu" {
+			return nil
+		}
+		if mtu := v.ToUint32(); mtu < 1280 || mtu > 9216 {
+			return fmt.Errorf("%s: mtu %d out of range", path, mtu)
+		}
+		return nil
+	}
+	got := tree.Validate(hasKey, inRange)
+	var msgs []string
+	for _, err := range got {
+		msgs = append(msgs, err.Error())
+	}
+	sort.Strings(msgs)
+	expected := []string{
+		"/module-v1:list[1]/mtu: mtu 20000 out of range",
+		"/module-v1:list[1]: missing key name",
+		"/module-v1:list[2]: missing key name",
+	}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, msgs)
+	}
+	clean := TreeNew().Assoc("/module-v1:mtu", 1500).Validate(hasKey, inRange)
+	if clean == nil || len(clean) != 0 {
+		t.Fatalf("expected an empty slice, got: %v", clean)
+	}
+}
+
+func TestTreeRangeMaxDepth(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:container/inner/leaf", "bar").
+		Assoc("/module-v1:list[key='a']/leaf", "baz")
+	got := make(map[string]struct{})
+	tree.RangeMaxDepth(2, func(path string) {
+		got[path] = struct{}{}
+	})
+	expected := map[string]struct{}{
+		"/module-v1:leaf":            {},
+		"/module-v1:container":       {},
+		"/module-v1:container/inner": {},
+		"/module-v1:list":            {},
+		"/module-v1:list[0]":         {},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+	}
+}
+
+func TestTreeWalk(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:container/inner/leaf", "bar").
+		Assoc("/module-v1:list[key='a']/leaf", "baz").
+		Assoc("/module-v1:list[key='b']/leaf", "quux")
+	t.Run("continue", func(t *testing.T) {
+		var walked, ranged []string
+		tree.Walk(func(path *InstanceID, v *Value) WalkAction {
+			walked = append(walked, path.String())
+			return WalkContinue
+		})
+		tree.Range(func(path string) {
+			ranged = append(ranged, path)
+		})
+		if !reflect.DeepEqual(walked, ranged) {
+			t.Fatalf("expected: %v\ngot: %v\n", ranged, walked)
+		}
+	})
+	t.Run("skip-children", func(t *testing.T) {
+		got := make(map[string]struct{})
+		tree.Walk(func(path *InstanceID, v *Value) WalkAction {
+			got[path.String()] = struct{}{}
+			switch path.String() {
+			case "/module-v1:container", "/module-v1:list[0]":
+				return WalkSkipChildren
+			}
+			return WalkContinue
+		})
+		expected := map[string]struct{}{
+			"/module-v1:leaf":         {},
+			"/module-v1:container":    {},
+			"/module-v1:list":         {},
+			"/module-v1:list[0]":      {},
+			"/module-v1:list[1]":      {},
+			"/module-v1:list[1]/key":  {},
+			"/module-v1:list[1]/leaf": {},
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+		}
+	})
+	t.Run("stop", func(t *testing.T) {
+		var count int
+		tree.Walk(func(path *InstanceID, v *Value) WalkAction {
+			count++
+			if path.String() == "/module-v1:list[0]" {
+				return WalkStop
+			}
+			return WalkContinue
+		})
+		var expected int
+		tree.Range(func(path string) bool {
+			expected++
+			return path != "/module-v1:list[0]"
+		})
+		if count != expected {
+			t.Fatalf("expected %d calls, got %d", expected, count)
+		}
+	})
+}
+
+func TestTreeMarshalRFC7951MaxDepth(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:leaf", "foo").
+		Assoc("/module-v1:container/inner/leaf", "bar").
+		Assoc("/module-v1:container/inner/other", "baz").
+		Assoc("/module-v1:container/leaf", "quux").
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 2, 3))
+	cases := []struct {
+		max      int
+		expected *Tree
+	}{
+		{
+			max: 1,
+			expected: TreeNew().
+				Assoc("/module-v1:leaf", "foo").
+				Assoc("/module-v1:container", 2).
+				Assoc("/module-v1:leaf-list", 3),
+		},
+		{
+			max: 2,
+			expected: TreeNew().
+				Assoc("/module-v1:leaf", "foo").
+				Assoc("/module-v1:container/inner", 2).
+				Assoc("/module-v1:container/leaf", "quux").
+				Assoc("/module-v1:leaf-list", ArrayWith(1, 2, 3)),
+		},
+		{
+			max:      3,
+			expected: tree,
+		},
+	}
+	for _, test := range cases {
+		t.Run(strconv.Itoa(test.max), func(t *testing.T) {
+			msg, err := tree.MarshalRFC7951MaxDepth(test.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := TreeNew()
+			err = rfc7951.Unmarshal(msg, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equal(got, test.expected) {
+				t.Fatalf("got:\n\t%s\nexpected:\n\t%s\n",
+					got, test.expected)
+			}
+		})
+	}
+	t.Run("null leaf", func(t *testing.T) {
+		var tree Tree
+		err := rfc7951.Unmarshal(
+			[]byte(`{"m:e":null,"m:c":{"n":null}}`), &tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := tree.MarshalRFC7951MaxDepth(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"m:c":{"n":null},"m:e":null}`
+		var got Tree
+		err = rfc7951.Unmarshal(msg, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(&got, &tree) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, msg)
+		}
+	})
+}
+
+func TestTreeDiffMerge(t *testing.T) {
+	tree := TreeFromObject(TESTOBJ)
+	cases := []struct {
+		name string
+		edit *EditOperation
+	}{
+		{
+			name: "sniff test",
+			edit: EditOperationNew(
+				EditEntryNew("delete",
+					"/module-v1:nested/list[key='foo']"),
+				EditEntryNew("delete",
+					"/module-v1:nested/container"),
+				EditEntryNew("assoc",
+					"/module-v1:new/othercontainer/leaf",
+					EditEntryValue("!!!")),
+				EditEntryNew("merge",
+					"/module-v1:container",
+					EditEntryValue(ObjectWith(
+						PairNew("containerleaf", "bar"),
+						PairNew("newleaf", "baz")))),
+			),
+		}, {
+			name: "shrink array",
+			edit: EditOperationNew(
+				EditEntryNew("delete", "/module-v1:leaf-list[6]"),
+				EditEntryNew("delete", "/module-v1:leaf-list[5]"),
+				EditEntryNew("delete", "/module-v1:leaf-list[1]"),
+			),
+		}, {
+			name: "grow array",
+			edit: EditOperationNew(
+				EditEntryNew("assoc", "/module-v1:leaf-list[7]",
+					EditEntryValue(8)),
+				EditEntryNew("assoc", "/module-v1:leaf-list[8]",
+					EditEntryValue(9)),
+			),
+		}, {
+			name: "object to leaf",
+			edit: EditOperationNew(
+				EditEntryNew("assoc", "/module-v1:nested/container",
+					EditEntryValue("!!!")),
+			),
+		}, {
+			name: "leaf to object",
+			edit: EditOperationNew(
+				EditEntryNew("assoc",
+					"/module-v1:container/containerleaf",
+					EditEntryValue(ObjectWith(
+						PairNew("foo", "!!!")))),
+			),
+		}, {
+			name: "leaf to array",
+			edit: EditOperationNew(
+				EditEntryNew("assoc",
+					"/module-v1:container/containerleaf",
+					EditEntryValue(ArrayWith(1, 2))),
+			),
+		}, {
+			name: "array to leaf",
+			edit: EditOperationNew(
+				EditEntryNew("assoc", "/module-v1:leaf-list",
+					EditEntryValue("!!!")),
+			),
+		}, {
+			name: "nested delete",
+			edit: EditOperationNew(
+				EditEntryNew("delete",
+					"/module-v1:nested/list[0]/objleaf"),
+				EditEntryNew("assoc",
+					"/module-v1:nested/list[1]/objleaf",
+					EditEntryValue("!!!")),
+			),
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			new := tree.Edit(test.edit)
+			diff := tree.DiffMerge(new)
+			edited := tree.Edit(diff)
+			if !equal(new, edited) {
+				t.Fatalf("When editing tree:\n\t%s\nwith:\n\t%s\ngot:\n\t%s\nexpected:\n\t%s\ndifferences were:\n\t%s",
+					tree.Root().AsObject(),
+					diff,
+					edited.Root().AsObject(),
+					new.Root().AsObject(),
+					new.Diff(edited))
+			}
+		})
+	}
+	t.Run("single leaf change is one merge", func(t *testing.T) {
+		new := tree.Assoc("/module-v1:container/containerleaf", "!!!")
+		diff := tree.DiffMerge(new)
+		if len(diff.Actions) != 1 {
+			t.Fatalf("expected one action, got: %s\n", diff)
+		}
+		action := diff.Actions[0]
+		expected := ObjectWith(PairNew("module-v1:containerleaf", "!!!"))
+		if action.Action != EditMerge ||
+			action.Path.String() != "/module-v1:container" ||
+			!equal(action.Value.AsObject(), expected) {
+			t.Fatalf("expected: merge of %s\ngot: %s\n", expected, diff)
+		}
+	})
+	t.Run("no changes", func(t *testing.T) {
+		diff := tree.DiffMerge(tree)
+		if len(diff.Actions) != 0 {
+			t.Fatalf("expected no actions, got: %s\n", diff)
+		}
+	})
+}
+
+func TestTreeStringIndent(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("key", "a")))).
+		Assoc("/module-v1:empty-array", ArrayNew()).
+		Assoc("/module-v1:empty-object", ObjectNew())
+	got := tree.Root().AsObject().
+		At("module-v1:list").AsArray().At(0).AsObject().
+		StringIndent(">", "  ")
+	expected := "{\n>  \"key\": \"a\"\n>}"
+	if got != expected {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	got = tree.StringIndent("", "\t")
+	for _, line := range []string{
+		"\t\"module-v1:list\": [\n\t\t{\n\t\t\t\"key\": \"a\"\n\t\t}\n\t]",
+		"\t\"module-v1:empty-array\": []",
+		"\t\"module-v1:empty-object\": {}",
+	} {
+		if !strings.Contains(got, line) {
+			t.Fatalf("expected %q in:\n%s\n", line, got)
+		}
+	}
+	if strings.Contains(tree.String(), "\n") {
+		t.Fatal("String should remain compact")
+	}
+}
+
+func TestTreePrune(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:interfaces/interface[name='eth0']/mtu", 1500).
+		Assoc("/module-v1:interfaces/interface[name='eth1']/mtu", 9000).
+		Assoc("/module-v1:interfaces/interface[name='eth1']/secret", "x").
+		Assoc("/module-v1:system/hostname", "host").
+		Assoc("/module-v1:system/users/user[name='root']/password", "y").
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 2, 3))
+	allow := func(prefixes ...string) func(*InstanceID) bool {
+		return func(path *InstanceID) bool {
+			for _, prefix := range prefixes {
+				if path.HasPrefix(InstanceIDNew(prefix)) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	cases := []struct {
+		name     string
+		keep     func(*InstanceID) bool
+		expected *Tree
+	}{
+		{
+			name:     "everything",
+			keep:     func(*InstanceID) bool { return true },
+			expected: tree,
+		},
+		{
+			name:     "nothing",
+			keep:     func(*InstanceID) bool { return false },
+			expected: TreeNew(),
+		},
+		{
+			name: "subtree",
+			keep: allow("/module-v1:system"),
+			expected: TreeNew().
+				Assoc("/module-v1:system/hostname", "host").
+				Assoc("/module-v1:system/users/user[name='root']/password",
+					"y"),
+		},
+		{
+			name: "ancestors",
+			keep: allow("/module-v1:system/hostname",
+				"/module-v1:interfaces/interface[1]/mtu"),
+			expected: TreeNew().
+				Assoc("/module-v1:system/hostname", "host").
+				Assoc("/module-v1:interfaces/interface", ArrayWith(
+					ObjectWith(PairNew("mtu", 9000)))),
+		},
+		{
+			name: "array elements",
+			keep: allow("/module-v1:leaf-list[0]",
+				"/module-v1:leaf-list[2]"),
+			expected: TreeNew().
+				Assoc("/module-v1:leaf-list", ArrayWith(1, 3)),
+		},
+		{
+			name: "empty containers dropped",
+			keep: func(path *InstanceID) bool {
+				v := tree.GetIn(path)
+				return v.IsString() && v.AsString() == "missing"
+			},
+			expected: TreeNew(),
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := tree.Prune(test.keep)
+			if !equal(test.expected, got) {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.expected, got)
+			}
+		})
+	}
+}
+
+func TestTreeMapLeaves(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:interfaces/interface[name='eth0']/mtu", 1500).
+		Assoc("/module-v1:interfaces/interface[name='eth1']/secret", "x").
+		Assoc("/module-v1:system/users/user[name='root']/password", "y").
+		Assoc("/module-v1:system/hostname", "host").
+		Assoc("/module-v1:system/debug", true).
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 2, 3))
+	var paths []string
+	got := tree.MapLeaves(func(path *InstanceID, v *Value) *Value {
+		paths = append(paths, path.String())
+		switch path.Last().Identifier {
+		case "secret", "password":
+			return ValueNew("****")
+		case "debug":
+			return nil
+		case "leaf-list":
+			if v.AsUint32() == 2 {
+				return nil
+			}
+		}
+		return v
+	})
+	expected := TreeNew().
+		Assoc("/module-v1:interfaces/interface[name='eth0']/mtu", 1500).
+		Assoc("/module-v1:interfaces/interface[name='eth1']/secret", "****").
+		Assoc("/module-v1:system/users/user[name='root']/password", "****").
+		Assoc("/module-v1:system/hostname", "host").
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 3))
+	if !equal(got, expected) {
+		t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+	}
+	sort.Strings(paths)
+	expectedPaths := []string{
+		"/module-v1:interfaces/interface[0]/mtu",
+		"/module-v1:interfaces/interface[0]/name",
+		"/module-v1:interfaces/interface[1]/name",
+		"/module-v1:interfaces/interface[1]/secret",
+		"/module-v1:leaf-list[0]",
+		"/module-v1:leaf-list[1]",
+		"/module-v1:leaf-list[2]",
+		"/module-v1:system/debug",
+		"/module-v1:system/hostname",
+		"/module-v1:system/users/user[0]/name",
+		"/module-v1:system/users/user[0]/password",
+	}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Fatalf("expected: %v\ngot: %v\n", expectedPaths, paths)
+	}
+	if !equal(tree.At("/module-v1:system/debug"), ValueNew(true)) {
+		t.Fatal("original tree was modified")
+	}
+	t.Run("containers kept", func(t *testing.T) {
+		got := tree.MapLeaves(func(*InstanceID, *Value) *Value {
+			return nil
+		})
+		expected := TreeNew().
+			Assoc("/module-v1:interfaces/interface",
+				ArrayWith(ObjectNew(), ObjectNew())).
+			Assoc("/module-v1:system/users/user", ArrayWith(ObjectNew())).
+			Assoc("/module-v1:leaf-list", ArrayNew())
+		if !equal(got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("null leaf", func(t *testing.T) {
+		var tree Tree
+		err := rfc7951.Unmarshal([]byte(`{"m:e":null,"m:x":1}`), &tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		got := tree.MapLeaves(func(path *InstanceID, v *Value) *Value {
+			paths = append(paths, path.String())
+			return v
+		})
+		if !equal(got, &tree) {
+			t.Fatalf("expected: %s\ngot: %s\n", &tree, got)
+		}
+		sort.Strings(paths)
+		expectedPaths := []string{"/m:e", "/m:x"}
+		if !reflect.DeepEqual(paths, expectedPaths) {
+			t.Fatalf("expected: %v\ngot: %v\n", expectedPaths, paths)
+		}
+	})
+}
+
+func TestTreeTransform(t *testing.T) {
+	orig := TreeFromObject(TESTOBJ)
+	edits := []struct {
+		path  string
+		value interface{}
+	}{
+		{"/module-v1:container/containerleaf", "!!!"},
+		{"/module-v1:nested/container/containerleaf", "!!!"},
+		{"/module-v1:nested-list[key='nest1']/container/containerleaf",
+			"!!!"},
+		{"/module-v1:nested-list[key='new']/container/containerleaf",
+			"!!!"},
+		{"/module-v1:nested-list[key='new']/list[key='a']/objleaf", 1},
+		{"/module-v1:nested-list[key='new']/list[key='a']/other", 2},
+		{"/module-v1:nested-list[key='new']/list[key='b']/objleaf", 3},
+		{"/module-v1:list[key='foo']", ObjectWith(PairNew("objleaf", 4))},
+		{"/module-v1:leaf-list[.='5']", "5"},
+		{"/module-v1:leaf-list[10]", 11},
+		{"/module-v1:new/container/leaf", "new"},
+		{"/module-v1:new/container/module-v2:leaf", "augment"},
+		{"/module-v1:new/list[0]/leaf", "pos"},
+	}
+	expected := orig
+	for _, edit := range edits {
+		expected = expected.Assoc(edit.path, edit.value)
+	}
+	got := orig.Transform(func(tt *TTree) {
+		for _, edit := range edits {
+			tt.Assoc(edit.path, edit.value)
+		}
+	})
+	if !equal(expected, got) {
+		t.Fatalf("expected: %s\ngot: %s\ndiff: %s\n",
+			expected, got, expected.Diff(got))
+	}
+	if !equal(orig, TreeFromObject(TESTOBJ)) {
+		t.Fatal("Transform modified the original tree")
+	}
+	t.Run("delete and merge", func(t *testing.T) {
+		other := TreeNew().Assoc("/module-v1:merged/leaf", "m")
+		expected := orig.
+			Assoc("/module-v1:list[key='foo']/objleaf", "x").
+			Delete("/module-v1:list[key='bar']").
+			Merge(other).
+			Assoc("/module-v1:list[key='foo']/other", "y")
+		got := orig.Transform(func(tt *TTree) {
+			tt.Assoc("/module-v1:list[key='foo']/objleaf", "x").
+				Delete("/module-v1:list[key='bar']").
+				Merge(other).
+				Assoc("/module-v1:list[key='foo']/other", "y")
+			if !equal(tt.At("/module-v1:list[key='foo']/objleaf"),
+				ValueNew("x")) {
+				t.Fatalf("expected: x\ngot: %s\n",
+					tt.At("/module-v1:list[key='foo']/objleaf"))
+			}
+		})
+		if !equal(expected, got) {
+			t.Fatalf("expected: %s\ngot: %s\ndiff: %s\n",
+				expected, got, expected.Diff(got))
+		}
+	})
+	t.Run("list stays transient", func(t *testing.T) {
+		expected := orig.
+			Assoc("/module-v1:list[key='foo']/objleaf", 1).
+			Assoc("/module-v1:list[key='bar']/other", 2).
+			Assoc("/module-v1:list[key='new']/objleaf", 3).
+			Assoc("/module-v1:list[key='foo']/other", 4)
+		got := orig.Transform(func(tt *TTree) {
+			tt.Assoc("/module-v1:list[key='foo']/objleaf", 1)
+			list := tt.root.children["module-v1:list"]
+			arr := list.arr
+			tt.Assoc("/module-v1:list[key='bar']/other", 2).
+				Assoc("/module-v1:list[key='new']/objleaf", 3).
+				Assoc("/module-v1:list[key='foo']/other", 4)
+			if tt.root.children["module-v1:list"] != list ||
+				list.arr != arr {
+				t.Fatal("list was frozen by a predicate")
+			}
+		})
+		if !equal(expected, got) {
+			t.Fatalf("expected: %s\ngot: %s\ndiff: %s\n",
+				expected, got, expected.Diff(got))
+		}
+	})
+	t.Run("descend through leaf", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+		orig.Transform(func(tt *TTree) {
+			tt.Assoc("/module-v1:container/containerleaf/leaf", 1)
+		})
+	})
+}
+
+func BenchmarkTreeTransformSorted(b *testing.B) {
+	paths := genSortedPaths(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		TreeNew().Transform(func(tt *TTree) {
+			for _, path := range paths {
+				tt.Assoc(path, 1)
+			}
+		})
+	}
+}
+
+func TestTreeEqualExcept(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:stats/name", "eth0").
+		Assoc("/module-v1:stats/timestamp", "10:00").
+		Assoc("/module-v1:list", ArrayWith(
+			ObjectWith(PairNew("key", "a"), PairNew("counter", 1)),
+			ObjectWith(PairNew("key", "b"), PairNew("counter", 2))))
+	volatile := func(id *InstanceID) bool {
+		nodes := id.Nodes()
+		switch nodes[len(nodes)-1].Identifier {
+		case "timestamp", "counter":
+			return true
+		}
+		return false
+	}
+	cases := []struct {
+		name     string
+		other    *Tree
+		expected bool
+	}{
+		{"identical", tree, true},
+		{"volatile-leaf", tree.Assoc("/module-v1:stats/timestamp",
+			"11:00"), true},
+		{"volatile-list-leaf", tree.Assoc(
+			"/module-v1:list[key='b']/counter", 7), true},
+		{"volatile-deleted", tree.Delete("/module-v1:stats/timestamp"),
+			true},
+		{"other-leaf", tree.Assoc("/module-v1:stats/name", "eth1"),
+			false},
+		{"both", tree.Assoc("/module-v1:stats/name", "eth1").
+			Assoc("/module-v1:stats/timestamp", "11:00"), false},
+		{"entry-added", tree.Assoc("/module-v1:list[key='c']/counter",
+			3), false},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := tree.EqualExcept(test.other, volatile)
+			if got != test.expected {
+				t.Fatalf("expected: %v\ngot: %v\n", test.expected, got)
+			}
+		})
+	}
+	if tree.At("/module-v1:stats/timestamp").ToString() != "10:00" {
+		t.Fatal("EqualExcept modified the tree")
+	}
+}
+
+func TestTreeApplyMergePatch(t *testing.T) {
+	tree := TreeNew().
+		Assoc("/module-v1:container/leaf", "foo").
+		Assoc("/module-v1:container/inner/a", "1").
+		Assoc("/module-v1:container/inner/b", "2").
+		Assoc("/module-v1:container/module-v2:aug", "bar").
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 2, 3)).
+		Assoc("/module-v1:other", "baz")
+	cases := []struct {
+		name     string
+		patch    string
+		expected *Tree
+	}{
+		{
+			name:     "empty",
+			patch:    `{}`,
+			expected: tree,
+		},
+		{
+			name:     "replace leaf",
+			patch:    `{"module-v1:container":{"leaf":"new"}}`,
+			expected: tree.Assoc("/module-v1:container/leaf", "new"),
+		},
+		{
+			name:     "add leaf",
+			patch:    `{"module-v1:container":{"inner":{"c":"x"}}}`,
+			expected: tree.Assoc("/module-v1:container/inner/c", "x"),
+		},
+		{
+			name:     "delete top level",
+			patch:    `{"module-v1:other":null}`,
+			expected: tree.Delete("/module-v1:other"),
+		},
+		{
+			name:  "nested delete",
+			patch: `{"module-v1:container":{"inner":{"a":null}}}`,
+			expected: tree.
+				Delete("/module-v1:container/inner/a"),
+		},
+		{
+			name:  "delete augmented",
+			patch: `{"module-v1:container":{"module-v2:aug":null}}`,
+			expected: tree.
+				Delete("/module-v1:container/module-v2:aug"),
+		},
+		{
+			name:     "delete missing",
+			patch:    `{"module-v1:container":{"missing":{"x":null}}}`,
+			expected: tree.Assoc("/module-v1:container/missing", ObjectNew()),
+		},
+		{
+			name:  "delete and add",
+			patch: `{"module-v1:container":{"inner":null,"leaf":{"x":"y"}}}`,
+			expected: tree.
+				Delete("/module-v1:container/inner").
+				Assoc("/module-v1:container/leaf",
+					ObjectWith(PairNew("x", "y"))),
+		},
+		{
+			name:     "replace array",
+			patch:    `{"module-v1:leaf-list":[4]}`,
+			expected: tree.Assoc("/module-v1:leaf-list", ArrayWith(4)),
+		},
+		{
+			name:     "replace object with leaf",
+			patch:    `{"module-v1:container":"leaf"}`,
+			expected: tree.Assoc("/module-v1:container", "leaf"),
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := tree.ApplyMergePatch([]byte(test.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equal(got, test.expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+		})
+	}
+	for _, patch := range []string{`[1]`, `"x"`, `{`} {
+		t.Run("invalid "+patch, func(t *testing.T) {
+			_, err := tree.ApplyMergePatch([]byte(patch))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -5,6 +5,38 @@
 
 package data
 
+import "sync"
+
+// Interner shares identical strings and scalar values between the
+// values produced by decoding. By default each decode uses its own
+// interner; passing one Interner to several decoders with WithInterner
+// allows the Trees they produce to share their storage, which saves
+// considerable memory when decoding many similar documents. Objects and
+// arrays are never shared. An Interner holds on to everything it has
+// interned until Reset is called. It is safe for concurrent use,
+// decodes using the same Interner are serialized.
+type Interner struct {
+	mu   sync.Mutex
+	strs *stringInterner
+	vals *valueInterner
+}
+
+// InternerNew returns a new empty Interner.
+func InternerNew() *Interner {
+	return &Interner{
+		strs: stringInternerNew(),
+		vals: valueInternerNew(),
+	}
+}
+
+// Reset discards all of the interned strings and values.
+func (i *Interner) Reset() {
+	i.mu.Lock()
+	i.strs = stringInternerNew()
+	i.vals = valueInternerNew()
+	i.mu.Unlock()
+}
+
 type stringInterner struct {
 	vals map[string]string
 }
@@ -29,6 +61,9 @@ type valueInterner struct {
 }
 
 func (i *valueInterner) Intern(val *Value) *Value {
+	if val.IsContainer() {
+		return val
+	}
 	data := val.ToInterface()
 	out, ok := i.vals[data]
 	if ok {
@@ -43,3 +78,21 @@ func valueInternerNew() *valueInterner {
 		vals: make(map[interface{}]*Value),
 	}
 }
+
+type decodeOpts struct {
+	bigInts  bool
+	decimals bool
+	interner *Interner
+}
+
+// interners returns the interners to use for a decode, these are those
+// of the Interner supplied with WithInterner if there was one. The
+// returned release function must be called once decoding has finished.
+func (opts *decodeOpts) interners() (*stringInterner, *valueInterner, func()) {
+	i := opts.interner
+	if i == nil {
+		return stringInternerNew(), valueInternerNew(), func() {}
+	}
+	i.mu.Lock()
+	return i.strs, i.vals, i.mu.Unlock
+}
@@ -6,13 +6,20 @@
 package data
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/danos/encoding/rfc7951"
 	"jsouthworth.net/go/dyn"
 	"jsouthworth.net/go/try"
 )
@@ -58,11 +65,21 @@ func valueNew(data interface{}) *Value {
 		// type to store the value as to ensure consistency
 		// with the unmarshal code.
 		data = inferInt64Type(d)
+	case *big.Int:
+		data = bigIntData(d)
+	case BigInt:
+		data = bigIntData(d.int())
+	case Decimal:
 	case float32:
 		data = float64(d)
 	case float64:
 	case bool:
 	case string:
+	case time.Time:
+		// Times are stored in the yang:date-and-time format.
+		data = d.Format(time.RFC3339Nano)
+	case time.Duration:
+		data = d.String()
 	case map[string]interface{}:
 		data = ObjectFrom(d)
 	case []interface{}:
@@ -71,17 +88,57 @@ func valueNew(data interface{}) *Value {
 		}
 		data = ArrayFrom(d)
 	default:
-		panic(errors.New("cannot create value, invalid type"))
+		if !isRegisteredValueType(reflect.TypeOf(data)) {
+			panic(errors.New("cannot create value, invalid type"))
+		}
 	}
 	return &Value{
 		data: data,
 	}
 }
 
+var registeredValueTypes = make(map[reflect.Type]struct{})
+
+var rfc7951StringerType = reflect.TypeOf((*interface {
+	RFC7951String() string
+})(nil)).Elem()
+
+// RegisterValueType allows types defined outside of this package to be
+// stored in a Value. The type must implement RFC7951String() string,
+// which is used to convert it to a string. If the type also implements
+// MarshalRFC7951() ([]byte, error) that is used to encode it, otherwise
+// it is encoded as a JSON string holding RFC7951String, as are YANG's
+// string encoded types such as identityref and binary. Values of a
+// registered type are stored as is and may be matched by Perform using
+// the type itself. RegisterValueType panics if the type does not
+// implement RFC7951String.
+//
+// The registry is not safe for concurrent modification,
+// RegisterValueType should be called from an init function before any
+// values are created.
+func RegisterValueType(typ reflect.Type) {
+	if !typ.Implements(rfc7951StringerType) {
+		panic(errors.New("cannot register value type " +
+			typ.String() + ", must implement RFC7951String"))
+	}
+	registeredValueTypes[typ] = struct{}{}
+}
+
+func isRegisteredValueType(typ reflect.Type) bool {
+	_, registered := registeredValueTypes[typ]
+	return registered
+}
+
 // Value is an RFC7951 value. Values may be *Object, *Array, *InstanceID,
-// int32, int64, uint32, uint64, float64, string, bool, Empty or nil.
+// int32, int64, uint32, uint64, BigInt, float64, Decimal, string, bool,
+// Empty, nil or a type registered with RegisterValueType.
 // All (u)integer types less than 32 are up-converted to a 32bit type when
 // creating a value.
+// A time.Time is stored as a string in the yang:date-and-time format and
+// a time.Duration as a string in the format of its String method, see
+// AsTime and AsDuration.
+// An *InstanceID is encoded as a quoted string, as RFC7951 section 6.11
+// requires.
 type Value struct {
 	data interface{}
 }
@@ -107,48 +164,124 @@ var stringType = reflect.TypeOf(String(""))
 // then that is considered a match and the conversion is applied first,
 // this is not go's standard ConvertibleTo however, only uint32 <-> int32
 // and uint64 <-> int64 are supported and only if the values fit.
+//
+// A func() oT taking no arguments is a default, it is applied only if
+// none of the other functions match, including when val is nil. If
+// more than one default is supplied the first is used.
+//
+// Perform returns nil if no function was applied, see PerformOK to
+// distinguish this from a function that returned nil. When the same
+// functions are applied to many values use a PerformSet instead.
 func (val *Value) Perform(fns ...interface{}) interface{} {
-	if val == nil {
-		return nil
-	}
-	vty := reflect.TypeOf(val.data)
-	var action interface{}
-	arg := val.data
+	out, _ := val.PerformOK(fns...)
+	return out
+}
+
+// PerformOK is like Perform but also reports whether one of the
+// functions, including a default, was applied. When it returns false
+// the result is always nil.
+func (val *Value) PerformOK(fns ...interface{}) (interface{}, bool) {
+	var fallback interface{}
 	for _, fn := range fns {
-		if action != nil {
-			break
+		fnty := reflect.TypeOf(fn)
+		switch fnty.NumIn() {
+		case 0:
+			if fallback == nil {
+				fallback = fn
+			}
+		case 1:
+			if arg, ok := val.performArg(fnty.In(0)); ok {
+				return dyn.Apply(fn, arg), true
+			}
 		}
+	}
+	return performFallback(fallback)
+}
+
+// PerformSet is a set of functions, as accepted by Perform, whose
+// types have been examined once so that they may be applied to many
+// values without repeating that work on each call.
+type PerformSet struct {
+	fns      []performFunc
+	fallback interface{}
+}
+
+type performFunc struct {
+	fn    interface{}
+	input reflect.Type
+}
+
+// PerformSetNew creates a PerformSet from the functions, which are
+// matched against values in the same way as by Perform.
+func PerformSetNew(fns ...interface{}) *PerformSet {
+	set := &PerformSet{fns: make([]performFunc, 0, len(fns))}
+	for _, fn := range fns {
 		fnty := reflect.TypeOf(fn)
-		if fnty.NumIn() != 1 {
-			continue
-		}
-		inputType := fnty.In(0)
-		switch {
-		case vty == nil:
-			if inputType == interfaceType {
-				action = fn
+		switch fnty.NumIn() {
+		case 0:
+			if set.fallback == nil {
+				set.fallback = fn
 			}
-		case inputType == valType:
-			arg = val
-			action = fn
-		case inputType == stringType:
-			arg = String(val.RFC7951String())
-			action = fn
-		case vty.AssignableTo(inputType):
-			action = fn
-		case canConvertNumeric(vty, inputType, arg):
-			// Schema less parsing means we don't really know
-			// the right numeric type, we use uint32 for all
-			// positive numbers but they may actually be int32.
-			// Let the user request an int32 if the number fits.
-			arg = convertNumeric(arg, inputType)
-			action = fn
-		}
-	}
-	if action == nil {
-		return nil
+		case 1:
+			set.fns = append(set.fns, performFunc{
+				fn:    fn,
+				input: fnty.In(0),
+			})
+		}
 	}
-	return dyn.Apply(action, arg)
+	return set
+}
+
+// Apply applies the first function in the set that matches the value,
+// it is equivalent to calling val.Perform with the set's functions.
+func (s *PerformSet) Apply(val *Value) interface{} {
+	out, _ := s.ApplyOK(val)
+	return out
+}
+
+// ApplyOK is like Apply but also reports whether one of the functions,
+// including a default, was applied, see PerformOK.
+func (s *PerformSet) ApplyOK(val *Value) (interface{}, bool) {
+	for _, f := range s.fns {
+		if arg, ok := val.performArg(f.input); ok {
+			return dyn.Apply(f.fn, arg), true
+		}
+	}
+	return performFallback(s.fallback)
+}
+
+func performFallback(fallback interface{}) (interface{}, bool) {
+	if fallback == nil {
+		return nil, false
+	}
+	return dyn.Apply(fallback), true
+}
+
+// performArg reports whether a function taking an argument of the
+// input type matches the value and if so returns the argument it
+// should be applied to.
+func (val *Value) performArg(input reflect.Type) (interface{}, bool) {
+	if val == nil {
+		return nil, false
+	}
+	vty := reflect.TypeOf(val.data)
+	switch {
+	case vty == nil:
+		return val.data, input == interfaceType
+	case input == valType:
+		return val, true
+	case input == stringType:
+		return String(val.RFC7951String()), true
+	case vty.AssignableTo(input):
+		return val.data, true
+	case canConvertNumeric(vty, input, val.data):
+		// Schema less parsing means we don't really know
+		// the right numeric type, we use uint32 for all
+		// positive numbers but they may actually be int32.
+		// Let the user request an int32 if the number fits.
+		return convertNumeric(val.data, input), true
+	}
+	return nil, false
 }
 
 func canConvertNumeric(from, to reflect.Type, v interface{}) bool {
@@ -178,6 +311,43 @@ func convertNumeric(from interface{}, to reflect.Type) interface{} {
 		Interface()
 }
 
+// Get descends through nested objects and arrays following each of the
+// supplied steps in turn and returns the value reached. A string step
+// is a key of an object, which may be either 'module:key' or just key
+// if the module is the same as the containing object's module. An int
+// step is an index into an array, negative indices count back from the
+// end as they do for Array.At. Get returns nil as soon as a step
+// doesn't exist or the value isn't of the kind the step requires, so
+// optional data may be navigated without checks at each level:
+//
+//     v.Get("module:a", "b", 0, "c")
+//
+// Get panics if a step is neither a string nor an int.
+func (val *Value) Get(steps ...interface{}) *Value {
+	out := val
+	for _, step := range steps {
+		if out == nil {
+			return nil
+		}
+		switch s := step.(type) {
+		case string:
+			if !out.IsObject() {
+				return nil
+			}
+			out = out.AsObject().At(s)
+		case int:
+			if !out.IsArray() {
+				return nil
+			}
+			out = out.AsArray().At(s)
+		default:
+			panic(fmt.Errorf("invalid step %v of type %T, "+
+				"must be a string or an int", step, step))
+		}
+	}
+	return out
+}
+
 // ToTree returns a *Tree if the value is an Object and panics otherwise.
 func (val *Value) ToTree() *Tree {
 	return val.Perform(func(o *Object) *Tree {
@@ -192,6 +362,16 @@ func (val *Value) AsObject() *Object {
 	return val.data.(*Object)
 }
 
+// AsObjectE is like AsObject but returns a *KindError instead of
+// panicking if the value is not an Object.
+func (val *Value) AsObjectE() (*Object, error) {
+	obj, isObject := val.data.(*Object)
+	if !isObject {
+		return nil, val.kindError(KindObject)
+	}
+	return obj, nil
+}
+
 // IsObject returns if the data stored in the value is an Object.
 func (val *Value) IsObject() bool {
 	_, isObject := val.data.(*Object)
@@ -217,6 +397,16 @@ func (val *Value) AsArray() *Array {
 	return val.data.(*Array)
 }
 
+// AsArrayE is like AsArray but returns a *KindError instead of
+// panicking if the value is not an Array.
+func (val *Value) AsArrayE() (*Array, error) {
+	arr, isArray := val.data.(*Array)
+	if !isArray {
+		return nil, val.kindError(KindArray)
+	}
+	return arr, nil
+}
+
 // IsArray returns if the data stored in the value is an Array.
 func (val *Value) IsArray() bool {
 	_, isArray := val.data.(*Array)
@@ -242,6 +432,16 @@ func (val *Value) AsString() string {
 	return val.data.(string)
 }
 
+// AsStringE is like AsString but returns a *KindError instead of
+// panicking if the value is not a string.
+func (val *Value) AsStringE() (string, error) {
+	str, isString := val.data.(string)
+	if !isString {
+		return "", val.kindError(KindString)
+	}
+	return str, nil
+}
+
 // IsString returns if the data stored in the value is an String.
 func (val *Value) IsString() bool {
 	_, isString := val.data.(string)
@@ -262,6 +462,57 @@ func (val *Value) ToString(defaultVal ...string) string {
 	return ""
 }
 
+// AsBytes returns the base64 decoded contents of a string value as
+// used for the YANG binary type. It panics if the value is not a
+// string or is not valid standard base64. The value is stored as a
+// string, decoding is only done on request so marshalling the value
+// round-trips unchanged.
+func (val *Value) AsBytes() []byte {
+	b, err := base64.StdEncoding.DecodeString(val.data.(string))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// AsBytesE is like AsBytes but returns an error instead of panicking.
+// A *KindError is returned if the value is not a string.
+func (val *Value) AsBytesE() ([]byte, error) {
+	str, isString := val.data.(string)
+	if !isString {
+		return nil, val.kindError(KindString)
+	}
+	return base64.StdEncoding.DecodeString(str)
+}
+
+// IsBytes returns if the value is a string containing valid standard
+// base64 encoded data.
+func (val *Value) IsBytes() bool {
+	str, isString := val.data.(string)
+	if !isString {
+		return false
+	}
+	_, err := base64.StdEncoding.DecodeString(str)
+	return err == nil
+}
+
+// ToBytes returns the base64 decoded contents of a string value and
+// allows the user to define a default. The value []byte(nil) is
+// returned if no default is defined and the value is not valid base64.
+func (val *Value) ToBytes(defaultVal ...[]byte) []byte {
+	str, isString := val.data.(string)
+	if isString {
+		b, err := base64.StdEncoding.DecodeString(str)
+		if err == nil {
+			return b
+		}
+	}
+	if len(defaultVal) != 0 {
+		return defaultVal[0]
+	}
+	return nil
+}
+
 // RFC7951String converts the object to a string that can be encoded in
 // RFC7951 format. This may be different than what String returns
 // so interface { RFC7951String() string } may be implemented to override
@@ -299,6 +550,20 @@ func (val *Value) RFC7951String() string {
 	}
 }
 
+// RFC7951StringFloat is like RFC7951String but formats floating point
+// values with exactly prec digits after the decimal point. A prec of -1
+// uses the fewest digits needed to represent the value exactly, as
+// RFC7951String does. For a YANG decimal64 leaf prec should be its
+// fraction-digits so that, for instance, 10 is formatted as "10.0"
+// when fraction-digits is 1. Values that aren't floating point are
+// formatted as by RFC7951String.
+func (val *Value) RFC7951StringFloat(prec int) string {
+	if d, isFloat := val.data.(float64); isFloat {
+		return strconv.FormatFloat(d, 'f', prec, 64)
+	}
+	return val.RFC7951String()
+}
+
 var int32Type = reflect.TypeOf(int32(0))
 
 func convertToInt32(v interface{}) int32 {
@@ -327,6 +592,15 @@ func (val *Value) AsInt32() int32 {
 	return convertToInt32(val.data)
 }
 
+// AsInt32E is like AsInt32 but returns a *KindError instead of panicking
+// if the type is not convertable to int32.
+func (val *Value) AsInt32E() (int32, error) {
+	if !val.convertibleTo(int32Type) {
+		return 0, val.kindError(KindInt32)
+	}
+	return convertToInt32(val.data), nil
+}
+
 // IsInt32 returns if the value is an int32
 func (val *Value) IsInt32() bool {
 	return canConvertNumeric(reflect.TypeOf(val.data),
@@ -335,7 +609,7 @@ func (val *Value) IsInt32() bool {
 
 // ToInt32 returns an int32 if the type is convertable to int32 and returns the user supplied default or 0 otherwise.
 func (val *Value) ToInt32(defaultVal ...int32) int32 {
-	if reflect.TypeOf(val.data).ConvertibleTo(int32Type) {
+	if val.convertibleTo(int32Type) {
 		return convertToInt32(val.data)
 	}
 	if len(defaultVal) != 0 {
@@ -349,6 +623,15 @@ func (val *Value) AsUint32() uint32 {
 	return convertToUint32(val.data)
 }
 
+// AsUint32E is like AsUint32 but returns a *KindError instead of panicking
+// if the type is not convertable to uint32.
+func (val *Value) AsUint32E() (uint32, error) {
+	if !val.convertibleTo(uint32Type) {
+		return 0, val.kindError(KindUint32)
+	}
+	return convertToUint32(val.data), nil
+}
+
 // IsUint32 returns if the value is an uint32
 func (val *Value) IsUint32() bool {
 	return canConvertNumeric(reflect.TypeOf(val.data),
@@ -357,7 +640,7 @@ func (val *Value) IsUint32() bool {
 
 // ToUint32 returns an uint32 if the type is convertable to uint32 and returns the user supplied default or 0 otherwise.
 func (val *Value) ToUint32(defaultVal ...uint32) uint32 {
-	if reflect.TypeOf(val.data).ConvertibleTo(uint32Type) {
+	if val.convertibleTo(uint32Type) {
 		return convertToUint32(val.data)
 	}
 	if len(defaultVal) != 0 {
@@ -386,6 +669,15 @@ func (val *Value) AsInt64() int64 {
 	return convertToInt64(val.data)
 }
 
+// AsInt64E is like AsInt64 but returns a *KindError instead of panicking
+// if the type is not convertable to int64.
+func (val *Value) AsInt64E() (int64, error) {
+	if !val.convertibleTo(int64Type) {
+		return 0, val.kindError(KindInt64)
+	}
+	return convertToInt64(val.data), nil
+}
+
 // IsInt64 returns if the value is an int64
 func (val *Value) IsInt64() bool {
 	return canConvertNumeric(reflect.TypeOf(val.data),
@@ -394,7 +686,7 @@ func (val *Value) IsInt64() bool {
 
 // ToInt64 returns an int64 if the type is convertable to int64 and returns the user supplied default or 0 otherwise.
 func (val *Value) ToInt64(defaultVal ...int64) int64 {
-	if reflect.TypeOf(val.data).ConvertibleTo(int64Type) {
+	if val.convertibleTo(int64Type) {
 		return convertToInt64(val.data)
 	}
 	if len(defaultVal) != 0 {
@@ -416,6 +708,15 @@ func (val *Value) AsUint64() uint64 {
 	return convertToUint64(val.data)
 }
 
+// AsUint64E is like AsUint64 but returns a *KindError instead of panicking
+// if the type is not convertable to uint64.
+func (val *Value) AsUint64E() (uint64, error) {
+	if !val.convertibleTo(uint64Type) {
+		return 0, val.kindError(KindUint64)
+	}
+	return convertToUint64(val.data), nil
+}
+
 // IsUint64 returns if the value is an uint64
 func (val *Value) IsUint64() bool {
 	return canConvertNumeric(reflect.TypeOf(val.data),
@@ -424,7 +725,7 @@ func (val *Value) IsUint64() bool {
 
 // ToUint64 returns an uint64 if the type is convertable to uint64 and returns the user supplied default or 0 otherwise.
 func (val *Value) ToUint64(defaultVal ...uint64) uint64 {
-	if reflect.TypeOf(val.data).ConvertibleTo(uint64Type) {
+	if val.convertibleTo(uint64Type) {
 		return convertToUint64(val.data)
 	}
 	if len(defaultVal) != 0 {
@@ -433,6 +734,28 @@ func (val *Value) ToUint64(defaultVal ...uint64) uint64 {
 	return 0
 }
 
+// InRangeInt64 returns whether the value is an integer within the
+// inclusive range [min, max]. Any of the integer types, including
+// BigInt, are compared by value; floats and non-numeric values are
+// never in range.
+func (val *Value) InRangeInt64(min, max int64) bool {
+	n, isInteger := integerValue(val.data)
+	return isInteger &&
+		n.Cmp(big.NewInt(min)) >= 0 &&
+		n.Cmp(big.NewInt(max)) <= 0
+}
+
+// InRangeUint64 returns whether the value is an integer within the
+// inclusive range [min, max]. Any of the integer types, including
+// BigInt, are compared by value; negative numbers, floats and
+// non-numeric values are never in range.
+func (val *Value) InRangeUint64(min, max uint64) bool {
+	n, isInteger := integerValue(val.data)
+	return isInteger &&
+		n.Cmp(new(big.Int).SetUint64(min)) >= 0 &&
+		n.Cmp(new(big.Int).SetUint64(max)) <= 0
+}
+
 var float64Type = reflect.TypeOf(float64(0))
 
 func convertToFloat(v interface{}) float64 {
@@ -446,6 +769,15 @@ func (val *Value) AsFloat() float64 {
 	return convertToFloat(val.data)
 }
 
+// AsFloatE is like AsFloat but returns a *KindError instead of
+// panicking if the type is not convertable to float64.
+func (val *Value) AsFloatE() (float64, error) {
+	if !val.convertibleTo(float64Type) {
+		return 0, val.kindError(KindFloat)
+	}
+	return convertToFloat(val.data), nil
+}
+
 // IsFloat returns if the value is an float
 func (val *Value) IsFloat() bool {
 	_, isFloat := val.data.(float64)
@@ -454,7 +786,7 @@ func (val *Value) IsFloat() bool {
 
 // ToFloat returns an float64 if the type is convertable to float64 and returns the user supplied default or 0 otherwise.
 func (val *Value) ToFloat(defaultVal ...float64) float64 {
-	if reflect.TypeOf(val.data).ConvertibleTo(float64Type) {
+	if val.convertibleTo(float64Type) {
 		return convertToFloat(val.data)
 	}
 	if len(defaultVal) != 0 {
@@ -463,6 +795,162 @@ func (val *Value) ToFloat(defaultVal ...float64) float64 {
 	return 0
 }
 
+// AsTime parses a string value in the yang:date-and-time format, as
+// stored by ValueNew for a time.Time, and returns the time it holds. An
+// error is returned if the value is not a string or is not a valid
+// RFC3339 date and time. The value is stored as a string, parsing is
+// only done on request so marshalling the value round-trips unchanged.
+func (val *Value) AsTime() (time.Time, error) {
+	str, isString := val.data.(string)
+	if !isString {
+		return time.Time{}, fmt.Errorf("cannot convert %s to time.Time",
+			valueKindName(val))
+	}
+	return time.Parse(time.RFC3339Nano, str)
+}
+
+// IsTime returns if the value is a string containing a valid
+// yang:date-and-time.
+func (val *Value) IsTime() bool {
+	_, err := val.AsTime()
+	return err == nil
+}
+
+// ToTime returns the time held in a string value and allows the user
+// to define a default. The zero time.Time is returned if no default is
+// defined and the value is not a valid yang:date-and-time.
+func (val *Value) ToTime(defaultVal ...time.Time) time.Time {
+	t, err := val.AsTime()
+	if err == nil {
+		return t
+	}
+	if len(defaultVal) != 0 {
+		return defaultVal[0]
+	}
+	return time.Time{}
+}
+
+// AsDuration parses a string value in the format produced by
+// time.Duration's String method, as stored by ValueNew for a
+// time.Duration, and returns the duration it holds. An error is
+// returned if the value is not a string or is not a valid duration.
+func (val *Value) AsDuration() (time.Duration, error) {
+	str, isString := val.data.(string)
+	if !isString {
+		return 0, fmt.Errorf("cannot convert %s to time.Duration",
+			valueKindName(val))
+	}
+	return time.ParseDuration(str)
+}
+
+// IsDuration returns if the value is a string containing a valid
+// duration.
+func (val *Value) IsDuration() bool {
+	_, err := val.AsDuration()
+	return err == nil
+}
+
+// ToDuration returns the duration held in a string value and allows
+// the user to define a default. Zero is returned if no default is
+// defined and the value is not a valid duration.
+func (val *Value) ToDuration(defaultVal ...time.Duration) time.Duration {
+	d, err := val.AsDuration()
+	if err == nil {
+		return d
+	}
+	if len(defaultVal) != 0 {
+		return defaultVal[0]
+	}
+	return 0
+}
+
+// AsBigInt returns the value as a *big.Int. Any integer value may be
+// returned as a *big.Int, strings are parsed as base 10 integers which
+// allows integers too large for 64 bits that were decoded as strings
+// to be accessed. An error is returned if the value is not an integer.
+func (val *Value) AsBigInt() (*big.Int, error) {
+	switch v := val.data.(type) {
+	case BigInt:
+		return v.Int(), nil
+	case string:
+		i, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %q to big.Int", v)
+		}
+		return i, nil
+	}
+	i, isInteger := integerValue(val.data)
+	if !isInteger {
+		return nil, fmt.Errorf("cannot convert %s to big.Int",
+			valueKindName(val))
+	}
+	return i, nil
+}
+
+// IsBigInt returns if the value is an integer too large to be stored
+// in 64 bits.
+func (val *Value) IsBigInt() bool {
+	_, isBigInt := val.data.(BigInt)
+	return isBigInt
+}
+
+// AsDecimal returns the value as a Decimal. Integers, floats and
+// strings in the form of a decimal64 may be returned as Decimals, the
+// shortest decimal form that converts back to the same float is used
+// for a float. AsDecimal panics for any other value.
+func (val *Value) AsDecimal() Decimal {
+	d, err := val.AsDecimalE()
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// AsDecimalE is like AsDecimal but returns a *KindError instead of
+// panicking if the value can't be converted to a Decimal.
+func (val *Value) AsDecimalE() (Decimal, error) {
+	var d Decimal
+	var err error
+	switch v := val.data.(type) {
+	case Decimal:
+		return v, nil
+	case string:
+		d, err = DecimalNew(v)
+	case float64:
+		d, err = DecimalNew(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		i, isInteger := integerValue(val.data)
+		if !isInteger {
+			return Decimal{}, val.kindError(KindDecimal)
+		}
+		d, err = DecimalNew(i.String())
+	}
+	if err != nil {
+		return Decimal{}, val.kindError(KindDecimal)
+	}
+	return d, nil
+}
+
+// IsDecimal returns if the value is a Decimal.
+func (val *Value) IsDecimal() bool {
+	_, isDecimal := val.data.(Decimal)
+	return isDecimal
+}
+
+// ToDecimal returns the value as a Decimal and allows the user to
+// define a default. The zero Decimal is returned if no default is
+// defined and the value can't be converted by AsDecimal.
+func (val *Value) ToDecimal(defaultVal ...Decimal) Decimal {
+	d, err := val.AsDecimalE()
+	if err == nil {
+		return d
+	}
+	if len(defaultVal) != 0 {
+		return defaultVal[0]
+	}
+	return Decimal{}
+}
+
 // AsBoolean returns a bool if the value is a bool or if the value is Empty it returns true.
 func (val *Value) AsBoolean() bool {
 	if val.IsEmpty() {
@@ -471,6 +959,19 @@ func (val *Value) AsBoolean() bool {
 	return val.data.(bool)
 }
 
+// AsBooleanE is like AsBoolean but returns a *KindError instead of
+// panicking if the value is neither a bool nor Empty.
+func (val *Value) AsBooleanE() (bool, error) {
+	if val.IsEmpty() {
+		return true, nil
+	}
+	b, isBool := val.data.(bool)
+	if !isBool {
+		return false, val.kindError(KindBool)
+	}
+	return b, nil
+}
+
 // IsBoolean returns if the value is an bool
 func (val *Value) IsBoolean() bool {
 	_, isBoolean := val.data.(bool)
@@ -525,6 +1026,25 @@ func (val *Value) AsInstanceID() *InstanceID {
 	}
 }
 
+// AsInstanceIDE is like AsInstanceID but returns an error instead of
+// panicking. A *KindError is returned if the value is neither an
+// instance-identifier nor a string, and the parse error is returned if
+// the string isn't a valid instance-identifier.
+func (val *Value) AsInstanceIDE() (*InstanceID, error) {
+	switch v := val.data.(type) {
+	case *InstanceID:
+		return v, nil
+	case string:
+		id, err := try.Apply(InstanceIDNew, v)
+		if err != nil {
+			return nil, err
+		}
+		return id.(*InstanceID), nil
+	default:
+		return nil, val.kindError(KindInstanceID)
+	}
+}
+
 // IsInstanceID returns whether the value is an instance-identifier.
 func (val *Value) IsInstanceID() bool {
 	switch v := val.data.(type) {
@@ -582,14 +1102,35 @@ func (val *Value) IsNull() bool {
 	return val.data == nil
 }
 
+// IsContainer returns whether the value is an Object or an Array, a
+// branch of a tree that may hold other values.
+func (val *Value) IsContainer() bool {
+	return val.IsObject() || val.IsArray()
+}
+
+// IsScalar returns whether the value is a leaf that holds no other
+// values. Every value that isn't a container is a scalar, including
+// null and empty.
+func (val *Value) IsScalar() bool {
+	return !val.IsContainer()
+}
+
 // Merge will combine the old value with the new value and return the
-// result.
+// result. Arrays are merged positionally, see MergeArrays for control
+// over how arrays are combined.
 func (val *Value) Merge(new *Value) *Value {
+	return val.MergeArrays(new, ArrayByIndex)
+}
+
+// MergeArrays will combine the old value with the new value and return
+// the result. Any arrays encountered, including those nested inside
+// objects, are combined according to the supplied policy.
+func (val *Value) MergeArrays(new *Value, policy ArrayMergePolicy) *Value {
 	switch val := val.data.(type) {
 	case interface {
-		merge(*Value) *Value
+		merge(*Value, ArrayMergePolicy) *Value
 	}:
-		return val.merge(new)
+		return val.merge(new, policy)
 	default:
 		return new
 	}
@@ -613,6 +1154,9 @@ func (val *Value) diff(new *Value, path *InstanceID) []EditEntry {
 }
 
 // Equal provides an implementation of Equality for Value types.
+// Values are only equal if their stored types match, see
+// DeepNumericEqual for a comparison that ignores the width numbers are
+// stored with.
 func (val *Value) Equal(other interface{}) bool {
 	if other == nil {
 		return val == nil
@@ -625,6 +1169,104 @@ func (val *Value) Equal(other interface{}) bool {
 		equal(val.data, ov.data)
 }
 
+// EqualNumeric returns true when both values are numbers with the same
+// mathematical value regardless of how they are stored. Equal compares
+// the stored go values, so an int64 and a uint64, or a float64 and an
+// integer, are never Equal even when they represent the same number.
+// EqualNumeric returns false if either value is not a number, see
+// DeepNumericEqual to compare objects and arrays containing numbers.
+func (val *Value) EqualNumeric(other *Value) bool {
+	if val == nil || other == nil {
+		return false
+	}
+	a, ok := numericValue(val.data)
+	if !ok {
+		return false
+	}
+	b, ok := numericValue(other.data)
+	if !ok {
+		return false
+	}
+	return a.Cmp(b) == 0
+}
+
+// DeepNumericEqual reports whether two values are equal, comparing any
+// numbers they contain by mathematical value rather than by storage
+// type. Objects and arrays are compared member by member in the same
+// way, all other values are compared with Equal.
+//
+// Schema-aware code, which knows the YANG type of each leaf and builds
+// values with the matching go type, should use Equal so that a change
+// of type is detected. Schema-less code, such as code comparing decoded
+// data against hand-built values, should use DeepNumericEqual since the
+// width a number is stored with is not meaningful without a schema.
+func (val *Value) DeepNumericEqual(other *Value) bool {
+	if val == nil || other == nil {
+		return val == other
+	}
+	if val.EqualNumeric(other) {
+		return true
+	}
+	switch {
+	case val.IsObject() && other.IsObject():
+		a, b := val.AsObject(), other.AsObject()
+		return a.Length() == b.Length() &&
+			a.everyPair(func(key string, v *Value) bool {
+				ov, found := b.Find(key)
+				return found && v.DeepNumericEqual(ov)
+			})
+	case val.IsArray() && other.IsArray():
+		a, b := val.AsArray(), other.AsArray()
+		return a.Length() == b.Length() &&
+			a.everyElement(func(i int, v *Value) bool {
+				return v.DeepNumericEqual(b.At(i))
+			})
+	default:
+		return val.Equal(other)
+	}
+}
+
+func numericValue(v interface{}) (*big.Float, bool) {
+	switch n := v.(type) {
+	case int32:
+		return new(big.Float).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Float).SetInt64(n), true
+	case uint32:
+		return new(big.Float).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Float).SetUint64(n), true
+	case float64:
+		if math.IsNaN(n) {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(n), true
+	case BigInt:
+		return new(big.Float).SetInt(n.int()), true
+	case Decimal:
+		return new(big.Float).SetRat(n.rat()), true
+	default:
+		return nil, false
+	}
+}
+
+func integerValue(v interface{}) (*big.Int, bool) {
+	switch n := v.(type) {
+	case int32:
+		return big.NewInt(int64(n)), true
+	case int64:
+		return big.NewInt(n), true
+	case uint32:
+		return new(big.Int).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Int).SetUint64(n), true
+	case BigInt:
+		return n.Int(), true
+	default:
+		return nil, false
+	}
+}
+
 // Compare provides an implementation of Comparison for Value types.
 func (val *Value) Compare(other interface{}) int {
 	return dyn.Compare(val.data, other.(*Value).data)
@@ -639,6 +1281,61 @@ func (val *Value) String() string {
 	return fmt.Sprintf("%v", val.data)
 }
 
+// Detach returns a deep copy of the value that shares no storage with
+// the original. Strings are freshly allocated so that a value extracted
+// from a large interned document does not keep the rest of the document
+// alive.
+func (val *Value) Detach() *Value {
+	switch v := val.data.(type) {
+	case interface {
+		detach() *Value
+	}:
+		return v.detach()
+	case *InstanceID:
+		return &Value{data: InstanceIDNew(cloneString(v.String()))}
+	case string:
+		return &Value{data: cloneString(v)}
+	case empty:
+		return _empty
+	default:
+		return &Value{data: val.data}
+	}
+}
+
+// Clone returns a structurally independent deep copy of the value.
+// Objects and arrays are rebuilt from fresh storage while leaf values,
+// being immutable, are shared with the original. Values are never
+// mutated so Clone is unnecessary for ordinary use; it exists for
+// handing values to external code that may mutate what it is given.
+// Use Detach to additionally copy strings.
+func (val *Value) Clone() *Value {
+	switch v := val.data.(type) {
+	case interface {
+		clone() *Value
+	}:
+		return v.clone()
+	default:
+		return &Value{data: val.data}
+	}
+}
+
+func cloneString(s string) string {
+	var b strings.Builder
+	b.WriteString(s)
+	return b.String()
+}
+
+func (val *Value) renameModule(from, to string) *Value {
+	switch v := val.data.(type) {
+	case interface {
+		renameModule(string, string) *Value
+	}:
+		return v.renameModule(from, to)
+	default:
+		return val
+	}
+}
+
 func (val *Value) belongsTo(orig *Value, moduleName string) *Value {
 	switch v := val.data.(type) {
 	case interface {
@@ -650,40 +1347,204 @@ func (val *Value) belongsTo(orig *Value, moduleName string) *Value {
 	}
 }
 
-func (val *Value) marshalRFC7951(buf *bytes.Buffer, module string) error {
+// marshalWriter is the set of write operations used to marshal
+// values. It is satisfied by both *bytes.Buffer and *bufio.Writer so
+// the buffered and streaming encoders share their implementation.
+type marshalWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// marshaler is implemented by the values that can marshal themselves
+// to a marshalWriter.
+type marshaler interface {
+	marshalRFC7951(marshalWriter, string) error
+}
+
+// writeRFC7951 streams the RFC7951 encoding of m to w. Output is
+// written through a small buffer as it is produced rather than being
+// accumulated in memory. Any error returned by w is returned.
+func writeRFC7951(w io.Writer, m marshaler) error {
+	bw := bufio.NewWriter(w)
+	err := m.marshalRFC7951(bw, "")
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// appendRFC7951 appends the RFC7951 encoding of m to dst and returns
+// the extended slice.
+func appendRFC7951(dst []byte, m marshaler) ([]byte, error) {
+	w := appendWriter{buf: dst}
+	err := m.marshalRFC7951(&w, "")
+	return w.buf, err
+}
+
+// appendWriter is a marshalWriter that appends to a byte slice, the
+// writes never fail.
+type appendWriter struct {
+	buf []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (w *appendWriter) WriteByte(c byte) error {
+	w.buf = append(w.buf, c)
+	return nil
+}
+
+func (w *appendWriter) WriteString(s string) (int, error) {
+	w.buf = append(w.buf, s...)
+	return len(s), nil
+}
+
+// canonicalWriter wraps a marshalWriter to request canonical output.
+// Objects marshalled to a canonicalWriter emit their members in sorted
+// order.
+type canonicalWriter struct {
+	marshalWriter
+}
+
+// numberWriter wraps a marshalWriter to request that 64 bit integers
+// are written as plain JSON numbers, which RFC7951 doesn't allow.
+type numberWriter struct {
+	marshalWriter
+}
+
+func marshalCanonical(m marshaler) ([]byte, error) {
+	var buf bytes.Buffer
+	err := m.marshalRFC7951(canonicalWriter{&buf}, "")
+	return buf.Bytes(), err
+}
+
+func writeObjectKey(w marshalWriter, key string) error {
+	if err := writeQuoted(w, key); err != nil {
+		return err
+	}
+	return w.WriteByte(':')
+}
+
+// writeQuoted writes s to w surrounded by double quotes. The quotes
+// are written separately to avoid building a new string.
+func writeQuoted(w marshalWriter, s string) error {
+	if err := w.WriteByte('"'); err != nil {
+		return err
+	}
+	if _, err := w.WriteString(s); err != nil {
+		return err
+	}
+	return w.WriteByte('"')
+}
+
+func (val *Value) marshalRFC7951(w marshalWriter, module string) error {
+	var err error
 	switch v := val.data.(type) {
+	case marshaler:
+		return v.marshalRFC7951(w, module)
 	case interface {
-		marshalRFC7951(*bytes.Buffer, string) error
+		MarshalRFC7951() ([]byte, error)
 	}:
-		return v.marshalRFC7951(buf, module)
-	case uint64, int64, float32, float64, string:
-		buf.WriteByte('"')
-		buf.WriteString(val.RFC7951String())
-		buf.WriteByte('"')
+		b, err := v.MarshalRFC7951()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case uint64, int64:
+		if _, plain := w.(numberWriter); plain {
+			_, err = w.WriteString(val.RFC7951String())
+			break
+		}
+		err = writeQuoted(w, val.RFC7951String())
+	case float32, float64, string:
+		err = writeQuoted(w, val.RFC7951String())
+	case nil, empty, uint32, int32, bool:
+		_, err = w.WriteString(val.RFC7951String())
+	case interface {
+		RFC7951String() string
+	}:
+		err = writeQuoted(w, v.RFC7951String())
 	default:
-		buf.WriteString(val.RFC7951String())
+		return fmt.Errorf("cannot marshal value of type %T", v)
 	}
-	return nil
+	return err
+}
+
+// WriteRFC7951 writes the value encoded in an RFC7951 compatible way
+// to w. The encoding is streamed to w as it is produced.
+func (val *Value) WriteRFC7951(w io.Writer) error {
+	return writeRFC7951(w, val)
 }
 
 // MarshalRFC7951 returns the value encoded in an RFC7951 compatible way.
 func (val *Value) MarshalRFC7951() ([]byte, error) {
+	return appendRFC7951(nil, val)
+}
+
+// MarshalRFC7951Indent is like MarshalRFC7951 but formats objects and
+// arrays over multiple lines, as rfc7951.MarshalIndent does, with each
+// element on a new line starting with prefix followed by one or more
+// copies of indent according to the nesting. Scalars, including the
+// empty value, are returned on a single line without a prefix.
+func (val *Value) MarshalRFC7951Indent(prefix, indent string) ([]byte, error) {
+	b, err := val.MarshalRFC7951()
+	if err != nil || !val.IsContainer() {
+		return b, err
+	}
 	var buf bytes.Buffer
-	err := val.marshalRFC7951(&buf, "")
-	return buf.Bytes(), err
+	err = rfc7951.Indent(&buf, b, prefix, indent)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AppendRFC7951 appends the value encoded in an RFC7951 compatible way
+// to dst and returns the extended slice, in the manner of
+// strconv.AppendInt. Reusing dst across calls avoids allocating a new
+// buffer for each encoding. If an error occurs the returned slice holds
+// the output written before the error.
+func (val *Value) AppendRFC7951(dst []byte) ([]byte, error) {
+	return appendRFC7951(dst, val)
 }
 
 // UnmarshalRFC7951 extracts a value from an rfc7951 encoded value.
 func (val *Value) UnmarshalRFC7951(msg []byte) error {
-	strs := stringInternerNew()
-	vals := valueInternerNew()
-	return val.unmarshalRFC7951(msg, "", strs, vals)
+	return val.unmarshalWithOpts(msg, &decodeOpts{})
+}
+
+// MarshalJSON implements json.Marshaler so a Value may be encoded by
+// the encoding/json package. The output is identical to MarshalRFC7951,
+// in particular 64 bit integers are encoded as quoted strings and empty
+// leaves as [null] as RFC7951 requires, so the two paths agree.
+func (val *Value) MarshalJSON() ([]byte, error) {
+	return val.MarshalRFC7951()
+}
+
+// UnmarshalJSON implements json.Unmarshaler so a Value may be decoded
+// by the encoding/json package. The message is interpreted exactly as
+// UnmarshalRFC7951 interprets it. Note that encoding/json sets a *Value
+// to nil when decoding null, without calling UnmarshalJSON.
+func (val *Value) UnmarshalJSON(msg []byte) error {
+	return val.UnmarshalRFC7951(msg)
+}
+
+func (val *Value) unmarshalWithOpts(msg []byte, opts *decodeOpts) error {
+	strs, vals, release := opts.interners()
+	defer release()
+	return val.unmarshalRFC7951(msg, "", strs, vals, opts)
 }
 
 func (val *Value) unmarshalRFC7951(
 	msg []byte, module string,
 	strs *stringInterner,
 	vals *valueInterner,
+	opts *decodeOpts,
 ) error {
 	if len(msg) == 0 {
 		return nil
@@ -691,14 +1552,14 @@ func (val *Value) unmarshalRFC7951(
 	switch c := msg[0]; c {
 	case '{':
 		obj := objectNew()
-		err := obj.unmarshalRFC7951(msg, module, strs, vals)
+		err := obj.unmarshalRFC7951(msg, module, strs, vals, opts)
 		if err != nil {
 			return err
 		}
 		val.data = obj
 	case '[':
 		arr := arrayNew()
-		err := arr.unmarshalRFC7951(msg, module, strs, vals)
+		err := arr.unmarshalRFC7951(msg, module, strs, vals, opts)
 		if err != nil {
 			return err
 		}
@@ -721,77 +1582,7 @@ func (val *Value) unmarshalRFC7951(
 		if err != nil {
 			return err
 		}
-		item = strs.Intern(item)
-		if len(item) == 0 {
-			val.data = item
-			return nil
-		}
-		c := item[0]
-		switch {
-		case c == '-' && len(item) >= 2:
-			n := item[1]
-			if n < '0' || n > '9' {
-				val.data = item
-				return nil
-			}
-			if strings.Contains(item, ".") {
-				f, err := strconv.ParseFloat(item, 64)
-				if err != nil {
-					//it wasn't a float, use the string
-					val.data = item
-					return nil
-				}
-				val.data = f
-			}
-			i, err := strconv.ParseInt(item, 10, 64)
-			if err != nil {
-				//it wasn't an int, use the string
-				val.data = item
-				return nil
-			}
-			val.data = i
-		case c == '+' && len(item) >= 2:
-			n := item[1]
-			if n < '0' || n > '9' {
-				val.data = item
-				return nil
-			}
-			if strings.Contains(item, ".") {
-				f, err := strconv.ParseFloat(item[1:], 64)
-				if err != nil {
-					//it wasn't a float, use the string
-					val.data = item
-					return nil
-				}
-				val.data = f
-			}
-			i, err := strconv.ParseUint(item[1:], 10, 64)
-			if err != nil {
-				//it wasn't an int, use the string
-				val.data = item
-				return nil
-			}
-			val.data = i
-		case c >= '0' && c <= '9':
-			if strings.Contains(item, ".") {
-				f, err := strconv.ParseFloat(item, 64)
-				if err != nil {
-					//it wasn't a float, use the string
-					val.data = item
-					return nil
-				}
-				val.data = f
-			}
-			i, err := strconv.ParseUint(item, 10, 64)
-			if err != nil {
-				//it wasn't an int, use the string
-				val.data = item
-				return nil
-			}
-			val.data = i
-		default:
-			val.data = item
-		}
+		val.data = inferStringData(strs.Intern(item), opts)
 	case '-':
 		i, err := strconv.ParseInt(string(msg), 10, 32)
 		if err != nil {
@@ -808,6 +1599,86 @@ func (val *Value) unmarshalRFC7951(
 	return nil
 }
 
+// inferStringData determines the type of the data held in a quoted
+// value that was received without a schema.
+func inferStringData(item string, opts *decodeOpts) interface{} {
+	if len(item) == 0 {
+		return item
+	}
+	c := item[0]
+	switch {
+	case c == '-' && len(item) >= 2:
+		n := item[1]
+		if n < '0' || n > '9' {
+			return item
+		}
+		if strings.Contains(item, ".") {
+			if d, ok := parseDecimal(item, opts); ok {
+				return d
+			}
+			// Without a schema a fractional value can't be told
+			// apart from a string, keep it as written.
+			return item
+		}
+		i, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			//it wasn't an int, use the string
+			return parseBigInt(item, err, opts)
+		}
+		return i
+	case c == '+' && len(item) >= 2:
+		n := item[1]
+		if n < '0' || n > '9' {
+			return item
+		}
+		if strings.Contains(item, ".") {
+			if d, ok := parseDecimal(item, opts); ok {
+				return d
+			}
+			// Without a schema a fractional value can't be told
+			// apart from a string, keep it as written.
+			return item
+		}
+		i, err := strconv.ParseUint(item[1:], 10, 64)
+		if err != nil {
+			//it wasn't an int, use the string
+			return parseBigInt(item, err, opts)
+		}
+		return i
+	case c >= '0' && c <= '9':
+		if strings.Contains(item, ".") {
+			if d, ok := parseDecimal(item, opts); ok {
+				return d
+			}
+			// Without a schema a fractional value can't be told
+			// apart from a string, keep it as written.
+			return item
+		}
+		i, err := strconv.ParseUint(item, 10, 64)
+		if err != nil {
+			//it wasn't an int, use the string
+			return parseBigInt(item, err, opts)
+		}
+		return i
+	default:
+		return item
+	}
+}
+
+// parseBigInt returns item as a BigInt if it was an integer that was out
+// of range for 64 bits and big integer parsing was requested, otherwise
+// the string is returned unchanged.
+func parseBigInt(item string, err error, opts *decodeOpts) interface{} {
+	if !opts.bigInts || !errors.Is(err, strconv.ErrRange) {
+		return item
+	}
+	i, ok := new(big.Int).SetString(item, 10)
+	if !ok {
+		return item
+	}
+	return BigInt{i: i}
+}
+
 var _empty = &Value{data: empty{}}
 
 // Empty returns the constant empty value
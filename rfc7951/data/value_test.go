@@ -5,10 +5,13 @@
 package data
 
 import (
+	"encoding/json"
+	"math/big"
 	"os"
 	"reflect"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/danos/encoding/rfc7951"
 	"jsouthworth.net/go/try"
@@ -58,6 +61,127 @@ func TestValueNew(t *testing.T) {
 	}
 }
 
+type testIdentity string
+
+func (i testIdentity) RFC7951String() string {
+	return string(i)
+}
+
+func (i testIdentity) MarshalRFC7951() ([]byte, error) {
+	return []byte("\"" + string(i) + "\""), nil
+}
+
+// testBinary is registered without a MarshalRFC7951 method.
+type testBinary string
+
+func (b testBinary) RFC7951String() string {
+	return string(b)
+}
+
+type testUnregistered struct{}
+
+func (testUnregistered) RFC7951String() string { return "unregistered" }
+
+func init() {
+	RegisterValueType(reflect.TypeOf(testIdentity("")))
+	RegisterValueType(reflect.TypeOf(testBinary("")))
+}
+
+func TestRegisterValueType(t *testing.T) {
+	t.Run("ValueNew", func(t *testing.T) {
+		val := ValueNew(testIdentity("module-v1:ident"))
+		if _, ok := val.data.(testIdentity); !ok {
+			t.Fatalf("didn't get expected type for value %T",
+				val.data)
+		}
+	})
+	t.Run("Perform", func(t *testing.T) {
+		got := ValueNew(testIdentity("module-v1:ident")).Perform(
+			func(s string) string { return "string" },
+			func(i testIdentity) string { return "identity" },
+		)
+		if got != "identity" {
+			t.Fatalf("expected identity handler, got %v", got)
+		}
+	})
+	t.Run("Marshal", func(t *testing.T) {
+		tree := TreeNew().Assoc("/module-v1:foo",
+			testIdentity("module-v1:ident"))
+		got, err := tree.MarshalRFC7951()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"module-v1:foo":"module-v1:ident"}`
+		if string(got) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+	})
+	t.Run("Marshal without MarshalRFC7951", func(t *testing.T) {
+		tree := TreeNew().
+			Assoc("/module-v1:bin", testBinary("AQID")).
+			Assoc("/module-v1:list", ArrayWith(testBinary("mod:foo")))
+		got, err := tree.MarshalCanonical()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"module-v1:bin":"AQID","module-v1:list":["mod:foo"]}`
+		if string(got) != expected {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+		}
+		var decoded Tree
+		if err := rfc7951.Unmarshal(got, &decoded); err != nil {
+			t.Fatalf("encoding is not valid: %s: %v", got, err)
+		}
+		if decoded.At("/module-v1:bin").ToString() != "AQID" {
+			t.Fatalf("unexpected decoding %s", &decoded)
+		}
+	})
+	t.Run("unregistered", func(t *testing.T) {
+		_, err := try.Apply(ValueNew, testUnregistered{})
+		if err == nil {
+			t.Fatal("expected unregistered type to fail")
+		}
+	})
+	t.Run("no RFC7951String", func(t *testing.T) {
+		_, err := try.Apply(RegisterValueType, reflect.TypeOf(0i))
+		if err == nil {
+			t.Fatal("expected registration to fail")
+		}
+	})
+}
+
+func TestValueMarshalInstanceID(t *testing.T) {
+	// Instance-identifiers nested in objects and arrays were once
+	// written unquoted, they must be encoded as strings.
+	id := InstanceIDNew("/module-v1:bar")
+	cases := []struct {
+		name     string
+		val      *Value
+		expected string
+	}{
+		{"value", ValueNew(id), `"/module-v1:bar"`},
+		{"leaf", TreeNew().Assoc("/module-v1:foo", id).Root(),
+			`{"module-v1:foo":"/module-v1:bar"}`},
+		{"leaf-list", ValueNew(ArrayWith(id, id)),
+			`["/module-v1:bar","/module-v1:bar"]`},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.val.MarshalRFC7951()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+			var decoded interface{}
+			if err := rfc7951.Unmarshal(got, &decoded); err != nil {
+				t.Fatalf("encoding is not valid: %s: %v", got, err)
+			}
+		})
+	}
+}
+
 func TestValuePerform(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -108,6 +232,67 @@ func TestValuePerform(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			name: "default",
+			val:  ValueNew("foo"),
+			fns: []interface{}{
+				func() string {
+					return "default"
+				},
+				func(o *Object) string {
+					return "object"
+				},
+				func(a *Array) string {
+					return "array"
+				},
+			},
+			expected: "default",
+		},
+		{
+			name: "default skipped when matched",
+			val:  ValueNew(ArrayNew()),
+			fns: []interface{}{
+				func(o *Object) string {
+					return "object"
+				},
+				func() string {
+					return "default"
+				},
+				func(a *Array) string {
+					return "array"
+				},
+			},
+			expected: "array",
+		},
+		{
+			name: "first default",
+			val:  ValueNew(10),
+			fns: []interface{}{
+				func(o *Object) string {
+					return "object"
+				},
+				func() string {
+					return "first"
+				},
+				func() string {
+					return "second"
+				},
+			},
+			expected: "first",
+		},
+		{
+			name: "default nil value",
+			val:  nil,
+			fns: []interface{}{
+				func(s String) String {
+					return s
+				},
+				func() string {
+					return "default"
+				},
+			},
+			expected: "default",
+		},
 		// (u)int32 tests
 		{
 			name: "int32",
@@ -294,6 +479,85 @@ func TestValuePerform(t *testing.T) {
 				t.Fatalf("got %T(%v) expected %T(%v)\n",
 					got, got, test.expected, test.expected)
 			}
+			got = PerformSetNew(test.fns...).Apply(test.val)
+			if !equal(got, test.expected) {
+				t.Fatalf("set: got %T(%v) expected %T(%v)\n",
+					got, got, test.expected, test.expected)
+			}
+		})
+	}
+}
+
+func TestValuePerformOK(t *testing.T) {
+	cases := []struct {
+		name     string
+		val      *Value
+		fns      []interface{}
+		expected interface{}
+		ok       bool
+	}{
+		{
+			name: "handler returns nil",
+			val:  ValueNew("foo"),
+			fns: []interface{}{
+				func(string) interface{} { return nil },
+			},
+			expected: nil,
+			ok:       true,
+		},
+		{
+			name: "no match",
+			val:  ValueNew("foo"),
+			fns: []interface{}{
+				func(bool) interface{} { return "bool" },
+			},
+			expected: nil,
+			ok:       false,
+		},
+		{
+			name: "default",
+			val:  ValueNew("foo"),
+			fns: []interface{}{
+				func(bool) interface{} { return "bool" },
+				func() interface{} { return "default" },
+			},
+			expected: "default",
+			ok:       true,
+		},
+		{
+			name: "nil value",
+			val:  nil,
+			fns: []interface{}{
+				func(string) interface{} { return "string" },
+			},
+			expected: nil,
+			ok:       false,
+		},
+		{
+			name: "nil value default",
+			val:  nil,
+			fns: []interface{}{
+				func() interface{} { return "default" },
+			},
+			expected: "default",
+			ok:       true,
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := test.val.PerformOK(test.fns...)
+			if got != test.expected || ok != test.ok {
+				t.Fatalf("expected: %v, %v\ngot: %v, %v\n",
+					test.expected, test.ok, got, ok)
+			}
+			if got := test.val.Perform(test.fns...); got != test.expected {
+				t.Fatalf("expected: %v\ngot: %v\n", test.expected, got)
+			}
+			got, ok = PerformSetNew(test.fns...).ApplyOK(test.val)
+			if got != test.expected || ok != test.ok {
+				t.Fatalf("expected: %v, %v\ngot: %v, %v\n",
+					test.expected, test.ok, got, ok)
+			}
 		})
 	}
 }
@@ -322,6 +586,255 @@ func TestValueRFC7951String(t *testing.T) {
 	}
 }
 
+func TestValueJSON(t *testing.T) {
+	type wrapper struct {
+		Name  string `json:"name" rfc7951:"name"`
+		Value *Value `json:"value" rfc7951:"value"`
+	}
+	cases := []struct {
+		name string
+		val  *Value
+	}{
+		{"int32", ValueNew(int32(-10))},
+		{"uint64", ValueNew(uint64(1 << 40))},
+		{"int64", ValueNew(int64(-1 << 40))},
+		{"string", ValueNew("foo")},
+		{"empty", Empty()},
+		{"null", ValueNew(nil)},
+		{"object", ValueNew(TreeNew().
+			Assoc("/module-v1:container/leaf", int64(-1<<40)).
+			Assoc("/module-v1:leaf-list", ArrayWith(1, 2)).
+			Root())},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			in := wrapper{Name: test.name, Value: test.val}
+			got, err := json.Marshal(&in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := rfc7951.Marshal(&in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(expected) {
+				t.Fatalf("expected: %s\ngot: %s\n", expected, got)
+			}
+			var out wrapper
+			err = json.Unmarshal(got, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.val.IsNull() {
+				// encoding/json decodes null as a nil pointer.
+				if out.Value != nil {
+					t.Fatalf("expected: nil\ngot: %s\n", out.Value)
+				}
+				return
+			}
+			if !equal(out.Value, test.val) {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.val, out.Value)
+			}
+		})
+	}
+}
+
+func TestValueUnmarshalQuotedNumbers(t *testing.T) {
+	cases := []struct {
+		msg      string
+		expected *Value
+	}{
+		{`"1234"`, ValueNew(uint64(1234))},
+		{`"-1234"`, ValueNew(int64(-1234))},
+		{`"+1234"`, ValueNew(uint64(1234))},
+		{`"1.5"`, ValueNew("1.5")},
+		{`"-1.5"`, ValueNew("-1.5")},
+		{`"+2.3"`, ValueNew("+2.3")},
+		{`"1.50"`, ValueNew("1.50")},
+		{`"10.0"`, ValueNew("10.0")},
+		{`"1.2.3"`, ValueNew("1.2.3")},
+		{`"-foo"`, ValueNew("-foo")},
+		{`""`, ValueNew("")},
+	}
+	for _, test := range cases {
+		t.Run(test.msg, func(t *testing.T) {
+			var got Value
+			err := got.UnmarshalRFC7951([]byte(test.msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equal(&got, test.expected) {
+				t.Fatalf("expected: %T(%v)\ngot: %T(%v)\n",
+					test.expected.data, test.expected,
+					got.data, &got)
+			}
+		})
+	}
+}
+
+func TestValueEqualNumeric(t *testing.T) {
+	cases := []struct {
+		name     string
+		a, b     *Value
+		expected bool
+	}{
+		{"uint32/uint64", ValueNew(2), ValueNew(uint64(2)), true},
+		{"int32/int64", ValueNew(int32(-2)), ValueNew(int64(-2)), true},
+		{"uint64/float64", ValueNew(uint64(2)), ValueNew(2.0), true},
+		{"int64/float64", ValueNew(int64(-2)), ValueNew(-2.0), true},
+		{"different", ValueNew(2), ValueNew(uint64(3)), false},
+		{"fraction", ValueNew(2), ValueNew(2.5), false},
+		{"max-uint64", ValueNew(uint64(1<<64 - 1)),
+			ValueNew(float64(1<<64 - 1)), false},
+		{"string", ValueNew(2), ValueNew("2"), false},
+		{"null", ValueNew(nil), ValueNew(nil), false},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.a.EqualNumeric(test.b)
+			if got != test.expected {
+				t.Fatalf("got %v expected %v\n",
+					got, test.expected)
+			}
+			if test.b.EqualNumeric(test.a) != got {
+				t.Fatal("EqualNumeric is not symmetric")
+			}
+		})
+	}
+	if ValueNew(int64(2)).Equal(ValueNew(2.0)) {
+		t.Fatal("Equal should compare storage types")
+	}
+}
+
+func TestValueInRange(t *testing.T) {
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+	cases := []struct {
+		name         string
+		val          *Value
+		smin, smax   int64
+		umin, umax   uint64
+		expectedInt  bool
+		expectedUint bool
+	}{
+		{name: "uint8", val: ValueNew(255),
+			smin: 0, smax: 255, umin: 0, umax: 255,
+			expectedInt: true, expectedUint: true},
+		{name: "uint8 overflow", val: ValueNew(256),
+			smin: 0, smax: 255, umin: 0, umax: 255},
+		{name: "vlan", val: ValueNew(uint64(4094)),
+			smin: 1, smax: 4094, umin: 1, umax: 4094,
+			expectedInt: true, expectedUint: true},
+		{name: "vlan underflow", val: ValueNew(0),
+			smin: 1, smax: 4094, umin: 1, umax: 4094},
+		{name: "negative", val: ValueNew(int64(-5)),
+			smin: -10, smax: 10, umin: 0, umax: 10,
+			expectedInt: true},
+		{name: "max uint64", val: ValueNew(uint64(1<<64 - 1)),
+			smin: 0, smax: 1<<63 - 1, umin: 0, umax: 1<<64 - 1,
+			expectedUint: true},
+		{name: "big", val: ValueNew(BigIntNew(huge)),
+			smin: -1 << 63, smax: 1<<63 - 1, umin: 0, umax: 1<<64 - 1},
+		{name: "float", val: ValueNew(1.0),
+			smin: 0, smax: 10, umin: 0, umax: 10},
+		{name: "string", val: ValueNew("5"),
+			smin: 0, smax: 10, umin: 0, umax: 10},
+		{name: "null", val: ValueNew(nil),
+			smin: 0, smax: 10, umin: 0, umax: 10},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.val.InRangeInt64(test.smin, test.smax)
+			if got != test.expectedInt {
+				t.Fatalf("InRangeInt64: got %v expected %v\n",
+					got, test.expectedInt)
+			}
+			got = test.val.InRangeUint64(test.umin, test.umax)
+			if got != test.expectedUint {
+				t.Fatalf("InRangeUint64: got %v expected %v\n",
+					got, test.expectedUint)
+			}
+		})
+	}
+}
+
+func TestValueNumericEqual(t *testing.T) {
+	cases := []struct {
+		name     string
+		a, b     *Value
+		expected bool
+	}{
+		{"uint32/int64", ValueNew(5), ValueNew(int64(5)), true},
+		{"int32/float64", ValueNew(int32(-5)), ValueNew(-5.0), true},
+		{"different", ValueNew(5), ValueNew(int64(6)), false},
+		{"string", ValueNew("foo"), ValueNew("foo"), true},
+		{"string/number", ValueNew("5"), ValueNew(5), false},
+		{"null", ValueNew(nil), ValueNew(nil), true},
+		{"object",
+			ValueNew(ObjectWith(
+				PairNew("m:a", 5),
+				PairNew("m:b", ArrayWith(1, "x")))),
+			ValueNew(ObjectWith(
+				PairNew("m:a", uint64(5)),
+				PairNew("m:b", ArrayWith(int64(1), "x")))),
+			true},
+		{"object/missing-key",
+			ValueNew(ObjectWith(PairNew("m:a", 5))),
+			ValueNew(ObjectWith(PairNew("m:b", 5))),
+			false},
+		{"object/extra-key",
+			ValueNew(ObjectWith(PairNew("m:a", 5))),
+			ValueNew(ObjectWith(PairNew("m:a", 5), PairNew("m:b", 5))),
+			false},
+		{"array/different-length",
+			ValueNew(ArrayWith(1, 2)), ValueNew(ArrayWith(1)), false},
+		{"array/different-value",
+			ValueNew(ArrayWith(1, 2)), ValueNew(ArrayWith(1, 3.0)), false},
+		{"object/array",
+			ValueNew(ObjectNew()), ValueNew(ArrayNew()), false},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.a.DeepNumericEqual(test.b)
+			if got != test.expected {
+				t.Fatalf("got %v expected %v\n",
+					got, test.expected)
+			}
+			if test.b.DeepNumericEqual(test.a) != got {
+				t.Fatal("DeepNumericEqual is not symmetric")
+			}
+		})
+	}
+	if ValueNew(5).Equal(ValueNew(5.0)) {
+		t.Fatal("Equal should remain strict")
+	}
+}
+
+func TestValueClone(t *testing.T) {
+	orig := TreeFromObject(TESTOBJ).Root()
+	clone := orig.Clone()
+	if !equal(orig, clone) {
+		t.Fatalf("expected: %s\ngot: %s\n", orig, clone)
+	}
+	origObj, cloneObj := orig.AsObject(), clone.AsObject()
+	if origObj == cloneObj || origObj.store == cloneObj.store {
+		t.Fatal("cloned object shares storage with the original")
+	}
+	origArr := origObj.At("module-v1:leaf-list").AsArray()
+	cloneArr := cloneObj.At("module-v1:leaf-list").AsArray()
+	if origArr == cloneArr || origArr.store == cloneArr.store {
+		t.Fatal("cloned array shares storage with the original")
+	}
+	if cloneArr.module != origArr.module {
+		t.Fatalf("expected: module %s\ngot: %s\n",
+			origArr.module, cloneArr.module)
+	}
+	leaf := ValueNew("foo")
+	if leaf.Clone().data != leaf.data {
+		t.Fatal("cloned leaf should share its data")
+	}
+}
+
 func TestValueConversions(t *testing.T) {
 	// Tree conversion
 	t.Run("ToTree", func(t *testing.T) {
@@ -497,6 +1010,82 @@ func TestValueConversions(t *testing.T) {
 		})
 	})
 
+	// binary conversion
+	t.Run("AsBytes", func(t *testing.T) {
+		t.Run("Base64", func(t *testing.T) {
+			v := ValueNew("Zm9vYmFy")
+			if string(v.AsBytes()) != "foobar" {
+				t.Fatal("didn't get expected result")
+			}
+		})
+		t.Run("Invalid", func(t *testing.T) {
+			v := ValueNew("not base64!")
+			_, err := try.Apply(v.AsBytes)
+			if err == nil {
+				t.Fatal("conversion should have failed")
+			}
+		})
+		t.Run("Other", func(t *testing.T) {
+			v := ValueNew(1)
+			_, err := try.Apply(v.AsBytes)
+			if err == nil {
+				t.Fatal("conversion should have failed")
+			}
+		})
+	})
+	t.Run("IsBytes", func(t *testing.T) {
+		t.Run("Base64", func(t *testing.T) {
+			v := ValueNew("Zm9vYmFy")
+			if !v.IsBytes() {
+				t.Fatal("Value is valid base64")
+			}
+		})
+		t.Run("Invalid", func(t *testing.T) {
+			v := ValueNew("Zm9vYmF")
+			if v.IsBytes() {
+				t.Fatal("Value is not valid base64")
+			}
+		})
+		t.Run("Other", func(t *testing.T) {
+			v := ValueNew(1)
+			if v.IsBytes() {
+				t.Fatal("Value is not a string")
+			}
+		})
+	})
+	t.Run("ToBytes", func(t *testing.T) {
+		t.Run("Base64", func(t *testing.T) {
+			v := ValueNew("Zm9vYmFy")
+			if string(v.ToBytes()) != "foobar" {
+				t.Fatal("didn't get expected result")
+			}
+		})
+		t.Run("Other", func(t *testing.T) {
+			v := ValueNew(1)
+			if v.ToBytes() != nil {
+				t.Fatal("Value should not be binary")
+			}
+		})
+		t.Run("Default", func(t *testing.T) {
+			v := ValueNew("not base64!")
+			o := v.ToBytes([]byte("bar"))
+			if string(o) != "bar" {
+				t.Fatal("should have gotten default")
+			}
+		})
+		t.Run("RoundTrip", func(t *testing.T) {
+			v := ValueNew("Zm9vYmFy")
+			_ = v.AsBytes()
+			b, err := v.MarshalRFC7951()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != `"Zm9vYmFy"` {
+				t.Fatalf("unexpected encoding %s", b)
+			}
+		})
+	})
+
 	// int32 conversion
 	t.Run("AsInt32", func(t *testing.T) {
 		t.Run("Int32", func(t *testing.T) {
@@ -1062,3 +1651,417 @@ func ExampleValue_ToData() {
 	// 6
 	// 7
 }
+
+func TestValueRFC7951StringFloat(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    *Value
+		prec     int
+		expected string
+	}{
+		{"shortest", ValueNew(10.0), -1, "10"},
+		{"one-digit", ValueNew(10.0), 1, "10.0"},
+		{"padded", ValueNew(1.5), 3, "1.500"},
+		{"rounded", ValueNew(1.25), 1, "1.2"},
+		{"negative", ValueNew(-0.5), 2, "-0.50"},
+		{"integer", ValueNew(10), 2, "10"},
+		{"string", ValueNew("1.5"), 2, "1.5"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.value.RFC7951StringFloat(test.prec)
+			if got != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+			if test.prec == -1 && got != test.value.RFC7951String() {
+				t.Fatalf("expected: %s\ngot: %s\n",
+					test.value.RFC7951String(), got)
+			}
+		})
+	}
+}
+
+func TestValueIsScalar(t *testing.T) {
+	cases := []struct {
+		name   string
+		value  *Value
+		scalar bool
+	}{
+		{"object", ValueNew(ObjectNew()), false},
+		{"array", ValueNew(ArrayWith(1)), false},
+		{"empty-array", ValueNew(ArrayNew()), false},
+		{"string", ValueNew("foo"), true},
+		{"int32", ValueNew(-1), true},
+		{"uint64", ValueNew(uint64(1)), true},
+		{"float", ValueNew(1.5), true},
+		{"bool", ValueNew(true), true},
+		{"empty", Empty(), true},
+		{"null", ValueNew(nil), true},
+		{"instance-id", ValueNew(InstanceIDNew("/m:a")), true},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			if test.value.IsScalar() != test.scalar {
+				t.Fatalf("expected IsScalar: %v\ngot: %v\n",
+					test.scalar, test.value.IsScalar())
+			}
+			if test.value.IsContainer() == test.scalar {
+				t.Fatalf("expected IsContainer: %v\ngot: %v\n",
+					!test.scalar, test.value.IsContainer())
+			}
+		})
+	}
+}
+
+func TestValueTime(t *testing.T) {
+	zone := time.FixedZone("", -5*60*60)
+	cases := []struct {
+		name     string
+		time     time.Time
+		expected string
+	}{
+		{"utc", time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC),
+			"2020-03-04T05:06:07Z"},
+		{"offset", time.Date(2020, 3, 4, 5, 6, 7, 0, zone),
+			"2020-03-04T05:06:07-05:00"},
+		{"fraction", time.Date(2020, 3, 4, 5, 6, 7, 500000000, time.UTC),
+			"2020-03-04T05:06:07.5Z"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			v := ValueNew(test.time)
+			if !v.IsString() || v.AsString() != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, v)
+			}
+			msg, err := v.MarshalRFC7951()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(msg) != `"`+test.expected+`"` {
+				t.Fatalf("expected: %q\ngot: %s\n", test.expected, msg)
+			}
+			var decoded Value
+			if err := decoded.UnmarshalRFC7951(msg); err != nil {
+				t.Fatal(err)
+			}
+			got, err := decoded.AsTime()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(test.time) || !decoded.IsTime() {
+				t.Fatalf("expected: %s\ngot: %s\n", test.time, got)
+			}
+		})
+	}
+	t.Run("invalid", func(t *testing.T) {
+		def := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, v := range []*Value{ValueNew("yesterday"), ValueNew(10)} {
+			if _, err := v.AsTime(); err == nil {
+				t.Fatalf("expected an error for %s", v)
+			}
+			if v.IsTime() {
+				t.Fatalf("%s is not a time", v)
+			}
+			if !v.ToTime(def).Equal(def) || !v.ToTime().IsZero() {
+				t.Fatalf("expected the default for %s", v)
+			}
+		}
+	})
+}
+
+func TestValueDuration(t *testing.T) {
+	d := 90*time.Minute + 500*time.Millisecond
+	v := ValueNew(d)
+	if v.AsString() != "1h30m0.5s" {
+		t.Fatalf("expected: %s\ngot: %s\n", "1h30m0.5s", v)
+	}
+	got, err := v.AsDuration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != d || !v.IsDuration() || v.ToDuration() != d {
+		t.Fatalf("expected: %s\ngot: %s\n", d, got)
+	}
+	invalid := ValueNew("soon")
+	if _, err := invalid.AsDuration(); err == nil || invalid.IsDuration() {
+		t.Fatal("expected an invalid duration")
+	}
+	if invalid.ToDuration(time.Second) != time.Second ||
+		invalid.ToDuration() != 0 {
+		t.Fatal("expected the default duration")
+	}
+}
+
+func TestValueGet(t *testing.T) {
+	val := ValueNew(TESTOBJ)
+	tests := []struct {
+		name     string
+		steps    []interface{}
+		expected *Value
+	}{
+		{"no steps", nil, val},
+		{"leaf", []interface{}{"module-v1:leaf"}, ValueNew("foo")},
+		{"implicit module",
+			[]interface{}{"module-v1:container", "containerleaf"},
+			ValueNew("foo")},
+		{"list entry",
+			[]interface{}{"module-v1:list", 1, "objleaf"},
+			ValueNew("baz")},
+		{"negative index",
+			[]interface{}{"module-v1:list", -1, "key"},
+			ValueNew("quux")},
+		{"leaf-list", []interface{}{"module-v1:leaf-list", 2}, ValueNew(3)},
+		{"missing key", []interface{}{"module-v1:missing", "foo"}, nil},
+		{"out of range", []interface{}{"module-v1:list", 10, "key"}, nil},
+		{"index into object",
+			[]interface{}{"module-v1:container", 0}, nil},
+		{"key into array", []interface{}{"module-v1:list", "key"}, nil},
+		{"key into leaf",
+			[]interface{}{"module-v1:leaf", "foo", "bar"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := val.Get(test.steps...)
+			if (got == nil) != (test.expected == nil) ||
+				(got != nil && !got.Equal(test.expected)) {
+				t.Fatalf("expected: %v\ngot: %v\n", test.expected, got)
+			}
+		})
+	}
+	t.Run("nil", func(t *testing.T) {
+		if got := (*Value)(nil).Get("foo", 0); got != nil {
+			t.Fatalf("expected nil, got: %v", got)
+		}
+	})
+	t.Run("invalid step", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected a panic for an invalid step")
+			}
+		}()
+		val.Get(1.5)
+	})
+}
+
+func TestValueAsE(t *testing.T) {
+	id := InstanceIDNew("/module-v1:leaf")
+	call := func(fn interface{}) (interface{}, error) {
+		out := reflect.ValueOf(fn).Call(nil)
+		err, _ := out[1].Interface().(error)
+		return out[0].Interface(), err
+	}
+	cases := []struct {
+		name     string
+		val      *Value
+		fn       func(*Value) interface{}
+		expected interface{}
+		err      string
+	}{
+		{"object", ValueNew(ObjectNew()),
+			func(v *Value) interface{} { return v.AsObjectE },
+			ObjectNew(), ""},
+		{"object from string", ValueNew("foo"),
+			func(v *Value) interface{} { return v.AsObjectE },
+			(*Object)(nil), "cannot use string value as object"},
+		{"array", ValueNew(ArrayWith(1)),
+			func(v *Value) interface{} { return v.AsArrayE },
+			ArrayWith(1), ""},
+		{"array from object", ValueNew(ObjectNew()),
+			func(v *Value) interface{} { return v.AsArrayE },
+			(*Array)(nil), "cannot use object value as array"},
+		{"string", ValueNew("foo"),
+			func(v *Value) interface{} { return v.AsStringE },
+			"foo", ""},
+		{"string from number", ValueNew(1),
+			func(v *Value) interface{} { return v.AsStringE },
+			"", "cannot use uint32 value as string"},
+		{"bytes", ValueNew("Zm9v"),
+			func(v *Value) interface{} { return v.AsBytesE },
+			[]byte("foo"), ""},
+		{"bytes from bool", ValueNew(true),
+			func(v *Value) interface{} { return v.AsBytesE },
+			[]byte(nil), "cannot use boolean value as string"},
+		{"int32", ValueNew(-5),
+			func(v *Value) interface{} { return v.AsInt32E },
+			int32(-5), ""},
+		{"int32 from string", ValueNew("-5"),
+			func(v *Value) interface{} { return v.AsInt32E },
+			int32(0), "cannot use string value as int32"},
+		{"uint32", ValueNew(5),
+			func(v *Value) interface{} { return v.AsUint32E },
+			uint32(5), ""},
+		{"uint32 from empty", ValueNew(Empty()),
+			func(v *Value) interface{} { return v.AsUint32E },
+			uint32(0), "cannot use empty value as uint32"},
+		{"int64", ValueNew(int64(-5)),
+			func(v *Value) interface{} { return v.AsInt64E },
+			int64(-5), ""},
+		{"int64 from null", ValueNew(nil),
+			func(v *Value) interface{} { return v.AsInt64E },
+			int64(0), "cannot use null value as int64"},
+		{"uint64", ValueNew(uint64(5)),
+			func(v *Value) interface{} { return v.AsUint64E },
+			uint64(5), ""},
+		{"uint64 from array", ValueNew(ArrayNew()),
+			func(v *Value) interface{} { return v.AsUint64E },
+			uint64(0), "cannot use array value as uint64"},
+		{"float", ValueNew(1.5),
+			func(v *Value) interface{} { return v.AsFloatE },
+			1.5, ""},
+		{"float from bool", ValueNew(false),
+			func(v *Value) interface{} { return v.AsFloatE },
+			float64(0), "cannot use boolean value as float64"},
+		{"boolean", ValueNew(true),
+			func(v *Value) interface{} { return v.AsBooleanE },
+			true, ""},
+		{"boolean from empty", ValueNew(Empty()),
+			func(v *Value) interface{} { return v.AsBooleanE },
+			true, ""},
+		{"boolean from string", ValueNew("true"),
+			func(v *Value) interface{} { return v.AsBooleanE },
+			false, "cannot use string value as boolean"},
+		{"instance-identifier", ValueNew(id),
+			func(v *Value) interface{} { return v.AsInstanceIDE },
+			id, ""},
+		{"instance-identifier from string", ValueNew("/module-v1:leaf"),
+			func(v *Value) interface{} { return v.AsInstanceIDE },
+			id, ""},
+		{"decimal", ValueNew(1.5),
+			func(v *Value) interface{} { return v.AsDecimalE },
+			mustDecimal(t, "1.5"), ""},
+		{"decimal from bool", ValueNew(true),
+			func(v *Value) interface{} { return v.AsDecimalE },
+			Decimal{}, "cannot use boolean value as decimal"},
+		{"instance-identifier from number", ValueNew(1),
+			func(v *Value) interface{} { return v.AsInstanceIDE },
+			(*InstanceID)(nil),
+			"cannot use uint32 value as instance-identifier"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := call(test.fn(test.val))
+			if test.err != "" {
+				kerr, isKindError := err.(*KindError)
+				if !isKindError || kerr.Error() != test.err {
+					t.Fatalf("expected: %s\ngot: %v\n",
+						test.err, err)
+				}
+				if kerr.Actual != test.val.Type() {
+					t.Fatalf("expected: %s\ngot: %s\n",
+						test.val.Type(), kerr.Actual)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.expected) &&
+				!equal(got, test.expected) {
+				t.Fatalf("expected: %v\ngot: %v\n",
+					test.expected, got)
+			}
+		})
+	}
+	t.Run("invalid base64", func(t *testing.T) {
+		_, err := ValueNew("!!").AsBytesE()
+		if err == nil {
+			t.Fatal("expected an error for invalid base64")
+		}
+	})
+	t.Run("invalid instance-identifier", func(t *testing.T) {
+		_, err := ValueNew("not an id").AsInstanceIDE()
+		if err == nil {
+			t.Fatal("expected an error for an invalid instance-identifier")
+		}
+	})
+}
+
+func TestPerformSet(t *testing.T) {
+	set := PerformSetNew(
+		func(o *Object) string { return "object" },
+		func(i int32) string { return "int32" },
+		func(s String) string { return "string " + string(s) },
+		func() string { return "default" },
+	)
+	cases := []struct {
+		val      *Value
+		expected string
+	}{
+		{ValueNew(ObjectNew()), "object"},
+		{ValueNew(5), "int32"},
+		{ValueNew(uint32(1) << 31), "string 2147483648"},
+		{ValueNew(true), "string true"},
+		{nil, "default"},
+	}
+	for _, test := range cases {
+		if got := set.Apply(test.val); got != test.expected {
+			t.Fatalf("expected: %s\ngot: %v\n", test.expected, got)
+		}
+	}
+}
+
+func benchmarkPerformValues() []*Value {
+	vals := make([]*Value, 0, 1000)
+	for i := 0; i < cap(vals)/4; i++ {
+		vals = append(vals, ValueNew(i), ValueNew(int64(-i)),
+			ValueNew("foo"), ValueNew(ObjectNew()))
+	}
+	return vals
+}
+
+var benchmarkPerformFns = []interface{}{
+	func(o *Object) int { return o.Length() },
+	func(a *Array) int { return a.Length() },
+	func(i int32) int { return int(i) },
+	func(i int64) int { return int(i) },
+	func(s String) int { return len(s) },
+}
+
+func BenchmarkValuePerform(b *testing.B) {
+	vals := benchmarkPerformValues()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, v := range vals {
+			v.Perform(benchmarkPerformFns...)
+		}
+	}
+}
+
+func BenchmarkPerformSetApply(b *testing.B) {
+	vals := benchmarkPerformValues()
+	set := PerformSetNew(benchmarkPerformFns...)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, v := range vals {
+			set.Apply(v)
+		}
+	}
+}
+
+func TestValueMarshalRFC7951Indent(t *testing.T) {
+	cases := []struct {
+		name     string
+		val      *Value
+		expected string
+	}{
+		{"object", ValueNew(ObjectWith(
+			PairNew("module-v1:list", ArrayWith(1, "two")))),
+			"{\n>  \"module-v1:list\": [\n>    1,\n>    \"two\"\n>  ]\n>}"},
+		{"array", ValueNew(ArrayWith(true, ObjectNew())),
+			"[\n>  true,\n>  {}\n>]"},
+		{"empty array", ValueNew(ArrayNew()), "[]"},
+		{"string", ValueNew("foo"), `"foo"`},
+		{"uint64", ValueNew(uint64(5)), `"5"`},
+		{"empty", Empty(), "[null]"},
+		{"null", ValueNew(nil), "null"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.val.MarshalRFC7951Indent(">", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.expected {
+				t.Fatalf("expected: %s\ngot: %s\n", test.expected, got)
+			}
+		})
+	}
+}
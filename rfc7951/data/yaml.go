@@ -0,0 +1,258 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"jsouthworth.net/go/immutable/hashmap"
+	"jsouthworth.net/go/immutable/vector"
+)
+
+// MarshalYAML implements the Marshaler interface shared by
+// gopkg.in/yaml.v2 and gopkg.in/yaml.v3, so a Value may be encoded by
+// either package without this package depending on them. The value is
+// returned as native data for the YAML encoder following the same model
+// as RFC7951, objects become maps whose keys are module qualified when
+// the module differs from that of the parent and the empty value
+// becomes [null].
+//
+// Unlike RFC7951, which quotes 64 bit integers, all integers that fit
+// in 64 bits are encoded as plain YAML integers and floats as plain
+// YAML floats. Integers too large for 64 bits, instance-identifiers and
+// registered types are encoded as strings. Packages such as
+// sigs.k8s.io/yaml that convert through encoding/json use MarshalJSON
+// instead and so quote 64 bit integers as RFC7951 does.
+func (val *Value) MarshalYAML() (interface{}, error) {
+	return val.marshalYAML("")
+}
+
+func (val *Value) marshalYAML(module string) (interface{}, error) {
+	switch v := val.data.(type) {
+	case *Object:
+		return v.marshalYAML(module)
+	case *Array:
+		return v.marshalYAML(module)
+	case empty:
+		return []interface{}{nil}, nil
+	case nil, bool, string, float64,
+		int32, uint32, int64, uint64:
+		return v, nil
+	case interface {
+		RFC7951String() string
+	}:
+		return v.RFC7951String(), nil
+	default:
+		return nil, fmt.Errorf("cannot marshal value of type %T", v)
+	}
+}
+
+func (obj *Object) marshalYAML(module string) (interface{}, error) {
+	out := make(map[string]interface{}, obj.Length())
+	var err error
+	obj.Range(func(k string, v *Value) bool {
+		mod, key := obj.parseKey(k)
+		if mod == module {
+			k = key
+		}
+		out[k], err = v.marshalYAML(mod)
+		return err == nil
+	})
+	return out, err
+}
+
+func (arr *Array) marshalYAML(module string) (interface{}, error) {
+	out := make([]interface{}, 0, arr.Length())
+	var err error
+	arr.Range(func(_ int, v *Value) bool {
+		var data interface{}
+		data, err = v.marshalYAML(module)
+		out = append(out, data)
+		return err == nil
+	})
+	return out, err
+}
+
+// UnmarshalYAML implements the obsolete Unmarshaler interface of
+// gopkg.in/yaml.v2, which gopkg.in/yaml.v3 still supports. The decoded
+// YAML is interpreted in the same way as RFC7951, strings are subject
+// to the same inference of numeric types as quoted RFC7951 values and
+// [null] is the empty value.
+//
+// YAML doesn't distinguish between the widths of integers, those that
+// fit in 32 bits are stored as 32 bit integers and larger ones as 64
+// bit integers. Floats with an integral value may be decoded as
+// integers by the YAML package. The As* conversions behave the same
+// either way but Equal, unlike EqualNumeric, may not consider a value
+// equal to the one that was marshalled.
+func (val *Value) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var in interface{}
+	err := unmarshal(&in)
+	if err != nil {
+		return err
+	}
+	opts := &decodeOpts{}
+	strs, vals, release := opts.interners()
+	defer release()
+	dec := &yamlDecoder{
+		strs: strs,
+		vals: vals,
+		opts: opts,
+	}
+	data, err := dec.decode(in, "")
+	if err != nil {
+		return err
+	}
+	val.data = data
+	return nil
+}
+
+type yamlDecoder struct {
+	strs *stringInterner
+	vals *valueInterner
+	opts *decodeOpts
+}
+
+func (d *yamlDecoder) decode(in interface{}, module string) (interface{}, error) {
+	switch v := in.(type) {
+	case nil, bool, float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int, int8, int16, int32, int64:
+		return yamlInt(reflect.ValueOf(v).Int()), nil
+	case uint, uint8, uint16, uint32, uint64:
+		return yamlUint(reflect.ValueOf(v).Uint()), nil
+	case string:
+		return inferStringData(d.strs.Intern(v), d.opts), nil
+	case []interface{}:
+		return d.decodeArray(v, module)
+	case map[string]interface{}:
+		// gopkg.in/yaml.v3 decodes mappings with string keys.
+		return d.decodeObject(module, func(fn func(k, elem interface{})) {
+			for k, elem := range v {
+				fn(k, elem)
+			}
+		})
+	case map[interface{}]interface{}:
+		// gopkg.in/yaml.v2 decodes all mappings this way.
+		return d.decodeObject(module, func(fn func(k, elem interface{})) {
+			for k, elem := range v {
+				fn(k, elem)
+			}
+		})
+	default:
+		return nil, fmt.Errorf("yaml: cannot unmarshal %T", v)
+	}
+}
+
+// yamlInt stores an integer in 32 bits if it fits, as the RFC7951
+// decoder does for unquoted numbers.
+func yamlInt(i int64) interface{} {
+	switch {
+	case i >= 0:
+		return yamlUint(uint64(i))
+	case i >= math.MinInt32:
+		return int32(i)
+	default:
+		return i
+	}
+}
+
+func yamlUint(u uint64) interface{} {
+	if u <= math.MaxUint32 {
+		return uint32(u)
+	}
+	return u
+}
+
+func (d *yamlDecoder) decodeArray(in []interface{}, module string) (interface{}, error) {
+	arr := arrayNew()
+	arr.module = module
+	var err error
+	arr.store = arr.store.Transform(
+		func(store *vector.TVector) *vector.TVector {
+			for _, elem := range in {
+				var data interface{}
+				data, err = d.decode(elem, arr.module)
+				if err != nil {
+					return store
+				}
+				val := arr.adaptValue(&Value{data: data})
+				val = d.vals.Intern(val)
+				store = store.Append(val)
+			}
+			return store
+		})
+	if err != nil {
+		return nil, err
+	}
+	if arr.Length() == 1 && equal(arr.At(0), ValueNew(nil)) {
+		return _empty.data, nil
+	}
+	return arr, nil
+}
+
+func (d *yamlDecoder) decodeObject(
+	module string, rangeMembers func(func(k, elem interface{})),
+) (interface{}, error) {
+	obj := objectNew()
+	obj.module = module
+	var err error
+	obj.store = obj.store.Transform(
+		func(store *hashmap.TMap) *hashmap.TMap {
+			rangeMembers(func(key, elem interface{}) {
+				if err != nil {
+					return
+				}
+				k, isString := key.(string)
+				if !isString {
+					err = errors.New("yaml: object keys must be strings")
+					return
+				}
+				module, _ := obj.parseKey(k)
+				module = d.strs.Intern(module)
+				var data interface{}
+				data, err = d.decode(elem, module)
+				if err != nil {
+					return
+				}
+				k, v := obj.adaptValue(k, &Value{data: data})
+				k = d.strs.Intern(k)
+				v = d.vals.Intern(v)
+				store = store.Assoc(k, v)
+			})
+			return store
+		})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// MarshalYAML returns the Tree as native data for a YAML encoder, see
+// (*Value).MarshalYAML for details of the encoding.
+func (t *Tree) MarshalYAML() (interface{}, error) {
+	return t.Root().MarshalYAML()
+}
+
+// UnmarshalYAML replaces the contents of the Tree with the decoded
+// YAML, which must contain an object. See (*Value).UnmarshalYAML.
+func (t *Tree) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var val Value
+	err := val.UnmarshalYAML(unmarshal)
+	if err != nil {
+		return err
+	}
+	if !val.IsObject() {
+		return errors.New("yaml: tree must be an object")
+	}
+	t.root = &val
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2020, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package data
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// yamlUnmarshaler returns an unmarshal function like those passed to
+// UnmarshalYAML by the YAML packages that produces the native data in.
+func yamlUnmarshaler(in interface{}) func(interface{}) error {
+	return func(out interface{}) error {
+		*out.(*interface{}) = in
+		return nil
+	}
+}
+
+func TestValueMarshalYAML(t *testing.T) {
+	big, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tree := TreeNew().
+		Assoc("/module-v1:container/name", "foo").
+		Assoc("/module-v1:container/module-v2:aug", int32(-5)).
+		Assoc("/module-v1:container/count", uint64(1)<<40).
+		Assoc("/module-v1:container/offset", int64(-1)<<40).
+		Assoc("/module-v1:container/ratio", 0.5).
+		Assoc("/module-v1:container/big", big).
+		Assoc("/module-v1:container/ref",
+			InstanceIDNew("/module-v1:container/name")).
+		Assoc("/module-v1:container/present", Empty()).
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 2)).
+		Assoc("/module-v1:null", nil)
+	got, err := tree.MarshalYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"module-v1:container": map[string]interface{}{
+			"name":          "foo",
+			"module-v2:aug": int32(-5),
+			"count":         uint64(1) << 40,
+			"offset":        int64(-1) << 40,
+			"ratio":         0.5,
+			"big":           "123456789012345678901234567890",
+			"ref":           "/module-v1:container/name",
+			"present":       []interface{}{nil},
+		},
+		"module-v1:leaf-list": []interface{}{uint32(1), uint32(2)},
+		"module-v1:null":      nil,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %v\ngot: %v\n", expected, got)
+	}
+}
+
+func TestValueUnmarshalYAML(t *testing.T) {
+	expected := TreeNew().
+		Assoc("/module-v1:container/name", "foo").
+		Assoc("/module-v1:container/module-v2:aug", -5).
+		Assoc("/module-v1:container/count", uint64(1)<<40).
+		Assoc("/module-v1:container/offset", int64(-1)<<40).
+		Assoc("/module-v1:container/quoted", uint64(12)).
+		Assoc("/module-v1:container/ratio", 0.5).
+		Assoc("/module-v1:container/present", Empty()).
+		Assoc("/module-v1:leaf-list", ArrayWith(1, 2))
+	t.Run("yaml.v2", func(t *testing.T) {
+		in := map[interface{}]interface{}{
+			"module-v1:container": map[interface{}]interface{}{
+				"name":          "foo",
+				"module-v2:aug": -5,
+				"count":         1 << 40,
+				"offset":        -1 << 40,
+				"quoted":        "12",
+				"ratio":         0.5,
+				"present":       []interface{}{nil},
+			},
+			"module-v1:leaf-list": []interface{}{1, 2},
+		}
+		var got Tree
+		err := got.UnmarshalYAML(yamlUnmarshaler(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(&got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, &got)
+		}
+		aug := got.At("/module-v1:container/module-v2:aug")
+		if aug.AsInt64() != -5 || aug.AsInt32() != -5 {
+			t.Fatalf("expected: -5\ngot: %s\n", aug)
+		}
+	})
+	t.Run("yaml.v3", func(t *testing.T) {
+		in := map[string]interface{}{
+			"module-v1:container": map[string]interface{}{
+				"name":          "foo",
+				"module-v2:aug": -5,
+				"count":         1 << 40,
+				"offset":        -1 << 40,
+				"quoted":        "12",
+				"ratio":         0.5,
+				"present":       []interface{}{nil},
+			},
+			"module-v1:leaf-list": []interface{}{1, 2},
+		}
+		var got Tree
+		err := got.UnmarshalYAML(yamlUnmarshaler(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(&got, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, &got)
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		data, err := expected.MarshalYAML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Tree
+		err = got.UnmarshalYAML(yamlUnmarshaler(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		quoted := got.At("/module-v1:container/quoted")
+		if !quoted.EqualNumeric(ValueNew(12)) {
+			t.Fatalf("expected: 12\ngot: %s\n", quoted)
+		}
+		// Only the width of the 64 bit integer that fits in 32
+		// bits is lost.
+		widened := got.Assoc("/module-v1:container/quoted", uint64(12))
+		if !equal(widened, expected) {
+			t.Fatalf("expected: %s\ngot: %s\n", expected, widened)
+		}
+	})
+	t.Run("big integer", func(t *testing.T) {
+		var got Value
+		err := got.UnmarshalYAML(
+			yamlUnmarshaler("123456789012345678901234567890"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		i, err := got.AsBigInt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.String() != "123456789012345678901234567890" {
+			t.Fatalf("expected: 123456789012345678901234567890\ngot: %s\n",
+				i)
+		}
+	})
+	failures := []struct {
+		name      string
+		unmarshal func(interface{}) error
+		expected  string
+	}{
+		{"not an object", yamlUnmarshaler([]interface{}{1}),
+			"yaml: tree must be an object"},
+		{"non-string key", yamlUnmarshaler(map[interface{}]interface{}{
+			1: "foo",
+		}), "yaml: object keys must be strings"},
+		{"unsupported type", yamlUnmarshaler(map[string]interface{}{
+			"module-v1:leaf": struct{}{},
+		}), "yaml: cannot unmarshal struct {}"},
+		{"unmarshal error", func(interface{}) error {
+			return errors.New("yaml: line 1: did not find expected key")
+		}, "yaml: line 1: did not find expected key"},
+	}
+	for _, test := range failures {
+		t.Run(test.name, func(t *testing.T) {
+			var got Tree
+			err := got.UnmarshalYAML(test.unmarshal)
+			if err == nil || err.Error() != test.expected {
+				t.Fatalf("expected: %s\ngot: %v\n", test.expected, err)
+			}
+		})
+	}
+}